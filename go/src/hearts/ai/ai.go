@@ -0,0 +1,134 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ai contains computer players for Hearts
+// A Strategy decides which cards a computer player passes and plays, given the table it is sitting at
+// Strategies only read the table: the caller is responsible for logging or applying the chosen moves
+// A Strategy may look at its own hand, the current trick, and the cards already taken,
+// but should not look at the hands of the other players
+
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+const (
+	// Avatar is the image displayed for computer players
+	Avatar string = "android.png"
+	// Device is the device image displayed for computer players
+	Device string = "laptopIcon.png"
+)
+
+type Strategy interface {
+	// Returns table.PassSize cards from t.LegalPasses(playerIndex) for the player at playerIndex to pass
+	Pass(t *table.Table, playerIndex int) []*card.Card
+	// Returns a card from t.LegalPlays(playerIndex) for the player at playerIndex to play
+	Play(t *table.Table, playerIndex int) *card.Card
+}
+
+const (
+	Random     string = "random"
+	Heuristic  string = "heuristic"
+	MonteCarlo string = "montecarlo"
+	// DefaultSamples is the number of deals the Monte Carlo strategy samples for each legal card
+	DefaultSamples int = 20
+)
+
+// ErrUnknownStrategy is returned by NewStrategy when given a name it doesn't know
+var ErrUnknownStrategy = errors.New("Unknown strategy")
+
+// Returns the strategy used for computer players unless another is chosen
+func Default() Strategy {
+	return NewHeuristic()
+}
+
+// Returns the strategy called name, using r for any random choices it makes
+func NewStrategy(name string, r *rand.Rand) (Strategy, error) {
+	switch name {
+	case Random:
+		return NewRandom(r), nil
+	case Heuristic:
+		return NewHeuristic(), nil
+	case MonteCarlo:
+		return NewMonteCarlo(DefaultSamples, r), nil
+	}
+	return nil, ErrUnknownStrategy
+}
+
+// Returns the user ID a computer player sitting at playerIndex is known by in the game log
+// These are negative so they can never collide with the ID of a real user
+func UserID(playerIndex int) int {
+	return -(playerIndex + 1)
+}
+
+// Returns true if userID belongs to a computer player
+func IsAI(userID int) bool {
+	return userID < 0
+}
+
+// Returns the display name of a computer player sitting at playerIndex
+func Name(playerIndex int) string {
+	return fmt.Sprintf("Computer %d", playerIndex+1)
+}
+
+// Returns a strategy which passes and plays random legal cards
+func NewRandom(r *rand.Rand) Strategy {
+	return &random{rng: r}
+}
+
+type random struct {
+	rng *rand.Rand
+}
+
+func (s *random) Pass(t *table.Table, playerIndex int) []*card.Card {
+	legal := t.LegalPasses(playerIndex)
+	if len(legal) < table.PassSize {
+		return nil
+	}
+	passed := make([]*card.Card, 0)
+	for _, i := range s.rng.Perm(len(legal))[:table.PassSize] {
+		passed = append(passed, legal[i])
+	}
+	return passed
+}
+
+func (s *random) Play(t *table.Table, playerIndex int) *card.Card {
+	legal := t.LegalPlays(playerIndex)
+	if len(legal) == 0 {
+		return nil
+	}
+	return legal[s.rng.Intn(len(legal))]
+}
+
+// Plays c for the player at playerIndex, the same way the game log applies a Play command
+func play(t *table.Table, playerIndex int, c *card.Card) {
+	p := t.GetPlayers()[playerIndex]
+	p.RemoveFromHand(c)
+	t.SetPlayedCard(c, playerIndex)
+	p.SetDonePlaying(true)
+}
+
+// Plays the rest of the round on t, with every player using s
+func playOut(t *table.Table, s Strategy) {
+	for {
+		if t.TrickOver() {
+			if t.SendTrick(t.GetTrickRecipient()) {
+				return
+			}
+			continue
+		}
+		playerIndex := t.WhoseTurn()
+		c := s.Play(t, playerIndex)
+		if c == nil {
+			return
+		}
+		play(t, playerIndex, c)
+	}
+}
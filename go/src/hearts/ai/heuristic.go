@@ -0,0 +1,207 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// heuristic.go contains a rule-of-thumb strategy: pass away high spades and try to void a suit,
+// then duck under the winning card when following suit and dump the most dangerous card when void
+
+package ai
+
+import (
+	"sort"
+
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+// Returns a strategy which follows simple rules of thumb used by human players
+func NewHeuristic() Strategy {
+	return &heuristic{}
+}
+
+type heuristic struct{}
+
+// Passes the Queen, King and Ace of Spades first, then the cards of the shortest Club or Diamond suit
+// if that empties it, then the highest remaining cards
+func (s *heuristic) Pass(t *table.Table, playerIndex int) []*card.Card {
+	legal := t.LegalPasses(playerIndex)
+	if len(legal) < table.PassSize {
+		return nil
+	}
+	passed := make([]*card.Card, 0)
+	for _, c := range sortedHighToLow(legal) {
+		if isHighSpade(c) && len(passed) < table.PassSize {
+			passed = append(passed, c)
+		}
+	}
+	var voidSuit []*card.Card
+	for _, suit := range []card.Suit{card.Club, card.Diamond} {
+		cards := cardsOfSuit(legal, suit)
+		if len(cards) > 0 && len(cards) <= table.PassSize-len(passed) && (voidSuit == nil || len(cards) < len(voidSuit)) {
+			voidSuit = cards
+		}
+	}
+	passed = append(passed, voidSuit...)
+	for _, c := range sortedHighToLow(legal) {
+		if len(passed) == table.PassSize {
+			break
+		}
+		if !containsCard(passed, c) {
+			passed = append(passed, c)
+		}
+	}
+	return passed
+}
+
+// Leads low, ducks under the winning card when following suit, and dumps the most dangerous card when void
+func (s *heuristic) Play(t *table.Table, playerIndex int) *card.Card {
+	legal := t.LegalPlays(playerIndex)
+	if len(legal) <= 1 {
+		if len(legal) == 0 {
+			return nil
+		}
+		return legal[0]
+	}
+	if t.TrickNew() {
+		return lowestLead(legal)
+	}
+	trick := t.GetTrick()
+	ledSuit := trick[t.GetFirstPlayer()].GetSuit()
+	if legal[0].GetSuit() != ledSuit {
+		return mostDangerous(legal)
+	}
+	winning := card.Two
+	for _, c := range trick {
+		if c != nil && c.GetSuit() == ledSuit && c.GetFace() > winning {
+			winning = c.GetFace()
+		}
+	}
+	sorted := sortedHighToLow(legal)
+	for _, c := range sorted {
+		if c.GetFace() < winning {
+			return c
+		}
+	}
+	// every card takes the trick: if this is the last card of a trick without points, take it as high as possible
+	if isLastToPlay(trick) && trickPoints(t) <= 0 {
+		for _, c := range sorted {
+			if t.GetRules().CardPoints(c) <= 0 {
+				return c
+			}
+		}
+	}
+	return sorted[len(sorted)-1]
+}
+
+// Returns the lowest card, avoiding hearts and high spades where possible
+func lowestLead(cards []*card.Card) *card.Card {
+	var best *card.Card
+	for _, c := range cards {
+		if best == nil || leadCost(c) < leadCost(best) {
+			best = c
+		}
+	}
+	return best
+}
+
+func leadCost(c *card.Card) int {
+	cost := int(c.GetFace())
+	if c.GetSuit() == card.Heart {
+		cost += 20
+	}
+	if isHighSpade(c) {
+		cost += 40
+	}
+	return cost
+}
+
+// Returns the card it is most useful to get rid of when not following suit
+func mostDangerous(cards []*card.Card) *card.Card {
+	var best *card.Card
+	for _, c := range cards {
+		if best == nil || dangerCost(c) > dangerCost(best) {
+			best = c
+		}
+	}
+	return best
+}
+
+func dangerCost(c *card.Card) int {
+	cost := int(c.GetFace())
+	switch {
+	case c.GetSuit() == card.Spade && c.GetFace() == card.Queen:
+		cost += 60
+	case isHighSpade(c):
+		cost += 40
+	case c.GetSuit() == card.Heart:
+		cost += 20
+	}
+	return cost
+}
+
+// Returns the points in the current trick of t
+func trickPoints(t *table.Table) int {
+	points := 0
+	for _, c := range t.GetTrick() {
+		if c != nil {
+			points += t.GetRules().CardPoints(c)
+		}
+	}
+	return points
+}
+
+// Returns true if exactly one card is missing from trick
+func isLastToPlay(trick []*card.Card) bool {
+	missing := 0
+	for _, c := range trick {
+		if c == nil {
+			missing++
+		}
+	}
+	return missing == 1
+}
+
+func isHighSpade(c *card.Card) bool {
+	return c.GetSuit() == card.Spade && c.GetFace() >= card.Queen
+}
+
+func cardsOfSuit(cards []*card.Card, suit card.Suit) []*card.Card {
+	suitCards := make([]*card.Card, 0)
+	for _, c := range cards {
+		if c.GetSuit() == suit {
+			suitCards = append(suitCards, c)
+		}
+	}
+	return suitCards
+}
+
+func containsCard(cards []*card.Card, c *card.Card) bool {
+	for _, other := range cards {
+		if other == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a copy of cards sorted from the highest face to the lowest
+func sortedHighToLow(cards []*card.Card) []*card.Card {
+	sorted := append([]*card.Card(nil), cards...)
+	sort.Stable(highToLowSorter(sorted))
+	return sorted
+}
+
+// Used to sort an array of cards by face, highest first
+type highToLowSorter []*card.Card
+
+func (hs highToLowSorter) Len() int {
+	return len(hs)
+}
+
+func (hs highToLowSorter) Swap(i, j int) {
+	hs[i], hs[j] = hs[j], hs[i]
+}
+
+func (hs highToLowSorter) Less(i, j int) bool {
+	return hs[i].GetFace() > hs[j].GetFace()
+}
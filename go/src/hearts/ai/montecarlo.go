@@ -0,0 +1,129 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// montecarlo.go contains a sampling strategy: for each legal card, it deals the unseen cards randomly
+// between the other players many times, plays the rest of the round out, and picks the card that scored best
+
+package ai
+
+import (
+	"math/rand"
+
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+// Returns a strategy which plays each legal card against samples random deals of the unseen cards
+// The rest of every sampled round is played out with the heuristic strategy
+func NewMonteCarlo(samples int, r *rand.Rand) Strategy {
+	return &monteCarlo{
+		samples: samples,
+		rng:     r,
+		rollout: NewHeuristic(),
+	}
+}
+
+type monteCarlo struct {
+	samples int
+	rng     *rand.Rand
+	rollout Strategy
+}
+
+// Passing is left to the rollout strategy, there is too little known about the other hands to sample usefully
+func (s *monteCarlo) Pass(t *table.Table, playerIndex int) []*card.Card {
+	return s.rollout.Pass(t, playerIndex)
+}
+
+// Plays the legal card with the lowest total score for the player at playerIndex over all samples
+func (s *monteCarlo) Play(t *table.Table, playerIndex int) *card.Card {
+	legal := t.LegalPlays(playerIndex)
+	if len(legal) <= 1 {
+		if len(legal) == 0 {
+			return nil
+		}
+		return legal[0]
+	}
+	var best *card.Card
+	bestScore := 0
+	for _, c := range legal {
+		score := 0
+		for i := 0; i < s.samples; i++ {
+			sim := s.sample(t, playerIndex)
+			play(sim, playerIndex, c)
+			playOut(sim, s.rollout)
+			score += sim.ScoreRound()[playerIndex]
+		}
+		if best == nil || score < bestScore {
+			best = c
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// Returns a copy of t where the cards the player at playerIndex can't see are dealt randomly between the other players
+// Each player keeps the same number of cards, and cards passed by the player at playerIndex stay with their recipient
+// Hidden cards, in hands dealt sealed, are replaced by the cards of the deck which can't be seen anywhere on the table
+func (s *monteCarlo) sample(t *table.Table, playerIndex int) *table.Table {
+	sim := t.Copy()
+	players := sim.GetPlayers()
+	recipient := t.GetPassRecipient(playerIndex)
+	passed := players[playerIndex].GetPassedFrom()
+	unseen := make([]*card.Card, 0)
+	known := make([][]*card.Card, len(players))
+	sizes := make([]int, len(players))
+	for i, p := range players {
+		if i == playerIndex {
+			continue
+		}
+		sizes[i] = len(p.GetHand())
+		for _, c := range p.GetHand() {
+			if c.Hidden() {
+				continue
+			} else if i == recipient && containsCard(passed, c) {
+				known[i] = append(known[i], c)
+			} else {
+				unseen = append(unseen, c)
+			}
+		}
+	}
+	unseen = append(unseen, hiddenCards(t)...)
+	shuffle := s.rng.Perm(len(unseen))
+	next := 0
+	for i, p := range players {
+		if i == playerIndex {
+			continue
+		}
+		hand := append([]*card.Card(nil), known[i]...)
+		for len(hand) < sizes[i] && next < len(unseen) {
+			hand = append(hand, unseen[shuffle[next]])
+			next++
+		}
+		p.SetHand(hand)
+	}
+	return sim
+}
+
+// Returns the cards of the deck of t which can't be seen in any hand or trick, and so must be behind hidden cards
+func hiddenCards(t *table.Table) []*card.Card {
+	seen := make(map[*card.Card]bool)
+	for _, p := range t.GetPlayers() {
+		for _, c := range p.GetHand() {
+			seen[c] = true
+		}
+		for _, c := range p.GetTricks() {
+			seen[c] = true
+		}
+	}
+	for _, c := range t.GetTrick() {
+		seen[c] = true
+	}
+	hidden := make([]*card.Card, 0)
+	for _, c := range t.GetAllCards() {
+		if !seen[c] {
+			hidden = append(hidden, c)
+		}
+	}
+	return hidden
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// hearts-sim plays games of Hearts between computer players and reports how each seat did
+// It is used to compare strategies and rule variants without running the app
+//
+// Example:
+//   hearts-sim -games 1000 -seed 7 -strategies heuristic,random,random,montecarlo -format csv
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"hearts/ai"
+	"hearts/logic/table"
+)
+
+var (
+	games              = flag.Int("games", 100, "number of games to play")
+	seed               = flag.Int64("seed", 1, "seed for dealing and for the strategies' random choices")
+	strategyNames      = flag.String("strategies", "heuristic,heuristic,heuristic,heuristic", "comma separated strategy for each seat: random, heuristic or montecarlo")
+	format             = flag.String("format", "json", "output format: json or csv")
+	pointsOnFirstTrick = flag.Bool("points-on-first-trick", false, "allow point cards on the first trick")
+	jackOfDiamonds     = flag.Bool("jack-of-diamonds", false, "make the Jack of Diamonds worth -10 points")
+	shootTheSun        = flag.Bool("shoot-the-sun", false, "double the moon points if the shooter took every trick")
+	moon               = flag.String("moon", "others", "who moon points go to: others, shooter or self")
+)
+
+func main() {
+	flag.Parse()
+	rules, err := parseRules()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	names := strings.Split(*strategyNames, ",")
+	if len(names) < 3 || len(names) > 6 {
+		fmt.Fprintln(os.Stderr, "hearts-sim: need between 3 and 6 strategies, got", len(names))
+		os.Exit(2)
+	}
+	strategies := make([]ai.Strategy, len(names))
+	for i, name := range names {
+		strategies[i], err = ai.NewStrategy(name, rand.New(rand.NewSource(*seed+int64(i)+1)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hearts-sim: %v: %s\n", err, name)
+			os.Exit(2)
+		}
+	}
+	res := simulate(*games, *seed, rules, names, strategies)
+	switch *format {
+	case "json":
+		err = writeJSON(os.Stdout, res)
+	case "csv":
+		err = writeCSV(os.Stdout, res)
+	default:
+		err = fmt.Errorf("hearts-sim: unknown format %s", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Returns the rules chosen by the command line flags
+func parseRules() (*table.Rules, error) {
+	rules := &table.Rules{
+		PointsOnFirstTrick: *pointsOnFirstTrick,
+		JackOfDiamonds:     *jackOfDiamonds,
+		ShootTheSun:        *shootTheSun,
+	}
+	switch *moon {
+	case "others":
+		rules.Moon = table.AddToOthers
+	case "shooter":
+		rules.Moon = table.SubtractFromShooter
+	case "self":
+		rules.Moon = table.AddToSelf
+	default:
+		return nil, fmt.Errorf("hearts-sim: unknown moon rule %s", *moon)
+	}
+	return rules, nil
+}
+
+func writeJSON(w io.Writer, res *simResults) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// Writes one row for each seat
+func writeCSV(w io.Writer, res *simResults) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"seat", "strategy", "games", "wins", "win_rate", "avg_points", "avg_round_points", "moons", "moon_rate", "violations"})
+	for _, s := range res.Seats {
+		cw.Write([]string{
+			strconv.Itoa(s.Seat),
+			s.Strategy,
+			strconv.Itoa(res.Games),
+			strconv.Itoa(s.Wins),
+			strconv.FormatFloat(s.WinRate, 'f', 4, 64),
+			strconv.FormatFloat(s.AvgPoints, 'f', 2, 64),
+			strconv.FormatFloat(s.AvgRoundPoints, 'f', 2, 64),
+			strconv.Itoa(s.Moons),
+			strconv.FormatFloat(s.MoonRate, 'f', 4, 64),
+			strconv.Itoa(s.Violations),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,157 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sim.go plays whole games of Hearts between computer players, without any UI or syncbase,
+// and keeps track of how each seat does
+
+package main
+
+import (
+	"math/rand"
+
+	"hearts/ai"
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+// maxRounds ends a game which hasn't been won after this many rounds, in case the rules allow scores to stall
+const maxRounds = 1000
+
+// Results for one seat at the table, over every game simulated
+type seatStats struct {
+	Seat           int     `json:"seat"`
+	Strategy       string  `json:"strategy"`
+	Wins           int     `json:"wins"`
+	WinRate        float64 `json:"win_rate"`
+	AvgPoints      float64 `json:"avg_points"`
+	AvgRoundPoints float64 `json:"avg_round_points"`
+	Moons          int     `json:"moons"`
+	MoonRate       float64 `json:"moon_rate"`
+	Violations     int     `json:"violations"`
+	totalPoints    int
+	roundPoints    int
+}
+
+// Results of a whole simulation
+type simResults struct {
+	Games  int          `json:"games"`
+	Rounds int          `json:"rounds"`
+	Seed   int64        `json:"seed"`
+	Rules  table.Rules  `json:"rules"`
+	Seats  []*seatStats `json:"seats"`
+}
+
+// Plays games games between strategies, one per seat, and returns the results
+func simulate(games int, seed int64, rules *table.Rules, names []string, strategies []ai.Strategy) *simResults {
+	res := &simResults{
+		Games: games,
+		Seed:  seed,
+		Rules: *rules,
+		Seats: make([]*seatStats, len(strategies)),
+	}
+	for i, name := range names {
+		res.Seats[i] = &seatStats{Seat: i, Strategy: name}
+	}
+	dealRand := rand.New(rand.NewSource(seed))
+	for g := 0; g < games; g++ {
+		t := table.InitializeGame(len(strategies), rules)
+		t.SetRand(dealRand)
+		playGame(t, strategies, res)
+	}
+	for _, s := range res.Seats {
+		if games > 0 {
+			s.WinRate = float64(s.Wins) / float64(games)
+			s.AvgPoints = float64(s.totalPoints) / float64(games)
+		}
+		if res.Rounds > 0 {
+			s.AvgRoundPoints = float64(s.roundPoints) / float64(res.Rounds)
+			s.MoonRate = float64(s.Moons) / float64(res.Rounds)
+		}
+	}
+	return res
+}
+
+// Plays rounds on t until someone wins, adding the outcome to res
+func playGame(t *table.Table, strategies []ai.Strategy, res *simResults) {
+	for round := 0; round < maxRounds; round++ {
+		playRound(t, strategies, res)
+		res.Rounds++
+		if shooter := t.Shooter(); shooter >= 0 {
+			res.Seats[shooter].Moons++
+		}
+		roundScores, winners := t.EndRound()
+		for i, score := range roundScores {
+			res.Seats[i].roundPoints += score
+		}
+		if len(winners) > 0 {
+			for _, w := range winners {
+				res.Seats[w].Wins++
+			}
+			break
+		}
+	}
+	for i, p := range t.GetPlayers() {
+		res.Seats[i].totalPoints += p.GetScore()
+	}
+}
+
+// Deals, passes, takes and plays one round on t
+func playRound(t *table.Table, strategies []ai.Strategy, res *simResults) {
+	players := t.GetPlayers()
+	for i, h := range t.Deal() {
+		players[i].SetHand(h)
+	}
+	t.NewRound()
+	passes := make([][]*card.Card, len(players))
+	for i := range players {
+		legal := t.LegalPasses(i)
+		if len(legal) == 0 {
+			continue
+		}
+		passes[i] = strategies[i].Pass(t, i)
+		if t.ValidPassFrom(passes[i], i) != nil {
+			res.Seats[i].Violations++
+			passes[i] = legal[:table.PassSize]
+		}
+	}
+	for i, passed := range passes {
+		if passed == nil {
+			continue
+		}
+		for _, c := range passed {
+			players[i].RemoveFromHand(c)
+		}
+		players[i].SetPassedFrom(passed)
+		players[t.GetPassRecipient(i)].SetPassedTo(passed)
+		players[i].SetDonePassing(true)
+	}
+	for _, p := range players {
+		if !p.GetDoneTaking() {
+			for _, c := range p.GetPassedTo() {
+				p.AddToHand(c)
+			}
+			p.SetDoneTaking(true)
+		}
+		if p.HasCard(t.GetFirstLead()) {
+			t.SetFirstPlayer(p.GetPlayerIndex())
+		}
+	}
+	for {
+		if t.TrickOver() {
+			if t.SendTrick(t.GetTrickRecipient()) {
+				return
+			}
+			continue
+		}
+		i := t.WhoseTurn()
+		c := strategies[i].Play(t, i)
+		if t.ValidPlay(c, i) != nil {
+			res.Seats[i].Violations++
+			c = t.LegalPlays(i)[0]
+		}
+		players[i].RemoveFromHand(c)
+		t.SetPlayedCard(c, i)
+		players[i].SetDonePlaying(true)
+	}
+}
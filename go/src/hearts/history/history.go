@@ -0,0 +1,143 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// history keeps a record of every game this device has seen finish, and the statistics of each user across them.
+// Games are kept in a store table under rows no syncgroup shares, so each device keeps its own history.
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"hearts/store"
+)
+
+// the prefix of the rows games are kept under, followed by <game_id>/<number>
+const prefix = "history/"
+
+type Game struct {
+	GameID   int       // the ID of the game log the game was played in
+	Number   int       // how many games were played in the same log before this one
+	Players  []Player  // the players by seat
+	Rounds   []Round   // the rounds in the order they were played
+	Winners  []int     // the seats of the players who won
+	Started  time.Time // when this device saw the first hand dealt
+	Finished time.Time // when this device saw the last trick taken
+}
+
+type Player struct {
+	UserID int
+	Name   string
+}
+
+type Round struct {
+	Scores      []int // the points each seat scored
+	MoonShooter int   // the seat of the player who shot the moon, or -1
+	QueenTaker  int   // the seat of the player who took the Queen of Spades
+}
+
+// Returns how long the game took
+func (g *Game) Duration() time.Duration {
+	return g.Finished.Sub(g.Started)
+}
+
+// Returns the total score of each seat
+func (g *Game) Scores() []int {
+	scores := make([]int, len(g.Players))
+	for _, r := range g.Rounds {
+		for i, s := range r.Scores {
+			if i < len(scores) {
+				scores[i] += s
+			}
+		}
+	}
+	return scores
+}
+
+// Returns the seat of the user userID in g, or -1 if they didn't play in it
+func (g *Game) Seat(userID int) int {
+	for i, p := range g.Players {
+		if p.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns true if the player at seat won g
+func (g *Game) Won(seat int) bool {
+	for _, w := range g.Winners {
+		if w == seat {
+			return true
+		}
+	}
+	return false
+}
+
+// History reads and writes the games kept in a table of a store
+type History struct {
+	s     store.Store
+	table string
+}
+
+// Returns the history kept in table of s
+func New(s store.Store, table string) *History {
+	return &History{s: s, table: table}
+}
+
+func gameKey(gameID, number int) string {
+	return fmt.Sprintf("%s%d/%d", prefix, gameID, number)
+}
+
+// Records g, unless a game with the same GameID and Number is already recorded. Returns true if g was recorded
+// A game read back from the log again, when a device rejoins it, keeps the times it was first recorded with
+func (h *History) Add(g *Game) (bool, error) {
+	key := gameKey(g.GameID, g.Number)
+	rows, err := h.s.Scan(h.table, key)
+	if err != nil {
+		return false, err
+	}
+	for _, row := range rows {
+		if row.Key == key {
+			return false, nil
+		}
+	}
+	value, err := json.Marshal(g)
+	if err != nil {
+		return false, err
+	}
+	if err := h.s.Put(h.table, key, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns every recorded game, the most recently finished first
+// Rows which can't be read are skipped
+func (h *History) Games() ([]*Game, error) {
+	rows, err := h.s.Scan(h.table, prefix)
+	if err != nil {
+		return nil, err
+	}
+	games := make([]*Game, 0, len(rows))
+	for _, row := range rows {
+		if !strings.HasPrefix(row.Key, prefix) {
+			continue
+		}
+		g := &Game{}
+		if err := json.Unmarshal(row.Value, g); err != nil {
+			fmt.Println("History error:", row.Key, err)
+			continue
+		}
+		games = append(games, g)
+	}
+	sort.SliceStable(games, func(i, j int) bool {
+		return games[i].Finished.After(games[j].Finished)
+	})
+	return games, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// stats.go sums up how a user has played across the games in their history, and rates every user from them
+
+package history
+
+import (
+	"hearts/rating"
+)
+
+// Stats are the totals of one user across the games they played in
+type Stats struct {
+	GamesPlayed int
+	GamesWon    int
+	Rounds      int // the rounds played in those games
+	Points      int // the points scored in those rounds
+	Queens      int // the rounds in which the user took the Queen of Spades
+	MoonShots   int // the rounds in which the user shot the moon
+}
+
+// Returns the stats of the user userID across games
+func UserStats(games []*Game, userID int) Stats {
+	var s Stats
+	for _, g := range games {
+		seat := g.Seat(userID)
+		if seat < 0 {
+			continue
+		}
+		s.GamesPlayed++
+		if g.Won(seat) {
+			s.GamesWon++
+		}
+		for _, r := range g.Rounds {
+			s.Rounds++
+			if seat < len(r.Scores) {
+				s.Points += r.Scores[seat]
+			}
+			if r.QueenTaker == seat {
+				s.Queens++
+			}
+			if r.MoonShooter == seat {
+				s.MoonShots++
+			}
+		}
+	}
+	return s
+}
+
+// Returns the fraction of games played which were won, 0 if none were played
+func (s Stats) WinRate() float64 {
+	if s.GamesPlayed == 0 {
+		return 0
+	}
+	return float64(s.GamesWon) / float64(s.GamesPlayed)
+}
+
+// Returns the average points scored per round, 0 if no rounds were played
+func (s Stats) AveragePoints() float64 {
+	if s.Rounds == 0 {
+		return 0
+	}
+	return float64(s.Points) / float64(s.Rounds)
+}
+
+// Returns the rating of every user who played in games, rating the games in the order they finished
+// Users for whom unrated returns true, such as computer players, play each game at the default rating and aren't rated
+func Ratings(games []*Game, unrated func(userID int) bool) map[int]rating.Rating {
+	ratings := make(map[int]rating.Rating)
+	for i := len(games) - 1; i >= 0; i-- {
+		g := games[i]
+		start := make([]rating.Rating, len(g.Players))
+		for seat, p := range g.Players {
+			start[seat] = rating.Default()
+			if r, ok := ratings[p.UserID]; ok {
+				start[seat] = r
+			}
+		}
+		for seat, r := range rating.Update(start, g.Scores()) {
+			if userID := g.Players[seat].UserID; !unrated(userID) {
+				ratings[userID] = r
+			}
+		}
+	}
+	return ratings
+}
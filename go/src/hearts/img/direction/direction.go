@@ -3,6 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // direction is a struct used for anything which involves sending something to a variable edge of the screen (passing cards and taking tricks)
+// Seat places the players of a table along those edges
 
 package direction
 
@@ -15,3 +16,31 @@ const (
 	None
 	Down
 )
+
+// the edges of the screen, clockwise from the bottom
+var edges = []Direction{Down, Left, Across, Right}
+
+// Returns the edge of the screen the player offset seats clockwise from the player at the bottom of a table of
+// numPlayers sits at, their place among the players at that edge, counted from the left or the top,
+// and how many players sit there
+// Players are spread evenly around the table, each at the edge nearest their place
+func Seat(offset, numPlayers int) (Direction, int, int) {
+	edgeIndex := func(i int) int {
+		return (8*i + numPlayers) / (2 * numPlayers) % len(edges)
+	}
+	edge := edgeIndex(offset)
+	slot, slots := 0, 0
+	for i := 0; i < numPlayers; i++ {
+		if edgeIndex(i) == edge {
+			if i < offset {
+				slot++
+			}
+			slots++
+		}
+	}
+	// going clockwise runs right to left along the bottom, and bottom to top along the left
+	if edges[edge] == Down || edges[edge] == Left {
+		slot = slots - 1 - slot
+	}
+	return edges[edge], slot, slots
+}
@@ -7,6 +7,7 @@
 package reposition
 
 import (
+	"math"
 	"time"
 
 	"hearts/img/coords"
@@ -131,20 +132,21 @@ func DetermineTablePassPosition(c *card.Card, cardNum, playerIndex int, u *uista
 	yPlayerBlockSize := u.TopPadding + u.TableCardDim.Y + 3*u.Padding + u.PlayerIconDim.Y
 	blockEdge := targetCenter.MinusVec(cardDim.Times(1.5).Plus(u.Padding))
 	var destination *coords.Vec
-	switch playerIndex {
-	case 0:
+	edge, _, _ := direction.Seat(playerIndex, u.NumPlayers)
+	switch edge {
+	case direction.Down:
 		destination = coords.MakeVec(
 			blockEdge.X+float32(cardNum)*(u.Padding+cardDim.X),
 			u.WindowSize.Y-yPlayerBlockSize-u.TableCardDim.Y)
-	case 1:
+	case direction.Left:
 		destination = coords.MakeVec(
 			xPlayerBlockSize,
 			blockEdge.Y+float32(cardNum)*(u.Padding+cardDim.Y))
-	case 2:
+	case direction.Across:
 		destination = coords.MakeVec(
 			blockEdge.X+float32(cardNum)*(u.Padding+cardDim.X),
 			yPlayerBlockSize+u.Padding)
-	case 3:
+	case direction.Right:
 		destination = coords.MakeVec(
 			u.WindowSize.X-xPlayerBlockSize-u.TableCardDim.X,
 			blockEdge.Y+float32(cardNum)*(u.Padding+cardDim.Y))
@@ -268,12 +270,14 @@ func AnimateSplitCardPlay(c *card.Card, player int, quit chan bool, u *uistate.U
 	toPos := dropTarget.GetCurrent()
 	toDim := dropTarget.GetDimensions()
 	texture.PopulateCardImage(c, u)
-	switch player {
-	case (u.CurPlayerIndex + 1) % u.NumPlayers:
+	// the card comes in from the edge of the screen the player sits at
+	edge, _, _ := direction.Seat((player-u.CurPlayerIndex+u.NumPlayers)%u.NumPlayers, u.NumPlayers)
+	switch edge {
+	case direction.Left:
 		c.Move(coords.MakeVec(-toDim.X, 0), toDim, u.Eng)
-	case (u.CurPlayerIndex + 2) % u.NumPlayers:
-		c.Move(coords.MakeVec((u.WindowSize.X-toDim.X)/2, -toDim.Y), toDim, u.Eng)
-	case (u.CurPlayerIndex + 3) % u.NumPlayers:
+	case direction.Across:
+		c.Move(coords.MakeVec(toPos.X, -toDim.Y), toDim, u.Eng)
+	case direction.Right:
 		c.Move(coords.MakeVec(u.WindowSize.X, 0), toDim, u.Eng)
 	}
 	ch := make(chan bool)
@@ -529,29 +533,59 @@ func SetCardPositionTable(c *card.Card, playerIndex int, cardIndex *coords.Vec,
 func CardPositionTable(playerIndex int, cardIndex *coords.Vec, u *uistate.UIState) *coords.Vec {
 	var x float32
 	var y float32
-	switch playerIndex {
-	case 0:
-		x = horizontalPlayerCardX(u.WindowSize, cardIndex, u.TableCardDim, u.BottomPadding, u.Overlap.X)
+	edge, start, length := TableSeat(playerIndex, u)
+	switch edge {
+	case direction.Down:
+		x = horizontalPlayerCardX(start, length, cardIndex, u.TableCardDim, u.BottomPadding, u.Overlap.X)
 		y = u.WindowSize.Y - u.TableCardDim.Y - u.BottomPadding
-	case 1:
+	case direction.Left:
 		x = u.BottomPadding
-		y = verticalPlayerCardY(u.WindowSize, cardIndex, u.TableCardDim, u.PlayerIconDim, u.BottomPadding, u.Overlap.Y, u.CardScaler)
-	case 2:
-		x = horizontalPlayerCardX(u.WindowSize, cardIndex, u.TableCardDim, u.BottomPadding, u.Overlap.X)
+		y = verticalPlayerCardY(start, length, cardIndex, u.TableCardDim, u.PlayerIconDim, u.BottomPadding, u.Overlap.Y)
+	case direction.Across:
+		x = horizontalPlayerCardX(start, length, cardIndex, u.TableCardDim, u.BottomPadding, u.Overlap.X)
 		y = u.TopPadding
-	case 3:
+	case direction.Right:
 		x = u.WindowSize.X - u.BottomPadding - u.TableCardDim.X
-		y = verticalPlayerCardY(u.WindowSize, cardIndex, u.TableCardDim, u.PlayerIconDim, u.BottomPadding, u.Overlap.Y, u.CardScaler)
+		y = verticalPlayerCardY(start, length, cardIndex, u.TableCardDim, u.PlayerIconDim, u.BottomPadding, u.Overlap.Y)
 	}
 	return coords.MakeVec(x, y)
 }
 
-func horizontalPlayerCardX(windowSize, cardIndex, cardDim *coords.Vec, edgePadding, overlap float32) float32 {
-	return (windowSize.X+edgePadding-(float32(cardIndex.X)*(cardDim.X-overlap)+cardDim.X))/2 + float32(cardIndex.Y)*(cardDim.X-overlap)
+// Returns the edge of the screen the player at playerIndex sits at in the table view,
+// and where the stretch of that edge their hand is centered in starts, and how long it is
+func TableSeat(playerIndex int, u *uistate.UIState) (direction.Direction, float32, float32) {
+	edge, slot, slots := direction.Seat(playerIndex, u.NumPlayers)
+	length := u.WindowSize.X
+	if edge == direction.Left || edge == direction.Right {
+		length = u.WindowSize.Y
+	}
+	length /= float32(slots)
+	return edge, float32(slot) * length, length
+}
+
+// Returns the position around center of the drop target of the player offset seats clockwise from the player at the
+// bottom of a table of numPlayers, set radius away from center, or further if neighbouring targets would overlap
+func SeatTargetPos(offset, numPlayers int, center, radius, dim *coords.Vec) *coords.Vec {
+	angle := func(i int) float64 {
+		return 2 * math.Pi * float64(i) / float64(numPlayers)
+	}
+	scale := 1.0
+	for i := 0; i < numPlayers; i++ {
+		dx := math.Abs(math.Sin(angle(i))-math.Sin(angle(i+1))) * float64(radius.X)
+		dy := math.Abs(math.Cos(angle(i))-math.Cos(angle(i+1))) * float64(radius.Y)
+		scale = math.Max(scale, math.Min(float64(dim.X)/dx, float64(dim.Y)/dy))
+	}
+	x := -math.Sin(angle(offset)) * scale * float64(radius.X)
+	y := math.Cos(angle(offset)) * scale * float64(radius.Y)
+	return center.PlusVec(coords.MakeVec(float32(x), float32(y))).MinusVec(dim.DividedBy(2))
+}
+
+func horizontalPlayerCardX(start, length float32, cardIndex, cardDim *coords.Vec, edgePadding, overlap float32) float32 {
+	return start + (length+edgePadding-(float32(cardIndex.X)*(cardDim.X-overlap)+cardDim.X))/2 + float32(cardIndex.Y)*(cardDim.X-overlap)
 }
 
-func verticalPlayerCardY(windowSize, cardIndex, cardDim, playerIconDim *coords.Vec, edgePadding, overlap, cardScaler float32) float32 {
-	return (playerIconDim.Y+windowSize.Y+2*edgePadding-(float32(cardIndex.X)*(cardDim.Y-overlap)+cardDim.Y))/2 +
+func verticalPlayerCardY(start, length float32, cardIndex, cardDim, playerIconDim *coords.Vec, edgePadding, overlap float32) float32 {
+	return start + (playerIconDim.Y+length+2*edgePadding-(float32(cardIndex.X)*(cardDim.Y-overlap)+cardDim.Y))/2 +
 		float32(cardIndex.Y)*(cardDim.Y-overlap)
 }
 
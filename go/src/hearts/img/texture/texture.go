@@ -7,6 +7,7 @@
 package texture
 
 import (
+	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
@@ -49,6 +50,10 @@ func PopulateCardImage(c *card.Card, u *uistate.UIState) {
 		texKey += strconv.Itoa(int(c.GetFace()))
 	}
 	texKey += ".png"
+	// a card this device can't see only ever shows its back
+	if c.Hidden() {
+		texKey = "BakuSquare.png"
+	}
 	n := MakeNode(u)
 	u.Eng.SetSubTex(n, u.Texs[texKey])
 	c.SetNode(n)
@@ -316,9 +321,26 @@ func LoadTextures(eng sprite.Engine) map[string]sprite.SubTex {
 		allTexs[f] = sprite.SubTex{t, image.Rect(1, 1, imgWidth-1, imgHeight-1)}
 		a.Close()
 	}
+	// the colors a user can choose have no image files, so solid swatches of them are drawn instead
+	for _, c := range uistate.Colors {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(c>>16), uint8(c>>8), uint8(c), 0xff
+		}
+		t, err := eng.LoadTexture(img)
+		if err != nil {
+			log.Fatal(err)
+		}
+		allTexs[ColorTexKey(c)] = sprite.SubTex{t, image.Rect(1, 1, 3, 3)}
+	}
 	return allTexs
 }
 
+// Returns the key of the texture of a solid swatch of c, one of uistate.Colors
+func ColorTexKey(c int) string {
+	return fmt.Sprintf("Color-%06x", c)
+}
+
 // Returns a new sprite node
 // NOTE: Currently, this is a public method, as it is useful in testing. Eventually it should be made private.
 func MakeNode(u *uistate.UIState) *sprite.Node {
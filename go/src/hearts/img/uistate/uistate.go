@@ -13,17 +13,22 @@ import (
 	"sync"
 	"time"
 
+	"hearts/ai"
+	"hearts/history"
 	"hearts/img/coords"
 	"hearts/img/staticimg"
 	"hearts/logic/card"
 	"hearts/logic/table"
+	"hearts/rating"
+	"hearts/replay"
+	"hearts/store"
+	"hearts/util"
 
 	"golang.org/x/mobile/exp/audio"
 	"golang.org/x/mobile/exp/gl/glutil"
 	"golang.org/x/mobile/exp/sprite"
 
 	"v.io/v23/context"
-	"v.io/v23/syncbase"
 )
 
 type View string
@@ -38,16 +43,30 @@ const (
 	Play      View = "Play"
 	Score     View = "Score"
 	Split     View = "Split"
+	Profile   View = "Profile"
+	Stats     View = "Stats"
 )
 
 const (
 	Avatar string = "avatar"
 	Name   string = "name"
 	Device string = "device"
+	Color  string = "color"
 )
 
+// The avatars and colors a user can choose for their profile, the same as the Flutter version of Croupier offers
+var (
+	Avatars = []string{"Club.png", "Diamond.png", "Heart.png", "Spade.png", "cat.png", "android.png", "man.png", "woman.png"}
+	Colors  = []int{0xefefef, 0xff3333, 0x33ff33, 0x3333ff, 0x101010, 0x33ffff, 0xff33ff, 0xffff33}
+)
+
+// MaxNameLength is the most characters a user's display name may have
+const MaxNameLength = 16
+
+// ClassicNumPlayers is the number of players in a game which doesn't say how many it is for
+const ClassicNumPlayers = 4
+
 const (
-	numPlayers    int     = 4
 	numSuits      int     = 4
 	cardSize      float32 = 35
 	cardScaler    float32 = .5
@@ -76,7 +95,7 @@ type UIState struct {
 	CurImg         *staticimg.StaticImg // the image that is currently clicked on
 	// lastMouseXY is in Px: divide by pixelsPerPt to get Pt
 	LastMouseXY *coords.Vec // the position of the mouse in the most recent frame
-	NumPlayers  int
+	NumPlayers  int         // the number of players in the current game
 	NumSuits    int
 	// the following variables are used for sizing and positioning specifications
 	CardSize         float32
@@ -97,7 +116,7 @@ type UIState struct {
 	Texs             map[string]sprite.SubTex // map of all loaded images
 	CurPlayerIndex   int                      // the player number of this player
 	Ctx              *context.T
-	Service          syncbase.Service
+	Store            store.Store                    // where the game log and settings are read and written
 	LogSG            string                         // name of the game log syncgroup the user is currently in
 	Debug            bool                           // true if debugging, adds extra functionality to switch between players
 	SequentialPhases bool                           // true if trying to match Croupier Flutter Pass -> Take -> Play phase system
@@ -113,8 +132,31 @@ type UIState struct {
 	GameChan         chan bool                      // pass in a bool to stop receiving updates from the current game
 	DiscGroups       map[string]*DiscStruct         // contains a set of addresses and game start data for each advertised game found
 	M                sync.Mutex
-	Audio            *PlayerStruct // audio players for app sounds
-	LatestTimestamp  int64         // highest timestamp seen so far
+	Audio            *PlayerStruct       // audio players for app sounds
+	Clock            *replay.Clock       // timestamps the entries this device writes to the game log
+	AIPlayers        map[int]ai.Strategy // strategies of the computer players this device moves for, keyed by player number
+	AIPending        map[int]bool        // true for a computer player whose last move hasn't come back through the log yet
+	Offline          bool                // true if the most recent write to Store failed every retry
+	GameStatus       replay.Status       // status of the current game, as last written or read
+	Spectators       map[int]bool        // user IDs of the users who chose to watch the current game rather than play
+	GodView          bool                // true if a spectator has chosen to see every hand once the game is over
+	Blessings        map[int][]string    // blessings each user in the current game writes with, keyed by user ID
+	HandKey          *replay.HandKey     // the key the cards dealt and passed to this device in the current game are sealed to
+	HandKeys         map[int]string      // public keys each user in the current game is dealt cards with, keyed by user ID
+	RevealPending    map[int]bool        // true for a seat whose cards this device revealed, until the reveal comes back through the log
+	DealPending      map[int]bool        // true for a seat whose step of the deal this device wrote, until it comes back through the log
+	Record           *history.Game       // the current game as it will be kept in this device's history once it finishes
+
+	Ratings map[int]rating.Rating // ratings of the users this device rated from its history, keyed by user ID
+
+	TurnLimit     time.Duration               // time each player has for a move in the current game, 0 for no limit
+	TurnPlayer    int                         // the player whose move is being timed, or -1
+	TurnStart     time.Time                   // when this device saw the turn of TurnPlayer begin
+	TimeoutLogged bool                        // true once this device has played for TurnPlayer after they ran out of time
+	Releases      map[int]*replay.GameCommand // the releases each seat this device controls writes if TurnPlayer stalls
+	ReleaseLogged bool                        // true once this device has released its shares of the hand of TurnPlayer
+	TimerChan     chan bool                   // pass in a bool to stop timing the moves of the current game
+	Countdown     []*staticimg.StaticImg      // the time left for the current move, shown in the play header
 }
 
 func MakeUIState() *UIState {
@@ -128,9 +170,9 @@ func MakeUIState() *UIState {
 		Buttons:          make(map[string]*staticimg.StaticImg),
 		Other:            make([]*staticimg.StaticImg, 0),
 		ModText:          make([]*staticimg.StaticImg, 0),
-		RoundScores:      make([]int, numPlayers),
+		RoundScores:      make([]int, ClassicNumPlayers),
 		LastMouseXY:      coords.MakeVec(-1, -1),
-		NumPlayers:       numPlayers,
+		NumPlayers:       ClassicNumPlayers,
 		NumSuits:         numSuits,
 		CardSize:         cardSize,
 		CardScaler:       cardScaler,
@@ -153,7 +195,16 @@ func MakeUIState() *UIState {
 		DiscGroups:       make(map[string]*DiscStruct),
 		CurPlayerIndex:   -1,
 		Audio:            makePlayerStruct([]string{"whooshIn.wav", "whooshOut.wav"}),
-		LatestTimestamp:  0,
+		Clock:            replay.NewClock(),
+		AIPlayers:        make(map[int]ai.Strategy),
+		AIPending:        make(map[int]bool),
+		Spectators:       make(map[int]bool),
+		Blessings:        make(map[int][]string),
+		HandKeys:         make(map[int]string),
+		RevealPending:    make(map[int]bool),
+		DealPending:      make(map[int]bool),
+		Ratings:          make(map[int]rating.Rating),
+		TurnPlayer:       -1,
 	}
 }
 
@@ -175,6 +226,19 @@ func GetName(playerNum int, u *UIState) string {
 	return u.UserData[userID][Name].(string)
 }
 
+// Returns the rating of the player at playerNum, and false if they're a computer player, who isn't rated
+// A user without a rating yet has the default one
+func GetRating(playerNum int, u *UIState) (rating.Rating, bool) {
+	userID := u.PlayerData[playerNum]
+	if ai.IsAI(userID) {
+		return rating.Rating{}, false
+	}
+	if r, ok := u.Ratings[userID]; ok {
+		return r, true
+	}
+	return rating.Default(), true
+}
+
 func GetDevice(playerNum int, u *UIState) sprite.SubTex {
 	blankTex := u.Texs["laptop.png"]
 	userID := u.PlayerData[playerNum]
@@ -184,6 +248,21 @@ func GetDevice(playerNum int, u *UIState) sprite.SubTex {
 	return u.Texs[u.UserData[userID][Device].(string)]
 }
 
+// Returns the status of the game gameID in this device's copy of the game log, which is empty if it has never joined the game
+func GetGameStatus(gameID int, u *UIState) replay.Status {
+	rows, err := u.Store.Scan(util.LogName, replay.StatusKey(gameID))
+	if err != nil || len(rows) == 0 {
+		return replay.NoStatus
+	}
+	status, _ := replay.ParseStatus(string(rows[0].Value))
+	return status
+}
+
+// Returns true if the user is watching the current game without a seat, either at the watch spot or because they never sat down
+func IsSpectator(u *UIState) bool {
+	return u.CurPlayerIndex < 0 || u.CurPlayerIndex >= len(u.CurTable.GetPlayers())
+}
+
 type DiscStruct struct {
 	SettingsAddr  string
 	LogAddr       string
@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // view handles the loading of new UI screens.
-// Currently supported screens: Opening, Table, Pass, Take, Play, Score
+// Currently supported screens: Opening, Table, Pass, Take, Play, Score, Profile, Stats
 // Future support: All screens part of the discovery process
 
 package view
@@ -11,10 +11,14 @@ package view
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"hearts/history"
 	"hearts/img/coords"
 	"hearts/img/direction"
 	"hearts/img/reposition"
@@ -22,6 +26,7 @@ import (
 	"hearts/img/texture"
 	"hearts/img/uistate"
 	"hearts/logic/card"
+	"hearts/replay"
 	"hearts/util"
 
 	"golang.org/x/mobile/exp/f32"
@@ -44,6 +49,10 @@ func ReloadView(u *uistate.UIState) {
 		LoadPlayView(true, u)
 	case uistate.Split:
 		LoadSplitView(true, u)
+	case uistate.Profile:
+		LoadProfileView(u)
+	case uistate.Stats:
+		LoadStatsView(u)
 	}
 }
 
@@ -63,17 +72,14 @@ func LoadArrangeView(u *uistate.UIState) {
 		arrangeBlockLength = u.WindowSize.Y - u.CardDim.Y
 	}
 	arrangeDim := coords.MakeVec(arrangeBlockLength/3-4*u.Padding, arrangeBlockLength/3-4*u.Padding)
-	// player 0 seat
-	addArrangePlayer(0, arrangeDim, arrangeBlockLength, u)
-	// player 1 seat
-	addArrangePlayer(1, arrangeDim, arrangeBlockLength, u)
-	// player 2 seat
-	addArrangePlayer(2, arrangeDim, arrangeBlockLength, u)
-	// player 3 seat
-	addArrangePlayer(3, arrangeDim, arrangeBlockLength, u)
 	// table
 	watchPos := coords.MakeVec((u.WindowSize.X-arrangeDim.X)/2, (u.WindowSize.Y+arrangeBlockLength)/2-2*arrangeDim.Y-4*u.Padding)
+	// seats, around the table
+	for player := 0; player < u.NumPlayers; player++ {
+		addArrangePlayer(player, watchPos.PlusVec(arrangeDim.DividedBy(2)), arrangeDim, u)
+	}
 	u.Buttons["joinTable"] = texture.MakeImgWithAlt(watchImg, watchAlt, watchPos, arrangeDim, true, u)
+	addArrangeSpectators(u)
 	quitImg := u.Texs["QuitUnpressed.png"]
 	quitAlt := u.Texs["QuitPressed.png"]
 	quitDim := u.CardDim
@@ -126,6 +132,17 @@ func LoadDiscoveryView(u *uistate.UIState) {
 	newGameDim := coords.MakeVec(2*u.CardDim.X, u.CardDim.Y)
 	newGamePos := coords.MakeVec((u.WindowSize.X-newGameDim.X)/2, u.TopPadding)
 	u.Buttons["newGame"] = texture.MakeImgWithAlt(newGameImg, newGameAlt, newGamePos, newGameDim, true, u)
+	// the user's own avatar opens their profile
+	if avatar, ok := u.UserData[util.UserID][uistate.Avatar].(string); ok {
+		profilePos := coords.MakeVec(u.Padding, u.TopPadding)
+		u.Buttons["profile"] = texture.MakeImgWithAlt(u.Texs[avatar], u.Texs[avatar], profilePos, u.CardDim, true, u)
+	}
+	statsDim := coords.MakeVec(u.CardDim.X+2*u.Padding, u.CardDim.Y/2)
+	statsPos := coords.MakeVec(u.WindowSize.X-u.Padding-statsDim.X, u.TopPadding+(u.CardDim.Y-statsDim.Y)/2)
+	u.Buttons["stats"] = texture.MakeImgWithAlt(u.Texs["RoundedRectangle-DBlue.png"], u.Texs["RoundedRectangle-LBlue.png"], statsPos, statsDim, true, u)
+	statsCenter := statsPos.PlusVec(statsDim.DividedBy(2))
+	u.BackgroundImgs = append(u.BackgroundImgs,
+		texture.MakeStringImgCenterAlign("Stats", "", "", true, statsCenter, 8, statsDim.X, u)...)
 	buttonNum := 1
 	file, err := os.OpenFile(util.AddrFile, os.O_RDONLY|os.O_CREATE, 0666)
 	if err != nil {
@@ -136,12 +153,13 @@ func LoadDiscoveryView(u *uistate.UIState) {
 	scanner.Scan()
 	addr := scanner.Text()
 	// Search through all the database's syncgroups to see if addr represents a current syncgroup
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	allAddrs, _ := db.GetSyncgroupNames(u.Ctx)
+	allAddrs, _ := u.Store.Syncgroups()
 	fmt.Println(allAddrs)
 	for _, a := range allAddrs {
-		if a == addr {
+		tmp := strings.Split(a, "-")
+		gameID, _ := strconv.Atoi(tmp[len(tmp)-1])
+		// games which have ended can't be rejoined, while paused games can be rejoined to resume them
+		if a == addr && !uistate.GetGameStatus(gameID, u).Over() {
 			oldAddr = a
 		}
 	}
@@ -159,10 +177,11 @@ func LoadDiscoveryView(u *uistate.UIState) {
 		buttonNum = 2
 	}
 	for _, d := range u.DiscGroups {
-		if d != nil {
+		if d != nil && !uistate.GetGameStatus(int(d.GameStartData["gameID"].(float64)), u).Over() {
 			dataMap := d.GameStartData
 			creatorID := int(dataMap["ownerID"].(float64))
-			if u.UserData[creatorID] != nil {
+			numPlayers, seated := advertisedNumPlayers(dataMap)
+			if u.UserData[creatorID] != nil && seated {
 				bgBannerDim := coords.MakeVec(u.WindowSize.X, u.CardDim.Y+(4*u.Padding/5))
 				bgBannerPos := coords.MakeVec(0, newGamePos.Y+float32(buttonNum)*(bgBannerDim.Y+u.Padding))
 				playerIconImg := u.Texs[u.UserData[creatorID]["avatar"].(string)]
@@ -172,6 +191,15 @@ func LoadDiscoveryView(u *uistate.UIState) {
 					texture.MakeImgWithoutAlt(playerIconImg, playerIconPos, playerIconDim, u))
 				creatorName := u.UserData[creatorID]["name"].(string)
 				gameText := creatorName + "'s game"
+				if uistate.GetGameStatus(int(dataMap["gameID"].(float64)), u) == replay.Paused {
+					gameText += " (paused)"
+				}
+				if numPlayers != uistate.ClassicNumPlayers {
+					gameText += fmt.Sprintf(" for %d", numPlayers)
+				}
+				if turnLimit, ok := dataMap["turnLimit"].(float64); ok && turnLimit > 0 {
+					gameText += fmt.Sprintf(": %d sec moves", int(turnLimit))
+				}
 				scaler := float32(6)
 				maxWidth := u.WindowSize.X - 3*u.CardDim.X - 4*u.Padding
 				left := coords.MakeVec(playerIconPos.X+playerIconDim.X+u.Padding, playerIconPos.Y+playerIconDim.Y/2-10)
@@ -184,7 +212,7 @@ func LoadDiscoveryView(u *uistate.UIState) {
 				joinGamePos := coords.MakeVec(u.WindowSize.X-u.BottomPadding-newGameDim.X, newGamePos.Y+float32(buttonNum)*(bgBannerDim.Y+u.Padding))
 				u.Buttons[fmt.Sprintf("joinGame-%d", buttonNum)] = texture.MakeImgWithAlt(joinGameImg, joinGameAlt, joinGamePos, newGameDim, true, u)
 				creator := creatorName == util.UserName
-				bInfo := d.LogAddr + "|" + strconv.FormatBool(creator)
+				bInfo := d.LogAddr + "|" + strconv.FormatBool(creator) + "|" + strconv.Itoa(numPlayers)
 				u.Buttons[fmt.Sprintf("joinGame-%d", buttonNum)].SetInfo(bInfo)
 				buttonNum++
 			}
@@ -192,6 +220,171 @@ func LoadDiscoveryView(u *uistate.UIState) {
 	}
 }
 
+// Returns the number of players a game is advertised for in its game start data, the classic number if it doesn't say,
+// and false if it is for a number no table seats
+func advertisedNumPlayers(dataMap map[string]interface{}) (int, bool) {
+	v, ok := dataMap["numPlayers"]
+	if !ok {
+		return uistate.ClassicNumPlayers, true
+	}
+	n, ok := v.(float64)
+	if !ok || n != math.Trunc(n) || n < util.MinPlayers || n > util.MaxPlayers {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// Profile view: Lets the user choose their display name, avatar and color
+// The name is typed on a keyboard of letter textures, with the first letter of each word capitalized
+func LoadProfileView(u *uistate.UIState) {
+	u.M.Lock()
+	defer u.M.Unlock()
+	reposition.ResetAnims(u)
+	resetImgs(u)
+	resetScene(u)
+	u.CurView = uistate.Profile
+	addHeader(u)
+	settings := u.UserData[util.UserID]
+	name, _ := settings[uistate.Name].(string)
+	avatar, _ := settings[uistate.Avatar].(string)
+	color, _ := settings[uistate.Color].(int)
+	if f, ok := settings[uistate.Color].(float64); ok {
+		// settings read back from the store decode numbers as floats
+		color = int(f)
+	}
+	quitPos := coords.MakeVec(u.Padding, u.TopPadding+10)
+	u.Buttons["exit"] = texture.MakeImgWithAlt(u.Texs["QuitUnpressed.png"], u.Texs["QuitPressed.png"], quitPos, u.CardDim, true, u)
+	// the current avatar on a swatch of the current color, and the name below it
+	avatarDim := u.CardDim.Times(2)
+	avatarPos := coords.MakeVec((u.WindowSize.X-avatarDim.X)/2, u.TopPadding+10)
+	if colorTex, ok := u.Texs[texture.ColorTexKey(color)]; ok {
+		u.BackgroundImgs = append(u.BackgroundImgs,
+			texture.MakeImgWithoutAlt(colorTex, avatarPos.Minus(u.Padding), avatarDim.Plus(2*u.Padding), u))
+	}
+	u.BackgroundImgs = append(u.BackgroundImgs, texture.MakeImgWithoutAlt(u.Texs[avatar], avatarPos, avatarDim, u))
+	nameCenter := coords.MakeVec(u.WindowSize.X/2, avatarPos.Y+avatarDim.Y+3*u.Padding)
+	maxWidth := u.WindowSize.X - 2*u.Padding
+	u.BackgroundImgs = append(u.BackgroundImgs,
+		texture.MakeStringImgCenterAlign(name, "", "", true, nameCenter, 4, maxWidth, u)...)
+	// a row of avatars then a row of colors, each highlighting the current choice
+	choiceSize := (u.WindowSize.X - float32(len(uistate.Avatars)+1)*u.Padding) / float32(len(uistate.Avatars))
+	if choiceSize > u.CardDim.X {
+		choiceSize = u.CardDim.X
+	}
+	choiceDim := coords.MakeVec(choiceSize, choiceSize)
+	rowWidth := float32(len(uistate.Avatars))*(choiceSize+u.Padding) - u.Padding
+	avatarRowPos := coords.MakeVec((u.WindowSize.X-rowWidth)/2, nameCenter.Y+6*u.Padding)
+	for i, a := range uistate.Avatars {
+		pos := coords.MakeVec(avatarRowPos.X+float32(i)*(choiceSize+u.Padding), avatarRowPos.Y)
+		if a == avatar {
+			u.BackgroundImgs = append(u.BackgroundImgs,
+				texture.MakeImgWithoutAlt(u.Texs["RoundedRectangle-LBlue.png"], pos.Minus(u.Padding/2), choiceDim.Plus(u.Padding), u))
+		}
+		u.Buttons[fmt.Sprintf("avatar-%d", i)] = texture.MakeImgWithAlt(u.Texs[a], u.Texs[a], pos, choiceDim, true, u)
+	}
+	colorRowPos := coords.MakeVec(avatarRowPos.X, avatarRowPos.Y+choiceSize+2*u.Padding)
+	for i, c := range uistate.Colors {
+		pos := coords.MakeVec(colorRowPos.X+float32(i)*(choiceSize+u.Padding), colorRowPos.Y)
+		if c == color {
+			u.BackgroundImgs = append(u.BackgroundImgs,
+				texture.MakeImgWithoutAlt(u.Texs["RoundedRectangle-DBlue.png"], pos.Minus(u.Padding/2), choiceDim.Plus(u.Padding), u))
+		}
+		tex := u.Texs[texture.ColorTexKey(c)]
+		u.Buttons[fmt.Sprintf("color-%d", i)] = texture.MakeImgWithAlt(tex, tex, pos, choiceDim, true, u)
+	}
+	addKeyboard(colorRowPos.Y+choiceSize+3*u.Padding, u)
+}
+
+// Adds a keyboard for typing a name, starting at top: three rows of letters, the last ending with a backspace key,
+// and a space bar below them
+func addKeyboard(top float32, u *uistate.UIState) {
+	rows := []string{"QWERTYUIOP", "ASDFGHJKL", "ZXCVBNM"}
+	keySize := (u.WindowSize.X - float32(len(rows[0])+1)*u.Padding) / float32(len(rows[0]))
+	if keySize > u.CardDim.X {
+		keySize = u.CardDim.X
+	}
+	keyDim := coords.MakeVec(keySize, keySize)
+	for r, row := range rows {
+		keys := len(row)
+		if r == len(rows)-1 {
+			keys++
+		}
+		left := (u.WindowSize.X - float32(keys)*(keySize+u.Padding) + u.Padding) / 2
+		y := top + float32(r)*(keySize+u.Padding)
+		for i, letter := range row {
+			pos := coords.MakeVec(left+float32(i)*(keySize+u.Padding), y)
+			img := u.Texs[fmt.Sprintf("%c-Upper-DBlue.png", letter)]
+			alt := u.Texs[fmt.Sprintf("%c-Upper-LBlue.png", letter)]
+			u.Buttons[fmt.Sprintf("key-%c", letter)] = texture.MakeImgWithAlt(img, alt, pos, keyDim, true, u)
+		}
+		if r == len(rows)-1 {
+			pos := coords.MakeVec(left+float32(len(row))*(keySize+u.Padding), y)
+			u.Buttons["backspace"] = texture.MakeImgWithAlt(u.Texs["LeftArrowBlue.png"], u.Texs["LeftArrowGray.png"], pos, keyDim, true, u)
+		}
+	}
+	spaceDim := coords.MakeVec(5*keySize, keySize)
+	spacePos := coords.MakeVec((u.WindowSize.X-spaceDim.X)/2, top+float32(len(rows))*(keySize+u.Padding))
+	u.Buttons["space"] = texture.MakeImgWithAlt(u.Texs["RoundedRectangle-DBlue.png"], u.Texs["RoundedRectangle-LBlue.png"], spacePos, spaceDim, true, u)
+}
+
+// the number of games listed in the stats view
+const maxRecentGames = 5
+
+// Stats view: Displays the user's statistics across the games in this device's history, and their most recent games
+func LoadStatsView(u *uistate.UIState) {
+	u.M.Lock()
+	defer u.M.Unlock()
+	reposition.ResetAnims(u)
+	resetImgs(u)
+	resetScene(u)
+	u.CurView = uistate.Stats
+	addHeader(u)
+	quitPos := coords.MakeVec(u.Padding, u.TopPadding+10)
+	u.Buttons["exit"] = texture.MakeImgWithAlt(u.Texs["QuitUnpressed.png"], u.Texs["QuitPressed.png"], quitPos, u.CardDim, true, u)
+	games, err := history.New(u.Store, util.SettingsName).Games()
+	if err != nil {
+		fmt.Println("History error:", err)
+	}
+	stats := history.UserStats(games, util.UserID)
+	lines := []string{
+		fmt.Sprintf("Games played: %d", stats.GamesPlayed),
+		fmt.Sprintf("Won: %d of %d", stats.GamesWon, stats.GamesPlayed),
+		fmt.Sprintf("Points per round: %.1f", stats.AveragePoints()),
+		fmt.Sprintf("Queens of Spades: %d", stats.Queens),
+		fmt.Sprintf("Moons shot: %d", stats.MoonShots),
+		"",
+		"Recent games",
+	}
+	recent := 0
+	for _, g := range games {
+		seat := g.Seat(util.UserID)
+		if seat < 0 {
+			continue
+		}
+		result := "Lost"
+		if g.Won(seat) {
+			result = "Won"
+		}
+		minutes := int(g.Duration().Minutes() + 0.5)
+		lines = append(lines, fmt.Sprintf("%s with %d points in %d min", result, g.Scores()[seat], minutes))
+		recent++
+		if recent == maxRecentGames {
+			break
+		}
+	}
+	if recent == 0 {
+		lines = append(lines, "None yet")
+	}
+	top := u.TopPadding + u.CardDim.Y + 4*u.Padding
+	maxWidth := u.WindowSize.X - 4*u.Padding
+	scaler := float32(5)
+	for i, line := range lines {
+		left := coords.MakeVec(2*u.Padding, top+float32(i)*u.CardDim.Y/2)
+		u.BackgroundImgs = append(u.BackgroundImgs,
+			texture.MakeStringImgLeftAlign(line, "", "", true, left, scaler, maxWidth, u)...)
+	}
+}
+
 // Table View: Displays the table. Intended for public devices
 func LoadTableView(u *uistate.UIState) {
 	u.M.Lock()
@@ -202,178 +395,48 @@ func LoadTableView(u *uistate.UIState) {
 	u.CurView = uistate.Table
 	scaler := float32(6)
 	maxWidth := 4 * u.TableCardDim.X
-	// adding four drop targets for trick
+	// adding a drop target for each player's card in the trick, around the middle of the table
 	dropTargetImage := u.Texs["trickDrop.png"]
 	dropTargetAlt := u.Texs["trickDropBlue.png"]
 	dropTargetDimensions := u.CardDim
-	dropTargetX := (u.WindowSize.X - u.CardDim.X) / 2
-	dropTargetY := u.WindowSize.Y/2 + u.CardDim.Y/2 + u.Padding
-	dropTargetPos := coords.MakeVec(dropTargetX, dropTargetY)
-	u.DropTargets = append(u.DropTargets,
-		texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u))
-	// take trick button
+	tableCenter := u.WindowSize.DividedBy(2)
+	dropTargetRadius := u.CardDim.Plus(u.Padding)
+	for i, dropCard := range u.CurTable.GetTrick() {
+		dropTargetPos := reposition.SeatTargetPos(i, u.NumPlayers, tableCenter, dropTargetRadius, dropTargetDimensions)
+		u.DropTargets = append(u.DropTargets,
+			texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u))
+		// card on top of the drop target
+		if dropCard != nil {
+			texture.PopulateCardImage(dropCard, u)
+			dropCard.SetInitial(dropTargetPos)
+			dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
+			u.Cards = append(u.Cards, dropCard)
+		}
+	}
+	// take trick button, above the first drop target
 	takeTrickImage := u.Texs["TakeTrickTableUnpressed.png"]
 	takeTrickAlt := u.Texs["TakeTrickTablePressed.png"]
 	takeTrickDim := coords.MakeVec(u.CardDim.X, u.CardDim.Y)
-	takeTrickPos := coords.MakeVec(dropTargetX, dropTargetY-u.Padding-takeTrickDim.Y)
+	firstTargetPos := u.DropTargets[0].GetCurrent()
+	takeTrickPos := coords.MakeVec(firstTargetPos.X, firstTargetPos.Y-u.Padding-takeTrickDim.Y)
 	u.Buttons["takeTrick"] = texture.MakeImgWithAlt(takeTrickImage, takeTrickAlt, takeTrickPos, takeTrickDim, true, u)
-	if !u.CurTable.TrickOver() {
+	// spectators can't take tricks for the players
+	if !u.CurTable.TrickOver() || uistate.IsSpectator(u) {
 		var emptyTex sprite.SubTex
 		u.Eng.SetSubTex(u.Buttons["takeTrick"].GetNode(), emptyTex)
 		u.Buttons["takeTrick"].SetHidden(true)
 	}
-	// card on top of first drop target
-	dropCard := u.CurTable.GetTrick()[0]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		u.Cards = append(u.Cards, dropCard)
-	}
-	// second drop target
-	dropTargetY = (u.WindowSize.Y - u.CardDim.Y) / 2
-	dropTargetX = u.WindowSize.X/2 - 3*u.CardDim.X/2 - u.Padding
-	dropTargetPos = coords.MakeVec(dropTargetX, dropTargetY)
-	u.DropTargets = append(u.DropTargets,
-		texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u))
-	// card on top of second drop target
-	dropCard = u.CurTable.GetTrick()[1]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		u.Cards = append(u.Cards, dropCard)
-	}
-	// third drop target
-	dropTargetX = (u.WindowSize.X - u.CardDim.X) / 2
-	dropTargetY = u.WindowSize.Y/2 - 3*u.CardDim.Y/2 - u.Padding
-	dropTargetPos = coords.MakeVec(dropTargetX, dropTargetY)
-	u.DropTargets = append(u.DropTargets,
-		texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u))
-	// card on top of third drop target
-	dropCard = u.CurTable.GetTrick()[2]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		u.Cards = append(u.Cards, dropCard)
-	}
-	// fourth drop target
-	dropTargetY = (u.WindowSize.Y - u.CardDim.Y) / 2
-	dropTargetX = u.WindowSize.X/2 + u.CardDim.X/2 + u.Padding
-	dropTargetPos = coords.MakeVec(dropTargetX, dropTargetY)
-	u.DropTargets = append(u.DropTargets,
-		texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u))
-	// card on top of fourth drop target
-	dropCard = u.CurTable.GetTrick()[3]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		u.Cards = append(u.Cards, dropCard)
-	}
 	// number of tricks each player has taken
 	SetNumTricksTable(u)
-	// adding 4 player icons, text, and device icons
-	playerIconImage := uistate.GetAvatar(0, u)
-	playerIconX := (u.WindowSize.X - u.PlayerIconDim.X) / 2
-	playerIconY := u.WindowSize.Y - u.TableCardDim.Y - u.BottomPadding - u.Padding - u.PlayerIconDim.Y
-	playerIconPos := coords.MakeVec(playerIconX, playerIconY)
-	if u.Debug {
-		u.Buttons["player0"] = texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u)
-	} else {
-		u.BackgroundImgs = append(u.BackgroundImgs,
-			texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u))
-	}
-	// player 0's name
-	center := coords.MakeVec(playerIconX+u.PlayerIconDim.X/2, playerIconY-15)
-	name := uistate.GetName(0, u)
-	textImgs := texture.MakeStringImgCenterAlign(name, "", "", true, center, scaler, maxWidth, u)
-	for _, img := range textImgs {
-		u.BackgroundImgs = append(u.BackgroundImgs, img)
-	}
-	// player 0's device icon
-	deviceIconImage := uistate.GetDevice(0, u)
-	deviceIconDim := u.PlayerIconDim.DividedBy(2)
-	deviceIconPos := coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X, playerIconPos.Y)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(deviceIconImage, deviceIconPos, deviceIconDim, u))
-	// player 1's icon
-	playerIconImage = uistate.GetAvatar(1, u)
-	playerIconX = u.BottomPadding
-	playerIconY = (u.WindowSize.Y+2*u.BottomPadding+u.PlayerIconDim.Y-
-		(float32(len(u.CurTable.GetPlayers()[1].GetHand()))*
-			(u.TableCardDim.Y-u.Overlap.Y)+u.TableCardDim.Y))/2 -
-		u.PlayerIconDim.Y - u.Padding
-	playerIconPos = coords.MakeVec(playerIconX, playerIconY)
-	if u.Debug {
-		u.Buttons["player1"] = texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u)
-	} else {
-		u.BackgroundImgs = append(u.BackgroundImgs,
-			texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u))
-	}
-	// player 1's name
-	start := coords.MakeVec(playerIconX, playerIconY-15)
-	name = uistate.GetName(1, u)
-	textImgs = texture.MakeStringImgLeftAlign(name, "", "", true, start, scaler, maxWidth, u)
-	for _, img := range textImgs {
-		u.BackgroundImgs = append(u.BackgroundImgs, img)
-	}
-	// player 1's device icon
-	deviceIconImage = uistate.GetDevice(1, u)
-	deviceIconPos = coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X, playerIconPos.Y+u.PlayerIconDim.Y-deviceIconDim.Y)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(deviceIconImage, deviceIconPos, deviceIconDim, u))
-	// player 2's icon
-	playerIconImage = uistate.GetAvatar(2, u)
-	playerIconX = (u.WindowSize.X - u.PlayerIconDim.X) / 2
-	playerIconY = u.TopPadding + u.TableCardDim.Y + u.Padding
-	playerIconPos = coords.MakeVec(playerIconX, playerIconY)
-	if u.Debug {
-		u.Buttons["player2"] = texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u)
-	} else {
-		u.BackgroundImgs = append(u.BackgroundImgs,
-			texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u))
-	}
-	// player 2's name
-	center = coords.MakeVec(playerIconX+u.PlayerIconDim.X/2, playerIconY+u.PlayerIconDim.Y)
-	name = uistate.GetName(2, u)
-	textImgs = texture.MakeStringImgCenterAlign(name, "", "", true, center, scaler, maxWidth, u)
-	for _, img := range textImgs {
-		u.BackgroundImgs = append(u.BackgroundImgs, img)
-	}
-	// player 2's device icon
-	deviceIconImage = uistate.GetDevice(2, u)
-	deviceIconPos = coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X, playerIconPos.Y+u.PlayerIconDim.Y-deviceIconDim.Y)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(deviceIconImage, deviceIconPos, deviceIconDim, u))
-	// player 3's icon
-	playerIconImage = uistate.GetAvatar(3, u)
-	playerIconX = u.WindowSize.X - u.BottomPadding - u.PlayerIconDim.X
-	playerIconY = (u.WindowSize.Y+2*u.BottomPadding+u.PlayerIconDim.Y-
-		(float32(len(u.CurTable.GetPlayers()[3].GetHand()))*
-			(u.TableCardDim.Y-u.Overlap.Y)+u.TableCardDim.Y))/2 -
-		u.PlayerIconDim.Y - u.Padding
-	playerIconPos = coords.MakeVec(playerIconX, playerIconY)
-	if u.Debug {
-		u.Buttons["player3"] = texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u)
-	} else {
-		u.BackgroundImgs = append(u.BackgroundImgs,
-			texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u))
+	// adding player icons, text, and device icons
+	for i := range u.CurTable.GetPlayers() {
+		addTablePlayer(i, scaler, maxWidth, u)
 	}
-	// player 3's name
-	end := coords.MakeVec(playerIconX+u.PlayerIconDim.X, playerIconY-15)
-	name = uistate.GetName(3, u)
-	textImgs = texture.MakeStringImgRightAlign(name, "", "", true, end, scaler, maxWidth, u)
-	for _, img := range textImgs {
-		u.BackgroundImgs = append(u.BackgroundImgs, img)
+	// adding cards, face down unless a spectator has chosen to see them after the game
+	godView := uistate.IsSpectator(u) && u.GameStatus.Over()
+	if godView {
+		addGodViewButton(u)
 	}
-	// player 3's device icon
-	deviceIconImage = uistate.GetDevice(3, u)
-	deviceIconPos = coords.MakeVec(playerIconPos.X-deviceIconDim.X, playerIconPos.Y+u.PlayerIconDim.Y-deviceIconDim.Y)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(deviceIconImage, deviceIconPos, deviceIconDim, u))
-	// adding cards
 	for _, p := range u.CurTable.GetPlayers() {
 		// cards in hand
 		hand := p.GetHand()
@@ -381,21 +444,15 @@ func LoadTableView(u *uistate.UIState) {
 			texture.PopulateCardImage(c, u)
 			cardIndex := coords.MakeVec(float32(len(hand)), float32(i))
 			reposition.SetCardPositionTable(c, p.GetPlayerIndex(), cardIndex, u)
-			u.Eng.SetSubTex(c.GetNode(), c.GetBack())
+			if !godView || !u.GodView {
+				u.Eng.SetSubTex(c.GetNode(), c.GetBack())
+			}
 			u.TableCards = append(u.TableCards, c)
 		}
 		// cards that have been passed
 		passed := p.GetPassedTo()
 		for i, c := range passed {
-			var passer int
-			switch u.CurTable.GetDir() {
-			case direction.Right:
-				passer = (p.GetPlayerIndex() + 1) % u.NumPlayers
-			case direction.Across:
-				passer = (p.GetPlayerIndex() + 2) % u.NumPlayers
-			case direction.Left:
-				passer = (p.GetPlayerIndex() + 3) % u.NumPlayers
-			}
+			passer := u.CurTable.GetPassSender(p.GetPlayerIndex())
 			cardIndexVec := coords.MakeVec(float32(len(hand)+len(passed)), float32(len(hand)+i))
 			initial := reposition.CardPositionTable(passer, cardIndexVec, u)
 			c.SetInitial(initial)
@@ -414,6 +471,77 @@ func LoadTableView(u *uistate.UIState) {
 	reposition.SetTableDropColors(u)
 }
 
+// Adds the icon, name and device icon of the player at playerIndex beside their hand in the table view
+func addTablePlayer(playerIndex int, scaler, maxWidth float32, u *uistate.UIState) {
+	edge, start, length := reposition.TableSeat(playerIndex, u)
+	handLength := float32(len(u.CurTable.GetPlayers()[playerIndex].GetHand()))*(u.TableCardDim.Y-u.Overlap.Y) + u.TableCardDim.Y
+	// icons at the sides go above the player's hand, which is centered in their stretch of the edge
+	sideIconY := start + (length+2*u.BottomPadding+u.PlayerIconDim.Y-handLength)/2 - u.PlayerIconDim.Y - u.Padding
+	var playerIconPos *coords.Vec
+	switch edge {
+	case direction.Down:
+		playerIconPos = coords.MakeVec(start+(length-u.PlayerIconDim.X)/2,
+			u.WindowSize.Y-u.TableCardDim.Y-u.BottomPadding-u.Padding-u.PlayerIconDim.Y)
+	case direction.Left:
+		playerIconPos = coords.MakeVec(u.BottomPadding, sideIconY)
+	case direction.Across:
+		playerIconPos = coords.MakeVec(start+(length-u.PlayerIconDim.X)/2, u.TopPadding+u.TableCardDim.Y+u.Padding)
+	case direction.Right:
+		playerIconPos = coords.MakeVec(u.WindowSize.X-u.BottomPadding-u.PlayerIconDim.X, sideIconY)
+	}
+	playerIconImage := uistate.GetAvatar(playerIndex, u)
+	if u.Debug {
+		u.Buttons[fmt.Sprintf("player%d", playerIndex)] = texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u)
+	} else {
+		u.BackgroundImgs = append(u.BackgroundImgs,
+			texture.MakeImgWithoutAlt(playerIconImage, playerIconPos, u.PlayerIconDim, u))
+	}
+	// name
+	name := uistate.GetName(playerIndex, u)
+	var textImgs []*staticimg.StaticImg
+	switch edge {
+	case direction.Down:
+		center := coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X/2, playerIconPos.Y-15)
+		textImgs = texture.MakeStringImgCenterAlign(name, "", "", true, center, scaler, maxWidth, u)
+	case direction.Left:
+		start := coords.MakeVec(playerIconPos.X, playerIconPos.Y-15)
+		textImgs = texture.MakeStringImgLeftAlign(name, "", "", true, start, scaler, maxWidth, u)
+	case direction.Across:
+		center := coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X/2, playerIconPos.Y+u.PlayerIconDim.Y)
+		textImgs = texture.MakeStringImgCenterAlign(name, "", "", true, center, scaler, maxWidth, u)
+	case direction.Right:
+		end := coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X, playerIconPos.Y-15)
+		textImgs = texture.MakeStringImgRightAlign(name, "", "", true, end, scaler, maxWidth, u)
+	}
+	u.BackgroundImgs = append(u.BackgroundImgs, textImgs...)
+	// device icon
+	deviceIconImage := uistate.GetDevice(playerIndex, u)
+	deviceIconDim := u.PlayerIconDim.DividedBy(2)
+	var deviceIconPos *coords.Vec
+	switch edge {
+	case direction.Down:
+		deviceIconPos = coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X, playerIconPos.Y)
+	case direction.Left, direction.Across:
+		deviceIconPos = coords.MakeVec(playerIconPos.X+u.PlayerIconDim.X, playerIconPos.Y+u.PlayerIconDim.Y-deviceIconDim.Y)
+	case direction.Right:
+		deviceIconPos = coords.MakeVec(playerIconPos.X-deviceIconDim.X, playerIconPos.Y+u.PlayerIconDim.Y-deviceIconDim.Y)
+	}
+	u.BackgroundImgs = append(u.BackgroundImgs,
+		texture.MakeImgWithoutAlt(deviceIconImage, deviceIconPos, deviceIconDim, u))
+}
+
+// Adds the button spectators use to show or hide every hand once the game is over
+func addGodViewButton(u *uistate.UIState) {
+	showImg := u.Texs["Visibility.png"]
+	hideImg := u.Texs["VisibilityOff.png"]
+	if u.GodView {
+		showImg, hideImg = hideImg, showImg
+	}
+	buttonDim := u.CardDim.DividedBy(2)
+	buttonPos := coords.MakeVec(u.WindowSize.X-buttonDim.X-u.Padding, u.TopPadding)
+	u.Buttons["godView"] = texture.MakeImgWithAlt(showImg, hideImg, buttonPos, buttonDim, true, u)
+}
+
 // Decides which view of the player's hand to load based on what steps of the round they have completed
 func LoadPassOrTakeOrPlay(u *uistate.UIState) {
 	if u.CurPlayerIndex >= 0 {
@@ -544,6 +672,24 @@ func LoadSplitView(reloading bool, u *uistate.UIState) {
 	}
 }
 
+// Records whether writes to the store are failing, and updates the header to show it
+// The play views only change their message. Other views are reloaded on going offline; they show the change back
+// with the next view, which follows straight after the write that succeeded
+func SetOffline(offline bool, u *uistate.UIState) {
+	if u.Offline == offline {
+		return
+	}
+	u.Offline = offline
+	switch u.CurView {
+	case uistate.Play, uistate.Split:
+		ChangePlayMessage(getTurnText(u), u)
+	default:
+		if offline {
+			ReloadView(u)
+		}
+	}
+}
+
 func ChangePlayMessage(message string, u *uistate.UIState) {
 	// remove text and replace with message
 	for _, img := range u.Other {
@@ -568,20 +714,13 @@ func ChangePlayMessage(message string, u *uistate.UIState) {
 	}
 }
 
-func addArrangePlayer(player int, arrangeDim *coords.Vec, arrangeBlockLength float32, u *uistate.UIState) {
+func addArrangePlayer(player int, tableCenter, arrangeDim *coords.Vec, u *uistate.UIState) {
 	sitImg := u.Texs["SitSpotUnpressed.png"]
 	sitAlt := u.Texs["SitSpotPressed.png"]
-	var sitPos *coords.Vec
-	switch player {
-	case 0:
-		sitPos = coords.MakeVec((u.WindowSize.X-arrangeDim.X)/2, (u.WindowSize.Y+arrangeBlockLength)/2-arrangeDim.Y-2*u.Padding)
-	case 1:
-		sitPos = coords.MakeVec((u.WindowSize.X-arrangeDim.X)/2-arrangeDim.X-2*u.Padding, (u.WindowSize.Y+arrangeBlockLength)/2-2*arrangeDim.Y-4*u.Padding)
-	case 2:
-		sitPos = coords.MakeVec((u.WindowSize.X-arrangeDim.X)/2, (u.WindowSize.Y+arrangeBlockLength)/2-3*arrangeDim.Y-6*u.Padding)
-	case 3:
-		sitPos = coords.MakeVec((u.WindowSize.X-arrangeDim.X)/2+arrangeDim.X+2*u.Padding, (u.WindowSize.Y+arrangeBlockLength)/2-2*arrangeDim.Y-4*u.Padding)
-	}
+	sitPos := reposition.SeatTargetPos(player, u.NumPlayers, tableCenter, arrangeDim.Plus(2*u.Padding), arrangeDim)
+	// names go above the seats along the top, and above the upper of two seats at a side, to clear the seat below
+	edge, slot, slots := direction.Seat(player, u.NumPlayers)
+	nameAbove := edge == direction.Across || slot < slots-1
 	if u.PlayerData[player] == 0 {
 		u.Buttons[fmt.Sprintf("joinPlayer-%d", player)] = texture.MakeImgWithAlt(sitImg, sitAlt, sitPos, arrangeDim, true, u)
 	} else {
@@ -589,7 +728,7 @@ func addArrangePlayer(player int, arrangeDim *coords.Vec, arrangeBlockLength flo
 		u.BackgroundImgs = append(u.BackgroundImgs, texture.MakeImgWithoutAlt(avatar, sitPos, arrangeDim, u))
 		name := uistate.GetName(player, u)
 		var center *coords.Vec
-		if player == 2 {
+		if nameAbove {
 			center = coords.MakeVec(sitPos.X+arrangeDim.X/2, sitPos.Y-u.Padding-10)
 		} else {
 			center = coords.MakeVec(sitPos.X+arrangeDim.X/2, sitPos.Y+arrangeDim.Y)
@@ -600,6 +739,39 @@ func addArrangePlayer(player int, arrangeDim *coords.Vec, arrangeBlockLength flo
 		for _, text := range textImgs {
 			u.BackgroundImgs = append(u.BackgroundImgs, text)
 		}
+		// the rating goes below the name, or below the avatar for the seat whose name is above it
+		ratingCenter := coords.MakeVec(center.X, center.Y+textHeight(textImgs))
+		if nameAbove {
+			ratingCenter = coords.MakeVec(center.X, sitPos.Y+arrangeDim.Y)
+		}
+		addRating(player, ratingCenter, scaler, maxWidth, u)
+	}
+}
+
+// Adds the avatars of the users watching the game along the bottom of the screen, apart from the seats
+func addArrangeSpectators(u *uistate.UIState) {
+	if len(u.Spectators) == 0 {
+		return
+	}
+	userIDs := make([]int, 0)
+	for userID := range u.Spectators {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+	iconDim := u.PlayerIconDim
+	iconY := u.WindowSize.Y - u.BottomPadding - iconDim.Y
+	scaler := float32(6)
+	maxWidth := u.WindowSize.X / 3
+	start := coords.MakeVec(u.Padding, iconY-u.Padding-10)
+	textImgs := texture.MakeStringImgLeftAlign("Watching", "", "", true, start, scaler, maxWidth, u)
+	u.BackgroundImgs = append(u.BackgroundImgs, textImgs...)
+	for i, userID := range userIDs {
+		avatar := u.Texs["Heart.png"]
+		if u.UserData[userID][uistate.Avatar] != nil {
+			avatar = u.Texs[u.UserData[userID][uistate.Avatar].(string)]
+		}
+		iconPos := coords.MakeVec(u.Padding+float32(i)*(iconDim.X+u.Padding), iconY)
+		u.BackgroundImgs = append(u.BackgroundImgs, texture.MakeImgWithoutAlt(avatar, iconPos, iconDim, u))
 	}
 }
 
@@ -610,110 +782,52 @@ func addSplitViewPlayerIcons(beforeSplitAnimation bool, u *uistate.UIState) {
 	dropTargetAlt := u.Texs["trickDropBlue.png"]
 	dropTargetDimensions := u.CardDim
 	playerIconDimensions := u.CardDim.Minus(4)
-	// first drop target
-	dropTargetX := (splitWindowSize.X - u.CardDim.X) / 2
-	dropTargetY := splitWindowSize.Y/2 + u.Padding
-	if beforeSplitAnimation {
-		dropTargetY -= topOfBanner
-	}
-	dropTargetPos := coords.MakeVec(dropTargetX, dropTargetY)
-	d := texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u)
-	u.DropTargets = append(u.DropTargets, d)
-	// 'unplayed' border
-	borderImage := u.Texs["UnplayedBorder1.png"]
-	borderAlt := u.Texs["UnplayedBorder2.png"]
-	borderDim := dropTargetDimensions.Plus(2)
-	borderPos := dropTargetPos.Minus(1)
-	b := texture.MakeImgWithAlt(borderImage, borderAlt, borderPos, borderDim, true, u)
-	u.BackgroundImgs = append(u.BackgroundImgs, b)
-	if u.CardToPlay == nil {
-		var emptyTex sprite.SubTex
-		u.Eng.SetSubTex(b.GetNode(), emptyTex)
-		b.SetHidden(true)
-	}
-	// first player icon
-	playerIconImage := uistate.GetAvatar(u.CurPlayerIndex, u)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(playerIconImage, dropTargetPos.Plus(2), playerIconDimensions, u))
-	// card on top of first drop target
-	dropCard := u.CurTable.GetTrick()[u.CurPlayerIndex]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		d.SetCardHere(dropCard)
-		u.TableCards = append(u.TableCards, dropCard)
-	}
-	// second drop target
-	dropTargetY = (splitWindowSize.Y - u.CardDim.Y) / 2
+	// drop targets go around the middle of the split window, starting with this player's at the bottom
+	center := splitWindowSize.DividedBy(2)
 	if beforeSplitAnimation {
-		dropTargetY -= topOfBanner
-	}
-	dropTargetX = splitWindowSize.X/2 - 3*u.CardDim.X/2 - u.Padding
-	dropTargetPos = coords.MakeVec(dropTargetX, dropTargetY)
-	d = texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u)
-	u.DropTargets = append(u.DropTargets, d)
-	// second player icon
-	playerIconImage = uistate.GetAvatar((u.CurPlayerIndex+1)%u.NumPlayers, u)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(playerIconImage, dropTargetPos.Plus(2), playerIconDimensions, u))
-	// card on top of second drop target
-	dropCard = u.CurTable.GetTrick()[(u.CurPlayerIndex+1)%len(u.CurTable.GetPlayers())]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		d.SetCardHere(dropCard)
-		u.TableCards = append(u.TableCards, dropCard)
-	}
-	// third drop target
-	dropTargetX = (splitWindowSize.X - u.CardDim.X) / 2
-	dropTargetY = splitWindowSize.Y/2 - u.Padding - u.CardDim.Y
-	if beforeSplitAnimation {
-		dropTargetY -= topOfBanner
+		center = coords.MakeVec(center.X, center.Y-topOfBanner)
 	}
-	dropTargetPos = coords.MakeVec(dropTargetX, dropTargetY)
-	d = texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u)
-	u.DropTargets = append(u.DropTargets, d)
-	// third player icon
-	playerIconImage = uistate.GetAvatar((u.CurPlayerIndex+2)%u.NumPlayers, u)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(playerIconImage, dropTargetPos.Plus(2), playerIconDimensions, u))
-	// card on top of third drop target
-	dropCard = u.CurTable.GetTrick()[(u.CurPlayerIndex+2)%len(u.CurTable.GetPlayers())]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		d.SetCardHere(dropCard)
-		u.TableCards = append(u.TableCards, dropCard)
-	}
-	// fourth drop target
-	dropTargetY = (splitWindowSize.Y - u.CardDim.Y) / 2
-	if beforeSplitAnimation {
-		dropTargetY -= topOfBanner
-	}
-	dropTargetX = splitWindowSize.X/2 + u.CardDim.X/2 + u.Padding
-	dropTargetPos = coords.MakeVec(dropTargetX, dropTargetY)
-	d = texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u)
-	u.DropTargets = append(u.DropTargets, d)
-	// fourth player icon
-	playerIconImage = uistate.GetAvatar((u.CurPlayerIndex+3)%u.NumPlayers, u)
-	u.BackgroundImgs = append(u.BackgroundImgs,
-		texture.MakeImgWithoutAlt(playerIconImage, dropTargetPos.Plus(2), playerIconDimensions, u))
-	// card on top of fourth drop target
-	dropCard = u.CurTable.GetTrick()[(u.CurPlayerIndex+3)%len(u.CurTable.GetPlayers())]
-	if dropCard != nil {
-		texture.PopulateCardImage(dropCard, u)
-		dropCard.SetInitial(dropTargetPos)
-		dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
-		d.SetCardHere(dropCard)
-		u.TableCards = append(u.TableCards, dropCard)
+	radius := coords.MakeVec(u.CardDim.X+u.Padding, u.CardDim.Y/2+u.Padding)
+	for i := 0; i < u.NumPlayers; i++ {
+		player := (u.CurPlayerIndex + i) % u.NumPlayers
+		dropTargetPos := reposition.SeatTargetPos(i, u.NumPlayers, center, radius, dropTargetDimensions)
+		d := texture.MakeImgWithAlt(dropTargetImage, dropTargetAlt, dropTargetPos, dropTargetDimensions, true, u)
+		u.DropTargets = append(u.DropTargets, d)
+		if i == 0 {
+			// 'unplayed' border
+			borderImage := u.Texs["UnplayedBorder1.png"]
+			borderAlt := u.Texs["UnplayedBorder2.png"]
+			borderDim := dropTargetDimensions.Plus(2)
+			borderPos := dropTargetPos.Minus(1)
+			b := texture.MakeImgWithAlt(borderImage, borderAlt, borderPos, borderDim, true, u)
+			u.BackgroundImgs = append(u.BackgroundImgs, b)
+			if u.CardToPlay == nil {
+				var emptyTex sprite.SubTex
+				u.Eng.SetSubTex(b.GetNode(), emptyTex)
+				b.SetHidden(true)
+			}
+		}
+		// player icon
+		playerIconImage := uistate.GetAvatar(player, u)
+		u.BackgroundImgs = append(u.BackgroundImgs,
+			texture.MakeImgWithoutAlt(playerIconImage, dropTargetPos.Plus(2), playerIconDimensions, u))
+		// card on top of drop target
+		dropCard := u.CurTable.GetTrick()[player]
+		if dropCard != nil {
+			texture.PopulateCardImage(dropCard, u)
+			dropCard.SetInitial(dropTargetPos)
+			dropCard.Move(dropTargetPos, dropTargetDimensions, u.Eng)
+			d.SetCardHere(dropCard)
+			u.TableCards = append(u.TableCards, dropCard)
+		}
 	}
 }
 
 // returns a string which says whose turn it is
 func getTurnText(u *uistate.UIState) string {
+	if u.Offline {
+		return "Offline"
+	}
 	var turnText string
 	playerTurnNum := u.CurTable.WhoseTurn()
 	if playerTurnNum == -1 || !u.CurTable.AllDonePassing() || (u.SequentialPhases && !u.CurTable.AllDoneTaking()) {
@@ -743,23 +857,32 @@ func addHeader(u *uistate.UIState) {
 	headerDimensions := coords.MakeVec(u.WindowSize.X, u.TopPadding+float32(20))
 	u.BackgroundImgs = append(u.BackgroundImgs,
 		texture.MakeImgWithoutAlt(headerImage, headerPos, headerDimensions, u))
+	if u.Offline {
+		scaler := float32(5)
+		center := coords.MakeVec(u.WindowSize.X/2, 2)
+		maxWidth := u.WindowSize.X / 3
+		u.BackgroundImgs = append(u.BackgroundImgs,
+			texture.MakeStringImgCenterAlign("Offline", "DBlue", "DBlue", true, center, scaler, maxWidth, u)...)
+	}
+}
+
+// Returns the position and dimensions of the banner along the top of the play view, or above the hand in the split view
+func playHeaderRect(beforeSplitAnimation bool, u *uistate.UIState) (*coords.Vec, *coords.Vec) {
+	if u.CurView == uistate.Play || beforeSplitAnimation {
+		return coords.MakeVec(0, 0), coords.MakeVec(u.WindowSize.X, float32(50))
+	}
+	headerDimensions := coords.MakeVec(u.WindowSize.X, float32(40))
+	topOfHand := u.WindowSize.Y - 4*(u.CardDim.Y+u.Padding) - u.BottomPadding
+	return coords.MakeVec(0, topOfHand-headerDimensions.Y-u.Padding), headerDimensions
 }
 
 func addPlayHeader(message string, beforeSplitAnimation bool, u *uistate.UIState) {
 	// adding blue banner
 	headerImage := u.Texs["Rectangle-DBlue.png"]
-	var headerDimensions *coords.Vec
-	var headerPos *coords.Vec
-	if u.CurView == uistate.Play || beforeSplitAnimation {
-		headerDimensions = coords.MakeVec(u.WindowSize.X, float32(50))
-		headerPos = coords.MakeVec(0, 0)
-	} else {
-		headerDimensions = coords.MakeVec(u.WindowSize.X, float32(40))
-		topOfHand := u.WindowSize.Y - 4*(u.CardDim.Y+u.Padding) - u.BottomPadding
-		headerPos = coords.MakeVec(0, topOfHand-headerDimensions.Y-u.Padding)
-	}
+	headerPos, headerDimensions := playHeaderRect(beforeSplitAnimation, u)
 	u.Other = append(u.Other,
 		texture.MakeImgWithoutAlt(headerImage, headerPos, headerDimensions, u))
+	addCountdown(headerPos, headerDimensions, u)
 	// adding pull tab
 	pullTabImage := u.Texs["Visibility.png"]
 	pullTabAlt := u.Texs["VisibilityOff.png"]
@@ -791,6 +914,57 @@ func addPlayHeader(message string, beforeSplitAnimation bool, u *uistate.UIState
 	}
 }
 
+// Adds the time left for the current move to the left of the play header, if moves are timed
+func addCountdown(headerPos, headerDimensions *coords.Vec, u *uistate.UIState) {
+	u.Countdown = nil
+	if u.TurnLimit == 0 || u.TurnPlayer < 0 {
+		return
+	}
+	left := u.TurnLimit - time.Since(u.TurnStart)
+	if left < 0 {
+		left = 0
+	}
+	// the seconds left, rounded up; there are only plain digits in the header's colors
+	text := strconv.Itoa(int((left + time.Second - 1) / time.Second))
+	color := "DBlue"
+	if u.TurnPlayer == u.CurPlayerIndex && left < u.TurnLimit/4 {
+		color = "Red"
+	}
+	scaler := float32(4)
+	start := coords.MakeVec(2*u.Padding, headerPos.Y+headerDimensions.Y-30)
+	maxWidth := u.WindowSize.X / 5
+	u.Countdown = texture.MakeStringImgLeftAlign(text, color, color, true, start, scaler, maxWidth, u)
+	u.Other = append(u.Other, u.Countdown...)
+}
+
+// Redraws the time left for the current move in the play header, if the play or split view is showing
+func RefreshCountdown(u *uistate.UIState) {
+	u.M.Lock()
+	defer u.M.Unlock()
+	if u.CurView != uistate.Play && u.CurView != uistate.Split {
+		return
+	}
+	old := make(map[*staticimg.StaticImg]bool)
+	for _, img := range u.Countdown {
+		old[img] = true
+		if img.GetNode().Parent == u.Scene {
+			u.Scene.RemoveChild(img.GetNode())
+		}
+	}
+	other := make([]*staticimg.StaticImg, 0, len(u.Other))
+	for _, img := range u.Other {
+		if !old[img] {
+			other = append(other, img)
+		}
+	}
+	u.Other = other
+	headerPos, headerDimensions := playHeaderRect(false, u)
+	addCountdown(headerPos, headerDimensions, u)
+	for _, img := range u.Countdown {
+		reposition.BringNodeToFront(img.GetNode(), u)
+	}
+}
+
 func addPlaySlot(display bool, u *uistate.UIState) {
 	topOfHand := u.WindowSize.Y - 5*(u.CardDim.Y+u.Padding) - (2 * u.Padding / 5) - u.BottomPadding
 	// adding blue rectangle
@@ -837,20 +1011,17 @@ func addGrayPassBar(u *uistate.UIState) {
 	u.Other = append(u.Other,
 		texture.MakeImgWithAlt(grayBarImg, blueBarImg, grayBarPos, grayBarDim, true, u))
 	// adding name
-	var receivingPlayer int
+	receivingPlayer := u.CurTable.GetPassRecipient(u.CurPlayerIndex)
 	var arrowImg sprite.SubTex
 	var arrowAlt sprite.SubTex
 	switch u.CurTable.GetDir() {
 	case direction.Right:
-		receivingPlayer = (u.CurPlayerIndex + 3) % u.NumPlayers
 		arrowImg = u.Texs["RightArrowGray.png"]
 		arrowAlt = u.Texs["RightArrowBlue.png"]
 	case direction.Left:
-		receivingPlayer = (u.CurPlayerIndex + 1) % u.NumPlayers
 		arrowImg = u.Texs["LeftArrowGray.png"]
 		arrowAlt = u.Texs["LeftArrowBlue.png"]
 	case direction.Across:
-		receivingPlayer = (u.CurPlayerIndex + 2) % u.NumPlayers
 		arrowImg = u.Texs["AcrossArrowGray.png"]
 		arrowAlt = u.Texs["AcrossArrowBlue.png"]
 	}
@@ -912,15 +1083,7 @@ func addGrayTakeBar(u *uistate.UIState) {
 	u.Other = append(u.Other,
 		texture.MakeImgWithAlt(grayBarImg, grayBarAlt, grayBarPos, grayBarDim, display, u))
 	// adding name
-	var passingPlayer int
-	switch u.CurTable.GetDir() {
-	case direction.Right:
-		passingPlayer = (u.CurPlayerIndex + 1) % u.NumPlayers
-	case direction.Left:
-		passingPlayer = (u.CurPlayerIndex + 3) % u.NumPlayers
-	case direction.Across:
-		passingPlayer = (u.CurPlayerIndex + 2) % u.NumPlayers
-	}
+	passingPlayer := u.CurTable.GetPassSender(u.CurPlayerIndex)
 	name := uistate.GetName(passingPlayer, u)
 	color := "Gray"
 	nameAltColor := "LBlue"
@@ -999,17 +1162,18 @@ func SetNumTricksTable(u *uistate.UIState) {
 			}
 			tMax := dropTargetDimensions.Y
 			var textImgs []*staticimg.StaticImg
-			switch i {
-			case 0:
+			edge, _, _ := direction.Seat(i, u.NumPlayers)
+			switch edge {
+			case direction.Down:
 				tCenter := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X/2, dropTargetPos.Y+dropTargetDimensions.Y)
 				textImgs = texture.MakeStringImgCenterAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tCenter, scaler, tMax, u)
-			case 1:
+			case direction.Left:
 				tRight := coords.MakeVec(dropTargetPos.X-2, dropTargetPos.Y+dropTargetDimensions.Y/2-5)
 				textImgs = texture.MakeStringImgRightAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tRight, scaler, tMax, u)
-			case 2:
+			case direction.Across:
 				tCenter := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X/2, dropTargetPos.Y-12)
 				textImgs = texture.MakeStringImgCenterAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tCenter, scaler, tMax, u)
-			case 3:
+			case direction.Right:
 				tLeft := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X+2, dropTargetPos.Y+dropTargetDimensions.Y/2-5)
 				textImgs = texture.MakeStringImgLeftAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tLeft, scaler, tMax, u)
 			}
@@ -1062,8 +1226,9 @@ func SetNumTricksHand(u *uistate.UIState) {
 				}
 				tMax := dropTargetDimensions.Y - u.Padding
 				var textImgs []*staticimg.StaticImg
-				switch i {
-				case 0:
+				edge, _, _ := direction.Seat(i, u.NumPlayers)
+				switch edge {
+				case direction.Down:
 					if topOfHand < u.TopPadding+4*u.CardDim.Y {
 						tLeft := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X+2, dropTargetPos.Y+dropTargetDimensions.Y-15)
 						textImgs = texture.MakeStringImgLeftAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tLeft, scaler, tMax, u)
@@ -1071,10 +1236,10 @@ func SetNumTricksHand(u *uistate.UIState) {
 						tCenter := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X/2, dropTargetPos.Y+dropTargetDimensions.Y+u.Padding)
 						textImgs = texture.MakeStringImgCenterAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tCenter, scaler, tMax, u)
 					}
-				case 1:
+				case direction.Left:
 					tRight := coords.MakeVec(dropTargetPos.X-2, dropTargetPos.Y+dropTargetDimensions.Y/2-5)
 					textImgs = texture.MakeStringImgRightAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tRight, scaler, tMax, u)
-				case 2:
+				case direction.Across:
 					if topOfHand < u.TopPadding+4*u.CardDim.Y {
 						tLeft := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X+2, dropTargetPos.Y)
 						textImgs = texture.MakeStringImgLeftAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tLeft, scaler, tMax, u)
@@ -1082,7 +1247,7 @@ func SetNumTricksHand(u *uistate.UIState) {
 						tCenter := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X/2, dropTargetPos.Y-u.Padding-15)
 						textImgs = texture.MakeStringImgCenterAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tCenter, scaler, tMax, u)
 					}
-				case 3:
+				case direction.Right:
 					tLeft := coords.MakeVec(dropTargetPos.X+dropTargetDimensions.X+2, dropTargetPos.Y+dropTargetDimensions.Y/2-5)
 					textImgs = texture.MakeStringImgLeftAlign(strconv.Itoa(numTricks)+trickText, "", "", true, tLeft, scaler, tMax, u)
 				}
@@ -1260,8 +1425,9 @@ func addPlayerScores(roundScores []int, u *uistate.UIState) {
 		// player name
 		name := uistate.GetName(i, u)
 		nameCenter := coords.MakeVec(playerIconPos.X+playerIconDim.X/2, playerIconPos.Y+playerIconDim.Y)
-		u.BackgroundImgs = append(u.BackgroundImgs,
-			texture.MakeStringImgCenterAlign(name, "", "", true, nameCenter, scaler, maxWidth, u)...)
+		nameImgs := texture.MakeStringImgCenterAlign(name, "", "", true, nameCenter, scaler, maxWidth, u)
+		u.BackgroundImgs = append(u.BackgroundImgs, nameImgs...)
+		addRating(i, coords.MakeVec(nameCenter.X, nameCenter.Y+textHeight(nameImgs)), scaler, maxWidth, u)
 		// player round score
 		roundScore := roundScores[i]
 		if roundScore == maxRoundScore {
@@ -1291,6 +1457,25 @@ func addPlayerScores(roundScores []int, u *uistate.UIState) {
 		texture.MakeImgWithoutAlt(dividerImage, dividerPos, dividerDim, u))
 }
 
+// Adds the rating of the player at playerNum centered at center, unless they're a computer player
+func addRating(playerNum int, center *coords.Vec, scaler, maxWidth float32, u *uistate.UIState) {
+	r, ok := uistate.GetRating(playerNum, u)
+	if !ok {
+		return
+	}
+	text := fmt.Sprintf("Rating: %d", r.Display())
+	u.BackgroundImgs = append(u.BackgroundImgs,
+		texture.MakeStringImgCenterAlign(text, "", "", true, center, scaler, maxWidth, u)...)
+}
+
+// Returns the height of a line of text images, or 0 if there are none
+func textHeight(textImgs []*staticimg.StaticImg) float32 {
+	if len(textImgs) == 0 {
+		return 0
+	}
+	return textImgs[0].GetDimensions().Y
+}
+
 func addScoreButton(gameOver bool, u *uistate.UIState) {
 	var buttonImg sprite.SubTex
 	var buttonAlt sprite.SubTex
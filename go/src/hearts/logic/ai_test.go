@@ -0,0 +1,131 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"hearts/ai"
+	"hearts/logic/card"
+	"hearts/logic/table"
+	"math/rand"
+	"testing"
+)
+
+// Deals a round on t and plays it out with every player using s, the same way the game log applies each command
+// Fails test if s ever chooses a move that validation wouldn't accept
+func playRound(test *testing.T, name string, t *table.Table, s ai.Strategy) {
+	players := t.GetPlayers()
+	for i, h := range t.Deal() {
+		players[i].SetHand(h)
+	}
+	t.NewRound()
+	for i, p := range players {
+		if len(t.LegalPasses(i)) == 0 {
+			continue
+		}
+		passed := s.Pass(t, i)
+		if err := t.ValidPass(passed); err != nil {
+			test.Errorf("%s: player %d passed %d cards", name, i, len(passed))
+			return
+		}
+		for _, c := range passed {
+			if !p.HasCard(c) {
+				test.Errorf("%s: player %d passed a card not in hand", name, i)
+			}
+			p.RemoveFromHand(c)
+		}
+		p.SetPassedFrom(passed)
+		players[t.GetPassRecipient(i)].SetPassedTo(passed)
+		p.SetDonePassing(true)
+	}
+	for _, p := range players {
+		if !p.GetDoneTaking() {
+			for _, c := range p.GetPassedTo() {
+				p.AddToHand(c)
+			}
+			p.SetDoneTaking(true)
+		}
+	}
+	for _, p := range players {
+		if p.HasCard(t.GetFirstLead()) {
+			t.SetFirstPlayer(p.GetPlayerIndex())
+		}
+	}
+	for {
+		if t.TrickOver() {
+			if t.SendTrick(t.GetTrickRecipient()) {
+				return
+			}
+			continue
+		}
+		i := t.WhoseTurn()
+		c := s.Play(t, i)
+		if c == nil || t.ValidPlay(c, i) != nil {
+			test.Errorf("%s: player %d made an invalid play", name, i)
+			return
+		}
+		players[i].RemoveFromHand(c)
+		t.SetPlayedCard(c, i)
+		players[i].SetDonePlaying(true)
+	}
+}
+
+// Testing that every strategy only makes legal moves over whole rounds, for each table size
+func TestAIStrategies(test *testing.T) {
+	strategies := map[string]ai.Strategy{
+		"random":     ai.NewRandom(rand.New(rand.NewSource(1))),
+		"heuristic":  ai.NewHeuristic(),
+		"montecarlo": ai.NewMonteCarlo(2, rand.New(rand.NewSource(1))),
+	}
+	for name, s := range strategies {
+		for numPlayers := 3; numPlayers <= 6; numPlayers++ {
+			t := table.InitializeGame(numPlayers, table.ClassicRules())
+			for round := 0; round < 3; round++ {
+				playRound(test, name, t, s)
+				if !t.RoundOver() {
+					test.Errorf("%s: round with %d players did not finish", name, numPlayers)
+				}
+				t.EndRound()
+			}
+		}
+	}
+}
+
+// Testing that the heuristic strategy passes away high spades
+func TestAIHeuristicPass(test *testing.T) {
+	t := table.InitializeGame(4, table.ClassicRules())
+	hand := []*card.Card{
+		card.NewCard(card.Queen, card.Spade),
+		card.NewCard(card.Ace, card.Spade),
+		card.NewCard(card.Two, card.Club),
+		card.NewCard(card.Three, card.Club),
+		card.NewCard(card.Ace, card.Heart),
+	}
+	t.GetPlayers()[0].SetHand(hand)
+	passed := ai.NewHeuristic().Pass(t, 0)
+	if len(passed) != table.PassSize || passed[0] != hand[1] || passed[1] != hand[0] {
+		test.Errorf("Expected the Ace and Queen of Spades to be passed first, got %v", passed)
+	}
+}
+
+// Testing that the heuristic strategy ducks under the winning card and dumps the Queen of Spades when void
+func TestAIHeuristicPlay(test *testing.T) {
+	// points are allowed on the first trick, so the Queen of Spades can be dumped on it
+	t := table.InitializeGame(4, &table.Rules{PointsOnFirstTrick: true})
+	players := t.GetPlayers()
+	for _, p := range players {
+		p.SetDonePassing(true)
+	}
+	players[0].SetHand([]*card.Card{card.NewCard(card.Nine, card.Club), card.NewCard(card.Jack, card.Club)})
+	players[1].SetHand([]*card.Card{card.NewCard(card.Queen, card.Spade), card.NewCard(card.Two, card.Diamond)})
+	t.SetFirstPlayer(3)
+	t.SetPlayedCard(card.NewCard(card.Ten, card.Club), 3)
+	if c := ai.NewHeuristic().Play(t, 0); c.GetFace() != card.Nine {
+		test.Errorf("Expected the Nine of Clubs, got %s", c.GetFace())
+	}
+	t.SetPlayedCard(card.NewCard(card.Nine, card.Club), 0)
+	if c := ai.NewHeuristic().Play(t, 1); c.GetSuit() != card.Spade {
+		test.Errorf("Expected the Queen of Spades, got %s", c.GetSuit())
+	}
+}
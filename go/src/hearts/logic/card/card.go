@@ -153,6 +153,15 @@ func NewCard(face Face, suit Suit) *Card {
 	}
 }
 
+// Returns a card standing in for one whose face and suit this device can't see, such as a card in another player's hand
+// Every hidden card is distinct, and matches no card of the deck
+func NewHiddenCard() *Card {
+	return &Card{
+		s: UnknownSuit,
+		f: UnknownFace,
+	}
+}
+
 type Card struct {
 	s     Suit
 	f     Face
@@ -177,6 +186,11 @@ func (c *Card) GetFace() Face {
 	return c.f
 }
 
+// Returns true if c stands in for a card which can't be seen
+func (c *Card) Hidden() bool {
+	return c.s == UnknownSuit || c.f == UnknownFace
+}
+
 // Returns the node of c
 func (c *Card) GetNode() *sprite.Node {
 	return c.node
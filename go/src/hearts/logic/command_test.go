@@ -0,0 +1,181 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"hearts/logic/card"
+	"hearts/replay"
+	"reflect"
+	"testing"
+)
+
+// Testing that every command encodes to the log syntax and decodes back to itself
+func TestCommandRoundTrip(test *testing.T) {
+	h10 := card.NewCard(card.Ten, card.Heart)
+	sq := card.NewCard(card.Queen, card.Spade)
+	c2 := card.NewCard(card.Two, card.Club)
+	commands := []struct {
+		c     replay.GameCommand
+		value string
+	}{
+		{replay.GameCommand{Type: replay.Deal, Player: 2, Cards: []*card.Card{h10, sq}, Seed: -42, HasSeed: true},
+			"Deal|2:classic h10:classic sq:seed -42:END"},
+		{replay.GameCommand{Type: replay.Deal, Player: 0, Cards: []*card.Card{c2}},
+			"Deal|0:classic c2:END"},
+		{replay.GameCommand{Type: replay.Pass, Player: 1, Cards: []*card.Card{h10, sq, c2}},
+			"Pass|1:classic h10:classic sq:classic c2:END"},
+		{replay.GameCommand{Type: replay.Take, Player: 3, Cards: []*card.Card{}},
+			"Take|3:END"},
+		{replay.GameCommand{Type: replay.Play, Player: 5, Cards: []*card.Card{sq}},
+			"Play|5:classic sq:END"},
+		{replay.GameCommand{Type: replay.Timeout, Player: 1, Cards: []*card.Card{c2}},
+			"Timeout|1:classic c2:END"},
+		{replay.GameCommand{Type: replay.TakeTrick, Player: -1, Cards: []*card.Card{}},
+			"TakeTrick|END"},
+		{replay.GameCommand{Type: replay.Ready, Player: 0, Cards: []*card.Card{}},
+			"Ready|0:END"},
+		{replay.GameCommand{Type: replay.Shuffle, Player: 0, Cards: []*card.Card{}, Commitment: "3fa9", Points: []string{"AbC", "dEf"},
+			Shares: []string{"9c1e"}, Escrow: []string{"Zm9v"}},
+			"Shuffle|0:commit 3fa9:point AbC:point dEf:share 9c1e:escrow Zm9v:END"},
+		{replay.GameCommand{Type: replay.Lock, Player: 1, Cards: []*card.Card{}, Points: []string{"AbC"}},
+			"Lock|1:point AbC:END"},
+		{replay.GameCommand{Type: replay.Unlock, Player: 2, Cards: []*card.Card{}, Keys: []string{"QkR", "x-y"}},
+			"Unlock|2:key QkR:key x-y:END"},
+		{replay.GameCommand{Type: replay.Release, Player: 3, Cards: []*card.Card{}, Shares: []string{"c2hh"}, Openings: []string{"MWYy"}},
+			"Release|3:share c2hh:opening MWYy:END"},
+		{replay.GameCommand{Type: replay.Release, Player: 1, Cards: []*card.Card{}, Shares: []string{"c2hh"}},
+			"Release|1:share c2hh:END"},
+		{replay.GameCommand{Type: replay.Pass, Player: 2, Cards: []*card.Card{}, Sealed: &replay.Sealed{Commitment: "77", Boxes: []string{"a-1", "b_2"}}},
+			"Pass|2:commit 77:box a-1:box b_2:END"},
+		{replay.GameCommand{Type: replay.Reveal, Player: 3, Cards: []*card.Card{}, Openings: []string{"MWYy", "OTk"}},
+			"Reveal|3:opening MWYy:opening OTk:END"},
+		{replay.GameCommand{Type: replay.Ready, Player: 1, Cards: []*card.Card{}, Proposal: "20-1"},
+			"Ready|1:proposal 20-1:END"},
+	}
+	for _, e := range commands {
+		value, err := e.c.Encode()
+		if err != nil || value != e.value {
+			test.Errorf("Expected %v to encode to %s, got %s %v", e.c, e.value, value, err)
+			continue
+		}
+		decoded, err := replay.Decode(value)
+		if err != nil {
+			test.Errorf("Decode error for %s: %v", value, err)
+		} else if !reflect.DeepEqual(*decoded, e.c) {
+			test.Errorf("Expected %s to decode to %v, got %v", value, e.c, *decoded)
+		}
+	}
+}
+
+// Testing that values which don't follow the log syntax are rejected
+func TestCommandDecodeErrors(test *testing.T) {
+	values := []struct {
+		value string
+		err   error
+	}{
+		{"", replay.ErrMalformedCommand},
+		{"Cut|1:END", replay.ErrUnknownCommand},
+		{"Deal|1:commit 3fa9:box Qm9v:END", replay.ErrMalformedCommand},
+		{"Shuffle|0:point AbC:share 9c1e:escrow Zm9v:END", replay.ErrMalformedCommand},
+		{"Shuffle|0:commit 3fa9:point AbC:END", replay.ErrMalformedCommand},
+		{"Release|1:END", replay.ErrMalformedCommand},
+		{"Release|1:share c2hh:share c2hh:END", replay.ErrMalformedCommand},
+		{"Play|1:classic h10:share c2hh:END", replay.ErrMalformedCommand},
+		{"Lock|1:commit 3fa9:point AbC:END", replay.ErrMalformedCommand},
+		{"Unlock|2:point AbC:END", replay.ErrMalformedCommand},
+		{"Deal", replay.ErrMalformedCommand},
+		{"Play|1:classic h10:END|", replay.ErrMalformedCommand},
+		{"Play|1:classic h10", replay.ErrMalformedCommand},
+		{"Play|1:END", replay.ErrMalformedCommand},
+		{"Play|1:classic h10:classic sq:END", replay.ErrMalformedCommand},
+		{"Play|-1:classic h10:END", replay.ErrMalformedCommand},
+		{"Play|01:classic h10:END", replay.ErrMalformedCommand},
+		{"Play|1:classic ha:END", replay.ErrMalformedCommand},
+		{"Play|1:classic x10:END", replay.ErrMalformedCommand},
+		{"Play|1:classic h:END", replay.ErrMalformedCommand},
+		{"Play|1:tarot h10:END", replay.ErrMalformedCommand},
+		{"Play|1:classic  h10:END", replay.ErrMalformedCommand},
+		{"Pass|1:classic h10:seed 4:END", replay.ErrMalformedCommand},
+		{"Deal|1:seed 4:seed 5:END", replay.ErrMalformedCommand},
+		{"Deal|1:seed 4:classic h10:END", replay.ErrMalformedCommand},
+		{"Deal|1:seed x:END", replay.ErrMalformedCommand},
+		{"Take|END", replay.ErrMalformedCommand},
+		{"Ready|1:classic h10:END", replay.ErrMalformedCommand},
+		{"TakeTrick|1:END", replay.ErrMalformedCommand},
+		{"Reveal|1:END", replay.ErrMalformedCommand},
+		{"Play|1:classic h10:opening MWYy:END", replay.ErrMalformedCommand},
+		{"Deal|1:box Qm9v:END", replay.ErrMalformedCommand},
+		{"Deal|1:commit 3fa9:END", replay.ErrMalformedCommand},
+		{"Deal|1:commit 3fa9:commit 3fa9:box Qm9v:END", replay.ErrMalformedCommand},
+		{"Deal|1:classic h10:commit 3fa9:box Qm9v:END", replay.ErrMalformedCommand},
+		{"Deal|1:commit 3fa9:box Qm9v:seed 4:END", replay.ErrMalformedCommand},
+		{"Play|1:commit 3fa9:box Qm9v:END", replay.ErrMalformedCommand},
+		{"Ready|1:proposal 20-1:proposal 20-1:END", replay.ErrMalformedCommand},
+		{"Play|1:sig Rk9v:classic h10:END", replay.ErrMalformedCommand},
+		{"Play|1:classic h10:sig Rk9v:sig Rk9v:END", replay.ErrMalformedCommand},
+	}
+	for _, v := range values {
+		if c, err := replay.Decode(v.value); !errors.Is(err, v.err) {
+			test.Errorf("Expected %q to be rejected with %v, got %v %v", v.value, v.err, c, err)
+		}
+	}
+}
+
+// Testing that commands which can't be written in the log syntax aren't encoded
+func TestCommandEncodeErrors(test *testing.T) {
+	h10 := card.NewCard(card.Ten, card.Heart)
+	commands := []struct {
+		c   replay.GameCommand
+		err error
+	}{
+		{replay.GameCommand{Type: "Cut", Player: 1}, replay.ErrUnknownCommand},
+		{replay.GameCommand{Type: replay.Play, Player: 1}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Play, Player: 1, Cards: []*card.Card{nil}}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Play, Player: -1, Cards: []*card.Card{h10}}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Ready, Player: 1, Cards: []*card.Card{h10}}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Pass, Player: 1, Cards: []*card.Card{h10}, HasSeed: true}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Pass, Player: 1, Cards: []*card.Card{card.NewCard(card.UnknownFace, card.Heart)}}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Reveal, Player: 1, Openings: []string{"a:b"}}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Deal, Player: 1, Sealed: &replay.Sealed{Commitment: "3fa9", Boxes: []string{"Qm9v"}}}, replay.ErrMalformedCommand},
+		{replay.GameCommand{Type: replay.Unlock, Player: 1, Keys: []string{"QkR"}, Points: []string{"AbC"}}, replay.ErrMalformedCommand},
+	}
+	for _, e := range commands {
+		if value, err := e.c.Encode(); !errors.Is(err, e.err) {
+			test.Errorf("Expected %v to be rejected with %v, got %s %v", e.c, e.err, value, err)
+		}
+	}
+}
+
+// Fuzzing Decode: it must never panic, and anything it accepts must encode and decode back to the same command
+func FuzzCommandDecode(f *testing.F) {
+	seeds := []string{
+		"Deal|2:classic h10:classic sq:seed 42:END",
+		"Pass|1:classic h10:classic sq:classic c2:END",
+		"Take|3:END",
+		"Play|0:classic d1:END",
+		"TakeTrick|END",
+		"Ready|0:END",
+		"Play|1:classic h11:END",
+		"Deal|",
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(test *testing.T, value string) {
+		c, err := replay.Decode(value)
+		if err != nil {
+			return
+		}
+		encoded, err := c.Encode()
+		if err != nil {
+			test.Fatalf("Decoded %q to %v, which doesn't encode: %v", value, *c, err)
+		}
+		again, err := replay.Decode(encoded)
+		if err != nil || !reflect.DeepEqual(again, c) {
+			test.Fatalf("Expected %q to decode to %v, got %v %v", encoded, *c, again, err)
+		}
+	})
+}
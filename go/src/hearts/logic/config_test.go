@@ -0,0 +1,76 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"hearts/util"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Testing that the config file, environment and flags each override the settings before them
+func TestReadConfig(test *testing.T) {
+	dir := test.TempDir()
+	path := filepath.Join(dir, "croupier.json")
+	if err := os.WriteFile(path, []byte(`{"mountPoint": "/file:8101", "userName": "Alice", "userID": 7, "userColor": 5}`), 0666); err != nil {
+		test.Fatalf("WriteFile error: %v", err)
+	}
+	env := map[string]string{
+		"CROUPIER_CONFIG":     path,
+		"CROUPIER_USER_NAME":  "Carol",
+		"CROUPIER_USER_COLOR": "9",
+	}
+	fs := flag.NewFlagSet("croupier", flag.ContinueOnError)
+	util.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-croupier.color", "12", "-croupier.syncbase", "syncbase2", "-croupier.turnlimit", "45", "-croupier.players", "5"}); err != nil {
+		test.Fatalf("Parse error: %v", err)
+	}
+	c, err := util.ReadConfig(func(key string) string { return env[key] }, fs)
+	if err != nil {
+		test.Fatalf("ReadConfig error: %v", err)
+	}
+	expected := util.DefaultConfig()
+	expected.MountPoint = "/file:8101"
+	expected.UserID = 7
+	expected.UserName = "Carol"
+	expected.UserColor = 12
+	expected.SBName = "syncbase2"
+	expected.TurnLimit = 45
+	expected.NumPlayers = 5
+	if c != expected {
+		test.Errorf("Expected config %+v, got %+v", expected, c)
+	}
+	// a missing file leaves the defaults, and the config file named by a flag wins over the environment
+	fs = flag.NewFlagSet("croupier", flag.ContinueOnError)
+	util.RegisterFlags(fs)
+	fs.Parse([]string{"-croupier.config", filepath.Join(dir, "missing.json")})
+	c, err = util.ReadConfig(func(key string) string { return env[key] }, fs)
+	if err != nil || c.MountPoint != util.DefaultConfig().MountPoint || c.UserID != 0 {
+		test.Errorf("Expected the defaults without a config file, got %+v %v", c, err)
+	}
+	env["CROUPIER_NUM_PLAYERS"] = "7"
+	if _, err := util.ReadConfig(func(key string) string { return env[key] }, nil); err == nil {
+		test.Errorf("Expected a number of players no table seats to be rejected")
+	}
+	delete(env, "CROUPIER_NUM_PLAYERS")
+	for _, n := range []int{-4, 0, 1, 2, 7, 1000} {
+		if util.ValidNumPlayers(n) {
+			test.Errorf("Expected a game for %d players to be refused", n)
+		}
+	}
+	env["CROUPIER_USER_ID"] = "seven"
+	if _, err := util.ReadConfig(func(key string) string { return env[key] }, nil); err == nil {
+		test.Errorf("Expected a user ID which isn't a number to be rejected")
+	}
+	if err := os.WriteFile(path, []byte(`{"userID": `), 0666); err != nil {
+		test.Fatalf("WriteFile error: %v", err)
+	}
+	delete(env, "CROUPIER_USER_ID")
+	if _, err := util.ReadConfig(func(key string) string { return env[key] }, nil); err == nil {
+		test.Errorf("Expected a config file which isn't JSON to be rejected")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"hearts/ai"
+	"hearts/history"
+	"hearts/rating"
+	"hearts/store"
+	"testing"
+	"time"
+)
+
+// Testing that finished games are kept once each, read back most recent first, and summed up per user
+func TestHistory(test *testing.T) {
+	d := store.NewNetwork().NewDevice("a")
+	if err := d.CreateTable("settings"); err != nil {
+		test.Fatalf("CreateTable error: %v", err)
+	}
+	h := history.New(d, "settings")
+	start := time.Date(2015, 9, 1, 12, 0, 0, 0, time.UTC)
+	players := []history.Player{{UserID: 7, Name: "Alice"}, {UserID: 8, Name: "Bob"}, {UserID: 9, Name: "Carol"}, {UserID: 10, Name: "Dan"}}
+	first := &history.Game{
+		GameID:  1,
+		Players: players,
+		Rounds: []history.Round{
+			{Scores: []int{0, 13, 10, 3}, MoonShooter: -1, QueenTaker: 1},
+			{Scores: []int{0, 26, 26, 26}, MoonShooter: 0, QueenTaker: 0},
+		},
+		Winners:  []int{0},
+		Started:  start,
+		Finished: start.Add(20 * time.Minute),
+	}
+	second := &history.Game{
+		GameID:  2,
+		Players: players[1:],
+		Rounds: []history.Round{
+			{Scores: []int{0, 13, 13}, MoonShooter: -1, QueenTaker: 1},
+		},
+		Winners:  []int{0},
+		Started:  start.Add(time.Hour),
+		Finished: start.Add(90 * time.Minute),
+	}
+	for _, g := range []*history.Game{first, second} {
+		if added, err := h.Add(g); err != nil || !added {
+			test.Fatalf("Expected game %d to be recorded, got %v", g.GameID, err)
+		}
+	}
+	// a game read back from its log again keeps its first record
+	replayed := *first
+	replayed.Finished = start.Add(3 * time.Hour)
+	if added, err := h.Add(&replayed); err != nil || added {
+		test.Fatalf("Expected a game already recorded to be skipped, got %v", err)
+	}
+	games, err := h.Games()
+	if err != nil {
+		test.Fatalf("Games error: %v", err)
+	}
+	if len(games) != 2 || games[0].GameID != 2 || games[1].GameID != 1 {
+		test.Fatalf("Expected games 2 then 1, got %v", games)
+	}
+	if games[1].Duration() != 20*time.Minute {
+		test.Errorf("Expected the first game to take 20 minutes, got %v", games[1].Duration())
+	}
+	if scores := games[1].Scores(); scores[0] != 0 || scores[1] != 39 {
+		test.Errorf("Expected the first game's scores to be totaled by seat, got %v", scores)
+	}
+	alice := history.UserStats(games, 7)
+	expected := history.Stats{GamesPlayed: 1, GamesWon: 1, Rounds: 2, Points: 0, Queens: 1, MoonShots: 1}
+	if alice != expected {
+		test.Errorf("Expected Alice's stats %+v, got %+v", expected, alice)
+	}
+	bob := history.UserStats(games, 8)
+	expected = history.Stats{GamesPlayed: 2, GamesWon: 1, Rounds: 3, Points: 39, Queens: 1, MoonShots: 0}
+	if bob != expected {
+		test.Errorf("Expected Bob's stats %+v, got %+v", expected, bob)
+	}
+	if bob.WinRate() != 0.5 || bob.AveragePoints() != 13 {
+		test.Errorf("Expected Bob to win half his games at 13 points a round, got %v and %v", bob.WinRate(), bob.AveragePoints())
+	}
+	if none := history.UserStats(games, 11); none.WinRate() != 0 || none.AveragePoints() != 0 {
+		test.Errorf("Expected a user without games to have empty stats, got %+v", none)
+	}
+	// another game played in the same log is recorded alongside the first
+	rematch := *first
+	rematch.Number = 1
+	rematch.Finished = start.Add(2 * time.Hour)
+	if added, err := h.Add(&rematch); err != nil || !added {
+		test.Fatalf("Expected a second game in the same log to be recorded, got %v", err)
+	}
+	if games, err = h.Games(); err != nil || len(games) != 3 || games[0].GameID != 1 || games[0].Number != 1 {
+		test.Errorf("Expected the rematch to be recorded as the most recent game, got %v %v", games, err)
+	}
+}
+
+// Testing that every user is rated from the games in the history, in the order they finished, and computer players aren't
+func TestHistoryRatings(test *testing.T) {
+	start := time.Date(2015, 9, 1, 12, 0, 0, 0, time.UTC)
+	first := &history.Game{
+		GameID:   1,
+		Players:  []history.Player{{UserID: 7}, {UserID: 8}, {UserID: ai.UserID(2)}},
+		Rounds:   []history.Round{{Scores: []int{0, 26, 0}, MoonShooter: -1, QueenTaker: 1}},
+		Finished: start,
+	}
+	second := &history.Game{
+		GameID:   2,
+		Players:  []history.Player{{UserID: 8}, {UserID: 9}},
+		Rounds:   []history.Round{{Scores: []int{4, 22}, MoonShooter: -1, QueenTaker: 1}},
+		Finished: start.Add(time.Hour),
+	}
+	ratings := history.Ratings([]*history.Game{second, first}, ai.IsAI)
+	expected := rating.Update([]rating.Rating{rating.Default(), rating.Default(), rating.Default()}, []int{0, 26, 0})
+	if ratings[7] != expected[0] {
+		test.Errorf("Expected user 7 to be rated from the first game, got %+v", ratings[7])
+	}
+	expected = rating.Update([]rating.Rating{expected[1], rating.Default()}, []int{4, 22})
+	if ratings[8] != expected[0] || ratings[9] != expected[1] || ratings[8].Games != 2 {
+		test.Errorf("Expected user 8 to be rated from both games in turn, got %+v %+v", ratings[8], ratings[9])
+	}
+	if _, ok := ratings[ai.UserID(2)]; ok || len(ratings) != 3 {
+		test.Errorf("Expected only the three users to be rated, got %v", ratings)
+	}
+}
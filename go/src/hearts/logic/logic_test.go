@@ -5,16 +5,19 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"golang.org/x/mobile/exp/sprite"
+	"hearts/img/direction"
 	"hearts/logic/card"
 	"hearts/logic/player"
 	"hearts/logic/table"
+	"math/rand"
 	"sort"
 	"testing"
 )
 
 var (
-	texs   map[string]sprite.SubTex
 	subtex sprite.SubTex
 )
 
@@ -23,7 +26,7 @@ func TestOne(test *testing.T) {
 	numPlayers := 4
 	p2Expect := 15
 	otherExpect := 0
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	t.SetFirstPlayer(1)
 	t.SetPlayedCard(card.NewCard(card.Three, card.Heart), 1)
@@ -56,7 +59,7 @@ func TestTwo(test *testing.T) {
 	p0Expect := 1
 	p2Expect := 15
 	otherExpect := 0
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	t.SetFirstPlayer(1)
 	t.SetPlayedCard(card.NewCard(card.Three, card.Heart), 1)
@@ -95,7 +98,7 @@ func TestThree(test *testing.T) {
 	p1Expect := 17
 	p2Expect := 1
 	otherExpect := 0
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	t.SetFirstPlayer(1)
 	t.SetPlayedCard(card.NewCard(card.Eight, card.Heart), 1)
@@ -144,7 +147,7 @@ func TestThree(test *testing.T) {
 // Testing dealing to make sure no duplicates are dealt
 func TestFour(test *testing.T) {
 	numPlayers := 4
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	hands := t.Deal()
 	testMap := make(map[*card.Card]int)
 	for i := 0; i < 13; i++ {
@@ -175,7 +178,7 @@ func TestFour(test *testing.T) {
 func TestFive(test *testing.T) {
 	numPlayers := 4
 	expect := 13
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	hands := t.Deal()
 	for i, h := range hands {
 		if len(h) != expect {
@@ -216,11 +219,11 @@ func TestSeven(test *testing.T) {
 // Testing playing a card-- ValidPlay() testing 2 of Clubs rule
 func TestEight(test *testing.T) {
 	numPlayers := 1
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	t.SetFirstPlayer(0)
-	if t.ValidPlayLogic(card.NewCard(card.Eight, card.Club), 0) == "" {
+	if t.ValidPlayLogic(card.NewCard(card.Eight, card.Club), 0) == nil {
 		test.Errorf("Expected invalid play for starting round with card other than 2 of Clubs")
-	} else if t.ValidPlayLogic(card.NewCard(card.Two, card.Club), 0) != "" {
+	} else if t.ValidPlayLogic(card.NewCard(card.Two, card.Club), 0) != nil {
 		test.Errorf("Expected valid play for starting round with 2 of Clubs")
 	}
 }
@@ -228,13 +231,13 @@ func TestEight(test *testing.T) {
 // Testing playing a card-- ValidPlay() testing first round points rule
 func TestNine(test *testing.T) {
 	numPlayers := 4
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	players[1].AddToHand(card.NewCard(card.Queen, card.Spade))
 	players[1].AddToHand(card.NewCard(card.Three, card.Diamond))
 	t.SetFirstPlayer(0)
 	t.SetPlayedCard(card.NewCard(card.Two, card.Club), 0)
-	if t.ValidPlayLogic(card.NewCard(card.Queen, card.Spade), 1) == "" {
+	if t.ValidPlayLogic(card.NewCard(card.Queen, card.Spade), 1) == nil {
 		test.Errorf("Expected invalid play for points on the first round")
 	}
 }
@@ -242,7 +245,7 @@ func TestNine(test *testing.T) {
 // Testing playing a card-- ValidPlay() testing breaking Hearts rule
 func TestTen(test *testing.T) {
 	numPlayers := 2
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	players[0].AddToHand(card.NewCard(card.Five, card.Heart))
 	players[1].AddToHand(card.NewCard(card.Two, card.Heart))
@@ -252,19 +255,19 @@ func TestTen(test *testing.T) {
 	t.SetPlayedCard(card.NewCard(card.Three, card.Club), 1)
 	t.SendTrick(t.GetTrickRecipient())
 	t.SetFirstPlayer(0)
-	if t.ValidPlayLogic(card.NewCard(card.Five, card.Heart), 0) != "" {
+	if t.ValidPlayLogic(card.NewCard(card.Five, card.Heart), 0) != nil {
 		test.Errorf("Expected valid play for opener rightfully breaking Hearts")
 	}
 	t.SetFirstPlayer(1)
-	if t.ValidPlayLogic(card.NewCard(card.Two, card.Heart), 1) == "" {
+	if t.ValidPlayLogic(card.NewCard(card.Two, card.Heart), 1) == nil {
 		test.Errorf("Expected invalid play for opener wrongfully breaking Hearts")
 	}
 	t.SetPlayedCard(card.NewCard(card.Three, card.Diamond), 1)
-	if t.ValidPlayLogic(card.NewCard(card.Five, card.Heart), 0) != "" {
+	if t.ValidPlayLogic(card.NewCard(card.Five, card.Heart), 0) != nil {
 		test.Errorf("Expected valid play for follower rightfully breaking Hearts")
 	}
 	players[0].AddToHand(card.NewCard(card.Seven, card.Diamond))
-	if t.ValidPlayLogic(card.NewCard(card.Five, card.Heart), 0) == "" {
+	if t.ValidPlayLogic(card.NewCard(card.Five, card.Heart), 0) == nil {
 		test.Errorf("Expected invalid play for follower wrongfully breaking Hearts")
 	}
 }
@@ -272,20 +275,20 @@ func TestTen(test *testing.T) {
 // Testing playing a card-- ValidPlay() testing following suit rule
 func TestEleven(test *testing.T) {
 	numPlayers := 2
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	players[0].AddToHand(card.NewCard(card.Two, card.Club))
 	players[1].AddToHand(card.NewCard(card.Three, card.Diamond))
 	t.SetFirstPlayer(0)
 	t.SetPlayedCard(card.NewCard(card.Two, card.Club), 0)
-	if t.ValidPlayLogic(card.NewCard(card.Three, card.Diamond), 1) != "" {
+	if t.ValidPlayLogic(card.NewCard(card.Three, card.Diamond), 1) != nil {
 		test.Errorf("Expected valid play for not following suit when player doesn't have suit")
 	}
 	players[1].AddToHand(card.NewCard(card.Five, card.Club))
-	if t.ValidPlayLogic(card.NewCard(card.Five, card.Club), 1) != "" {
+	if t.ValidPlayLogic(card.NewCard(card.Five, card.Club), 1) != nil {
 		test.Errorf("Expected valid play for following suit")
 	}
-	if t.ValidPlayLogic(card.NewCard(card.Three, card.Diamond), 1) == "" {
+	if t.ValidPlayLogic(card.NewCard(card.Three, card.Diamond), 1) == nil {
 		test.Errorf("Expected invalid play for not following suit when player has suit")
 	}
 }
@@ -293,7 +296,7 @@ func TestEleven(test *testing.T) {
 // Testing win condition
 func TestTwelve(test *testing.T) {
 	numPlayers := 1
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	t.SetFirstPlayer(0)
 	t.SetPlayedCard(card.NewCard(card.Queen, card.Spade), 0)
 	t.SendTrick(t.GetTrickRecipient())
@@ -346,7 +349,7 @@ func TestThirteen(test *testing.T) {
 // Testing card sorting
 func TestFourteen(test *testing.T) {
 	numPlayers := 1
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	t.Deal()
 	hand := players[0].GetHand()
@@ -380,9 +383,437 @@ func TestFourteen(test *testing.T) {
 func TestFifteen(test *testing.T) {
 	expect := 0
 	numPlayers := -1
-	t := table.InitializeGame(numPlayers, texs)
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
 	players := t.GetPlayers()
 	if len(players) != expect {
 		test.Errorf("Expected %d, got %d", expect, len(players))
 	}
 }
+
+// Testing dealing with 3, 5 and 6 players to make sure the deck is trimmed and dealt evenly
+func TestSixteen(test *testing.T) {
+	expect := map[int]int{3: 17, 4: 13, 5: 10, 6: 8}
+	for numPlayers, numCards := range expect {
+		t := table.InitializeGame(numPlayers, table.ClassicRules())
+		if t.GetCard(card.Two, card.Club) != nil && numPlayers != 4 {
+			test.Errorf("Expected the Two of Clubs to be removed for %d players", numPlayers)
+		}
+		hands := t.Deal()
+		testMap := make(map[*card.Card]bool)
+		for i, h := range hands {
+			if len(h) != numCards {
+				test.Errorf("Expected %d cards in the hand of player %d of %d, got %d cards", numCards, i, numPlayers, len(h))
+			}
+			for _, c := range h {
+				if testMap[c] {
+					test.Errorf("Duplicate card")
+				}
+				testMap[c] = true
+			}
+		}
+	}
+}
+
+// Testing the first lead card with cards removed from the deck
+func TestSeventeen(test *testing.T) {
+	expect := map[int]card.Face{3: card.Three, 4: card.Two, 5: card.Three, 6: card.Four}
+	for numPlayers, face := range expect {
+		t := table.InitializeGame(numPlayers, table.ClassicRules())
+		lead := t.GetFirstLead()
+		if lead.GetSuit() != card.Club || lead.GetFace() != face {
+			test.Errorf("Expected first lead %s%s for %d players, got %s%s", card.Club, face, numPlayers, lead.GetSuit(), lead.GetFace())
+		}
+		t.SetFirstPlayer(0)
+		if t.ValidPlayLogic(card.NewCard(face, card.Club), 0) != nil {
+			test.Errorf("Expected valid play for starting round with the first lead for %d players", numPlayers)
+		}
+		if t.ValidPlayLogic(card.NewCard(card.Five, card.Club), 0) == nil {
+			test.Errorf("Expected invalid play for starting round with another club for %d players", numPlayers)
+		}
+	}
+}
+
+// Testing pass rotation and pass recipients with an odd number of players
+func TestEighteen(test *testing.T) {
+	numPlayers := 3
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
+	dirs := []direction.Direction{direction.Right, direction.Left, direction.None, direction.Right}
+	recipients := [][]int{{2, 0, 1}, {1, 2, 0}, {-1, -1, -1}, {2, 0, 1}}
+	for round, dir := range dirs {
+		if t.GetDir() != dir {
+			test.Errorf("Expected direction %d in round %d, got %d", dir, round, t.GetDir())
+		}
+		for i := 0; i < numPlayers; i++ {
+			recipient := t.GetPassRecipient(i)
+			if recipient != recipients[round][i] {
+				test.Errorf("Expected player %d to pass to %d in round %d, got %d", i, recipients[round][i], round, recipient)
+			}
+			if recipient != -1 && t.GetPassSender(recipient) != i {
+				test.Errorf("Expected player %d to receive from %d in round %d, got %d", recipient, i, round, t.GetPassSender(recipient))
+			}
+		}
+		t.EndRound()
+	}
+}
+
+// Testing pass recipients across the table with six players
+func TestNineteen(test *testing.T) {
+	numPlayers := 6
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
+	t.EndRound()
+	t.EndRound()
+	if t.GetDir() != direction.Across {
+		test.Errorf("Expected direction %d, got %d", direction.Across, t.GetDir())
+	}
+	for i := 0; i < numPlayers; i++ {
+		expect := (i + 3) % numPlayers
+		if recipient := t.GetPassRecipient(i); recipient != expect {
+			test.Errorf("Expected player %d to pass to %d, got %d", i, expect, recipient)
+		}
+	}
+}
+
+// Testing number of tricks taken with three card tricks
+func TestTwenty(test *testing.T) {
+	numPlayers := 3
+	expect := 2
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
+	t.SetFirstPlayer(0)
+	t.SetPlayedCard(card.NewCard(card.Three, card.Club), 0)
+	t.SetPlayedCard(card.NewCard(card.Four, card.Club), 1)
+	t.SetPlayedCard(card.NewCard(card.Five, card.Club), 2)
+	t.SendTrick(t.GetTrickRecipient())
+	t.SetPlayedCard(card.NewCard(card.Eight, card.Club), 2)
+	t.SetPlayedCard(card.NewCard(card.Six, card.Club), 0)
+	t.SetPlayedCard(card.NewCard(card.Seven, card.Club), 1)
+	t.SendTrick(t.GetTrickRecipient())
+	numTricks := t.GetPlayers()[2].GetNumTricks()
+	if numTricks != expect {
+		test.Errorf("Expected %d, got %d", expect, numTricks)
+	}
+}
+
+// Testing the reasons ValidPlay() gives for rejecting a card
+func TestTwentyOne(test *testing.T) {
+	numPlayers := 4
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
+	players := t.GetPlayers()
+	for _, p := range players {
+		p.SetDonePassing(false)
+	}
+	players[0].AddToHand(card.NewCard(card.Two, card.Club))
+	players[0].AddToHand(card.NewCard(card.Five, card.Club))
+	players[1].AddToHand(card.NewCard(card.Three, card.Club))
+	players[1].AddToHand(card.NewCard(card.Four, card.Diamond))
+	t.SetFirstPlayer(0)
+	if err := t.ValidPlay(card.NewCard(card.Two, card.Club), 0); !errors.Is(err, table.ErrPassingNotDone) {
+		test.Errorf("Expected %v, got %v", table.ErrPassingNotDone, err)
+	}
+	for _, p := range players {
+		p.SetDonePassing(true)
+	}
+	if err := t.ValidPlay(card.NewCard(card.Three, card.Club), 1); !errors.Is(err, table.ErrNotYourTurn) {
+		test.Errorf("Expected %v, got %v", table.ErrNotYourTurn, err)
+	}
+	if err := t.ValidPlay(card.NewCard(card.Five, card.Club), 0); !errors.Is(err, table.ErrMustOpenWithLead) {
+		test.Errorf("Expected %v, got %v", table.ErrMustOpenWithLead, err)
+	}
+	if err := t.ValidPlay(card.NewCard(card.Six, card.Club), 0); !errors.Is(err, table.ErrCardNotInHand) {
+		test.Errorf("Expected %v, got %v", table.ErrCardNotInHand, err)
+	}
+	t.SetPlayedCard(card.NewCard(card.Two, card.Club), 0)
+	players[0].SetDonePlaying(true)
+	if err := t.ValidPlay(card.NewCard(card.Six, card.Club), 0); !errors.Is(err, table.ErrAlreadyPlayed) {
+		test.Errorf("Expected %v, got %v", table.ErrAlreadyPlayed, err)
+	}
+	if err := t.ValidPlay(card.NewCard(card.Four, card.Diamond), 1); !errors.Is(err, table.ErrMustFollowSuit) {
+		test.Errorf("Expected %v, got %v", table.ErrMustFollowSuit, err)
+	}
+	if err := t.ValidPlay(card.NewCard(card.Three, card.Club), 1); err != nil {
+		test.Errorf("Expected valid play, got %v", err)
+	}
+	if err := t.ValidPass(players[1].GetHand()); !errors.Is(err, table.ErrWrongPassSize) {
+		test.Errorf("Expected %v, got %v", table.ErrWrongPassSize, err)
+	}
+}
+
+// Testing LegalPlays() and LegalPasses() against validation
+func TestTwentyTwo(test *testing.T) {
+	numPlayers := 4
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
+	players := t.GetPlayers()
+	for i, h := range t.Deal() {
+		players[i].SetHand(h)
+	}
+	for i := range players {
+		if passes := t.LegalPasses(i); len(passes) != len(players[i].GetHand()) {
+			test.Errorf("Expected %d cards to pass from, got %d", len(players[i].GetHand()), len(passes))
+		}
+		if plays := t.LegalPlays(i); len(plays) != 0 {
+			test.Errorf("Expected no legal plays before passing, got %d", len(plays))
+		}
+		players[i].SetDonePassing(true)
+		if passes := t.LegalPasses(i); len(passes) != 0 {
+			test.Errorf("Expected no cards to pass from after passing, got %d", len(passes))
+		}
+	}
+	for _, p := range players {
+		if p.HasCard(t.GetFirstLead()) {
+			t.SetFirstPlayer(p.GetPlayerIndex())
+		}
+	}
+	for i, p := range players {
+		plays := t.LegalPlays(i)
+		for _, c := range p.GetHand() {
+			legal := false
+			for _, l := range plays {
+				if l == c {
+					legal = true
+				}
+			}
+			if legal != (t.ValidPlay(c, i) == nil) {
+				test.Errorf("LegalPlays disagrees with ValidPlay for player %d", i)
+			}
+		}
+		if i == t.GetFirstPlayer() && len(plays) != 1 {
+			test.Errorf("Expected only the first lead to be legal, got %d cards", len(plays))
+		}
+	}
+}
+
+// Testing that deals can be reproduced from a seed, a source of randomness or a predefined layout
+func TestTwentyThree(test *testing.T) {
+	numPlayers := 4
+	t1 := table.InitializeGame(numPlayers, table.ClassicRules())
+	t2 := table.InitializeGame(numPlayers, table.ClassicRules())
+	sameHands := func(h1, h2 [][]*card.Card) bool {
+		for i := range h1 {
+			for j := range h1[i] {
+				if h1[i][j].GetSuit() != h2[i][j].GetSuit() || h1[i][j].GetFace() != h2[i][j].GetFace() {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if !sameHands(t1.DealSeed(42), t2.DealSeed(42)) {
+		test.Errorf("Expected the same seed to deal the same hands")
+	}
+	if sameHands(t1.DealSeed(42), t1.DealSeed(43)) {
+		test.Errorf("Expected different seeds to deal different hands")
+	}
+	t1.SetRand(rand.New(rand.NewSource(7)))
+	t2.SetRand(rand.New(rand.NewSource(7)))
+	for i := 0; i < 3; i++ {
+		if !sameHands(t1.Deal(), t2.Deal()) {
+			test.Errorf("Expected the same source of randomness to deal the same hands")
+		}
+	}
+	layout := make([][]*card.Card, numPlayers)
+	for i, c := range t1.GetAllCards() {
+		layout[i%numPlayers] = append(layout[i%numPlayers], card.NewCard(c.GetFace(), c.GetSuit()))
+	}
+	hands, err := t2.DealLayout(layout)
+	if err != nil || !sameHands(hands, layout) || hands[0][0] != t2.GetAllCards()[0] {
+		test.Errorf("Expected the layout to be dealt with the cards of the table, got %v", err)
+	}
+	layout[1][0] = layout[0][0]
+	if _, err := t2.DealLayout(layout); !errors.Is(err, table.ErrInvalidLayout) {
+		test.Errorf("Expected a repeated card to be rejected, got %v", err)
+	}
+	if _, err := t2.DealLayout(layout[:3]); !errors.Is(err, table.ErrInvalidLayout) {
+		test.Errorf("Expected a missing hand to be rejected, got %v", err)
+	}
+}
+
+// Testing that a table restored from a snapshot is in the same state, after an encoding round trip
+func TestTwentyFour(test *testing.T) {
+	t := table.InitializeGame(4, &table.Rules{JackOfDiamonds: true, Moon: table.SubtractFromShooter})
+	t.SetRand(rand.New(rand.NewSource(3)))
+	players := t.GetPlayers()
+	for i, h := range t.Deal() {
+		players[i].SetHand(h)
+	}
+	for i, p := range players {
+		passed := p.GetHand()[:table.PassSize]
+		p.SetPassedFrom(passed)
+		players[t.GetPassRecipient(i)].SetPassedTo(passed)
+		p.SetDonePassing(true)
+		p.UpdateScore(i * 7)
+	}
+	for _, p := range players {
+		if p.HasCard(t.GetFirstLead()) {
+			t.SetFirstPlayer(p.GetPlayerIndex())
+		}
+	}
+	lead := t.WhoseTurn()
+	c := t.LegalPlays(lead)[0]
+	players[lead].RemoveFromHand(c)
+	t.SetPlayedCard(c, lead)
+	players[lead].SetDonePlaying(true)
+	encoded, err := json.Marshal(t.Snapshot())
+	if err != nil {
+		test.Fatalf("Marshal error: %v", err)
+	}
+	var s table.Snapshot
+	if err := json.Unmarshal(encoded, &s); err != nil {
+		test.Fatalf("Unmarshal error: %v", err)
+	}
+	restored, err := table.Restore(&s)
+	if err != nil {
+		test.Fatalf("Restore error: %v", err)
+	}
+	reencoded, _ := json.Marshal(restored.Snapshot())
+	if string(encoded) != string(reencoded) {
+		test.Errorf("Expected the restored table to encode the same, got\n%s\n%s", encoded, reencoded)
+	}
+	next := t.WhoseTurn()
+	if restored.WhoseTurn() != next || len(restored.LegalPlays(next)) != len(t.LegalPlays(next)) {
+		test.Errorf("Expected the restored table to allow the same plays")
+	}
+	if restored.GetRules().CardPoints(card.NewCard(card.Jack, card.Diamond)) != -10 {
+		test.Errorf("Expected the restored table to keep its rules")
+	}
+	s.Version = table.SnapshotVersion + 1
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrSnapshotVersion) {
+		test.Errorf("Expected %v, got %v", table.ErrSnapshotVersion, err)
+	}
+	s.Version = table.SnapshotVersion
+	s.Players[0].Hand[0] = "x1"
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrInvalidSnapshot) {
+		test.Errorf("Expected %v, got %v", table.ErrInvalidSnapshot, err)
+	}
+}
+
+// Testing the reasons ValidDeal(), ValidPassFrom(), ValidTake() and ValidReady() give for rejecting a move
+func TestTwentyFive(test *testing.T) {
+	numPlayers := 4
+	t := table.InitializeGame(numPlayers, table.ClassicRules())
+	players := t.GetPlayers()
+	hands := t.DealSeed(9)
+	if err := t.ValidDeal(hands[0][1:], 0); !errors.Is(err, table.ErrInvalidLayout) {
+		test.Errorf("Expected %v, got %v", table.ErrInvalidLayout, err)
+	}
+	for i, h := range hands {
+		if err := t.ValidDeal(h, i); err != nil {
+			test.Errorf("Expected valid deal, got %v", err)
+		}
+		players[i].SetHand(h)
+	}
+	if err := t.ValidDeal(hands[0], 0); !errors.Is(err, table.ErrAlreadyDealt) {
+		test.Errorf("Expected %v, got %v", table.ErrAlreadyDealt, err)
+	}
+	t.NewRound()
+	if err := t.ValidReady(0); !errors.Is(err, table.ErrRoundNotOver) {
+		test.Errorf("Expected %v, got %v", table.ErrRoundNotOver, err)
+	}
+	if err := t.ValidTake(0); !errors.Is(err, table.ErrPassingNotDone) {
+		test.Errorf("Expected %v, got %v", table.ErrPassingNotDone, err)
+	}
+	hand := players[0].GetHand()
+	if err := t.ValidPassFrom(hand[:2], 0); !errors.Is(err, table.ErrWrongPassSize) {
+		test.Errorf("Expected %v, got %v", table.ErrWrongPassSize, err)
+	}
+	if err := t.ValidPassFrom([]*card.Card{hand[0], hand[0], hand[1]}, 0); !errors.Is(err, table.ErrCardNotInHand) {
+		test.Errorf("Expected %v, got %v", table.ErrCardNotInHand, err)
+	}
+	if err := t.ValidPassFrom(players[1].GetHand()[:table.PassSize], 0); !errors.Is(err, table.ErrCardNotInHand) {
+		test.Errorf("Expected %v, got %v", table.ErrCardNotInHand, err)
+	}
+	for i, p := range players {
+		passed := p.GetHand()[:table.PassSize]
+		if err := t.ValidPassFrom(passed, i); err != nil {
+			test.Errorf("Expected valid pass, got %v", err)
+		}
+		p.SetPassedFrom(passed)
+		players[t.GetPassRecipient(i)].SetPassedTo(passed)
+		p.SetDonePassing(true)
+	}
+	if err := t.ValidPassFrom(players[0].GetHand()[:table.PassSize], 0); !errors.Is(err, table.ErrAlreadyPassed) {
+		test.Errorf("Expected %v, got %v", table.ErrAlreadyPassed, err)
+	}
+	if err := t.ValidTake(0); err != nil {
+		test.Errorf("Expected valid take, got %v", err)
+	}
+	players[0].SetDoneTaking(true)
+	if err := t.ValidTake(0); !errors.Is(err, table.ErrAlreadyTaken) {
+		test.Errorf("Expected %v, got %v", table.ErrAlreadyTaken, err)
+	}
+}
+
+// Testing that Seat() gives four players an edge of the screen each, and gives every player of a larger or smaller
+// table a place of their own, with the player at the bottom alone there
+func TestTwentySix(test *testing.T) {
+	classic := []direction.Direction{direction.Down, direction.Left, direction.Across, direction.Right}
+	for i, expected := range classic {
+		if edge, slot, slots := direction.Seat(i, 4); edge != expected || slot != 0 || slots != 1 {
+			test.Errorf("Expected player %d at edge %d alone, got edge %d, place %d of %d", i, expected, edge, slot, slots)
+		}
+	}
+	for numPlayers := 3; numPlayers <= 6; numPlayers++ {
+		places := make(map[[2]int]bool)
+		for i := 0; i < numPlayers; i++ {
+			edge, slot, slots := direction.Seat(i, numPlayers)
+			place := [2]int{int(edge), slot}
+			if slot < 0 || slot >= slots || places[place] {
+				test.Errorf("Expected a place of their own for player %d of %d, got edge %d, place %d of %d", i, numPlayers, edge, slot, slots)
+			}
+			places[place] = true
+		}
+		if edge, _, slots := direction.Seat(0, numPlayers); edge != direction.Down || slots != 1 {
+			test.Errorf("Expected player 0 of %d alone at the bottom, got edge %d with %d places", numPlayers, edge, slots)
+		}
+	}
+	// with six players, the two players at the left sit in clockwise order, from the bottom up
+	if _, lower, _ := direction.Seat(1, 6); lower != 1 {
+		test.Errorf("Expected player 1 of 6 lower on the left, got place %d", lower)
+	}
+}
+
+// Testing that Restore() rejects a snapshot holding a card twice, or cards the deck couldn't have dealt
+func TestTwentySeven(test *testing.T) {
+	snapshot := func(numPlayers int) table.Snapshot {
+		t := table.InitializeGame(numPlayers, table.ClassicRules())
+		t.SetRand(rand.New(rand.NewSource(5)))
+		for i, h := range t.Deal() {
+			t.GetPlayers()[i].SetHand(h)
+		}
+		encoded, _ := json.Marshal(t.Snapshot())
+		var s table.Snapshot
+		json.Unmarshal(encoded, &s)
+		return s
+	}
+	for numPlayers := 3; numPlayers <= 6; numPlayers++ {
+		s := snapshot(numPlayers)
+		if _, err := table.Restore(&s); err != nil {
+			test.Errorf("Expected a dealt table for %d to be restored, got %v", numPlayers, err)
+		}
+	}
+	s := snapshot(4)
+	s.Players[1].Hand[0] = s.Players[0].Hand[0]
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrDuplicateCard) || !errors.Is(err, table.ErrInvalidSnapshot) {
+		test.Errorf("Expected a card in two hands to be rejected, got %v", err)
+	}
+	s = snapshot(4)
+	s.Players[2].Tricks = []string{s.Players[3].Hand[0]}
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrDuplicateCard) {
+		test.Errorf("Expected a card in a hand and a pile to be rejected, got %v", err)
+	}
+	s = snapshot(4)
+	s.Players[0].Hand = s.Players[0].Hand[1:]
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrCardCount) || !errors.Is(err, table.ErrInvalidSnapshot) {
+		test.Errorf("Expected a card missing from the deck to be rejected, got %v", err)
+	}
+	s = snapshot(5)
+	s.Players[0].Hand = append(s.Players[0].Hand, s.Players[1].Hand...)
+	s.Players[1].Hand = nil
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrCardCount) {
+		test.Errorf("Expected a hand larger than the deck deals to be rejected, got %v", err)
+	}
+	// the deck for three players leaves out the two of clubs
+	s = snapshot(3)
+	s.Players[0].Hand[0] = "c2"
+	if _, err := table.Restore(&s); !errors.Is(err, table.ErrInvalidSnapshot) {
+		test.Errorf("Expected a card removed from the deck to be rejected, got %v", err)
+	}
+}
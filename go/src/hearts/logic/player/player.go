@@ -15,6 +15,7 @@ func NewPlayer(index int) *Player {
 	return &Player{
 		hand:        nil,
 		tricks:      make([]*card.Card, 0),
+		numTricks:   0,
 		score:       0,
 		playerIndex: index,
 		donePassing: false,
@@ -28,6 +29,7 @@ type Player struct {
 	passedFrom  []*card.Card
 	passedTo    []*card.Card
 	tricks      []*card.Card
+	numTricks   int
 	score       int
 	playerIndex int
 	donePassing bool
@@ -36,6 +38,17 @@ type Player struct {
 	doneScoring bool
 }
 
+// Returns a copy of p which can be changed without affecting p
+// The cards themselves are shared between p and the copy
+func (p *Player) Copy() *Player {
+	c := *p
+	c.hand = append([]*card.Card(nil), p.hand...)
+	c.passedFrom = append([]*card.Card(nil), p.passedFrom...)
+	c.passedTo = append([]*card.Card(nil), p.passedTo...)
+	c.tricks = append([]*card.Card(nil), p.tricks...)
+	return &c
+}
+
 // Returns the hand of p
 func (p *Player) GetHand() []*card.Card {
 	return p.hand
@@ -51,10 +64,14 @@ func (p *Player) GetPassedFrom() []*card.Card {
 	return p.passedFrom
 }
 
+// Returns all cards in the tricks p has taken
+func (p *Player) GetTricks() []*card.Card {
+	return p.tricks
+}
+
 // Returns the number of tricks p has taken
-// Assumes each trick is 4 cards
 func (p *Player) GetNumTricks() int {
-	return len(p.tricks) / 4
+	return p.numTricks
 }
 
 // Returns the score of p
@@ -139,6 +156,13 @@ func (p *Player) SetDoneScoring(isDone bool) {
 // Adds cards to the tricks deck of p
 func (p *Player) TakeTrick(cards []*card.Card) {
 	p.tricks = append(p.tricks, cards...)
+	p.numTricks++
+}
+
+// Sets the tricks deck of p to cards, taken over numTricks tricks
+func (p *Player) SetTricks(cards []*card.Card, numTricks int) {
+	p.tricks = cards
+	p.numTricks = numTricks
 }
 
 // Adds points to the total score of p
@@ -172,6 +196,7 @@ func (p *Player) ResetPassedFrom() {
 // Sets the tricks deck of p to a new empty list
 func (p *Player) ResetTricks() {
 	p.tricks = make([]*card.Card, 0)
+	p.numTricks = 0
 }
 
 // Resets the score of p to 0 for a new game
@@ -204,10 +229,13 @@ func (p *Player) HasAllPoints() bool {
 	return true
 }
 
-// Returns true if p has the two of clubs in hand
-func (p *Player) HasTwoOfClubs() bool {
-	for _, c := range p.hand {
-		if c.GetSuit() == card.Club && c.GetFace() == card.Two {
+// Returns true if p has a card with the same suit and face as c in hand
+func (p *Player) HasCard(c *card.Card) bool {
+	if c == nil {
+		return false
+	}
+	for _, h := range p.hand {
+		if h.GetSuit() == c.GetSuit() && h.GetFace() == c.GetFace() {
 			return true
 		}
 	}
@@ -0,0 +1,73 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"hearts/rating"
+	"math"
+	"testing"
+)
+
+// Testing that a game moves ratings in the order the players finished, and makes them more certain
+func TestRatingUpdate(test *testing.T) {
+	start := []rating.Rating{rating.Default(), rating.Default(), rating.Default(), rating.Default()}
+	if start[0].Display() != 0 {
+		test.Errorf("Expected a new user to be shown a rating of 0, got %d", start[0].Display())
+	}
+	updated := rating.Update(start, []int{40, 12, 103, 40})
+	if !(updated[1].Mu > updated[0].Mu && updated[0].Mu > updated[2].Mu) {
+		test.Errorf("Expected the lowest score to rate highest and the highest score lowest, got %+v", updated)
+	}
+	if math.Abs(updated[0].Mu-updated[3].Mu) > 1e-9 || updated[0].Sigma != updated[3].Sigma {
+		test.Errorf("Expected players who drew from the same rating to stay equal, got %+v and %+v", updated[0], updated[3])
+	}
+	total := 0.0
+	for i, r := range updated {
+		total += r.Mu - start[i].Mu
+		if r.Sigma >= start[i].Sigma {
+			test.Errorf("Expected player %d to be rated more certainly after a game, got %v", i, r.Sigma)
+		}
+		if r.Games != 1 {
+			test.Errorf("Expected player %d to have one game rated, got %d", i, r.Games)
+		}
+	}
+	if math.Abs(total) > 1e-9 {
+		test.Errorf("Expected players with equal ratings to trade skill without creating any, got a total change of %v", total)
+	}
+	// beating a stronger player gains more than beating a weaker one
+	strong := rating.Rating{Mu: 35, Sigma: 3}
+	weak := rating.Rating{Mu: 15, Sigma: 3}
+	upset := rating.Update([]rating.Rating{rating.Default(), strong}, []int{20, 60})[0]
+	expected := rating.Update([]rating.Rating{rating.Default(), weak}, []int{20, 60})[0]
+	if upset.Mu <= expected.Mu {
+		test.Errorf("Expected an upset to gain more than an expected win, got %v and %v", upset.Mu, expected.Mu)
+	}
+	for i := 0; i < 20; i++ {
+		updated = rating.Update(updated, []int{0, 26, 26, 26})
+	}
+	if updated[0].Display() <= 0 || math.IsNaN(updated[0].Mu) || math.IsNaN(updated[1].Sigma) {
+		test.Errorf("Expected a player who keeps winning to climb, got %+v", updated)
+	}
+}
+
+// Testing a game between two new players against the values published for TrueSkill's default environment
+func TestRatingTwoPlayers(test *testing.T) {
+	tests := []struct {
+		name   string
+		scores []int
+		expect []rating.Rating
+	}{
+		{"win", []int{10, 16}, []rating.Rating{{Mu: 29.396, Sigma: 7.171}, {Mu: 20.604, Sigma: 7.171}}},
+		{"draw", []int{13, 13}, []rating.Rating{{Mu: 25.000, Sigma: 6.458}, {Mu: 25.000, Sigma: 6.458}}},
+	}
+	for _, tt := range tests {
+		updated := rating.Update([]rating.Rating{rating.Default(), rating.Default()}, tt.scores)
+		for i, r := range updated {
+			if math.Abs(r.Mu-tt.expect[i].Mu) > 0.001 || math.Abs(r.Sigma-tt.expect[i].Sigma) > 0.001 {
+				test.Errorf("%s: expected player %d to be rated %+v, got %+v", tt.name, i, tt.expect[i], r)
+			}
+		}
+	}
+}
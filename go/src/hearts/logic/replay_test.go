@@ -0,0 +1,469 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hearts/ai"
+	"hearts/logic/card"
+	"hearts/logic/table"
+	"hearts/replay"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Encodes a game log command the way hearts/sync writes it
+func logCommand(command string, playerIndex int, cards []*card.Card) string {
+	value, _ := (&replay.GameCommand{Type: command, Player: playerIndex, Cards: cards}).Encode()
+	return value
+}
+
+// Applies a log entry and returns its events, failing test on error
+func applyEntry(test *testing.T, r *replay.Replayer, n *int, playerIndex int, value string) []replay.Event {
+	*n++
+	events, err := r.Apply(fmt.Sprintf("1/log/%d-%d", *n, playerIndex), value)
+	if err != nil {
+		test.Fatalf("Apply error for %s: %v", value, err)
+	}
+	return events
+}
+
+// Testing the events and table state produced by replaying the log of a whole round
+func TestReplayRound(test *testing.T) {
+	numPlayers := 4
+	r := replay.New(table.InitializeGame(numPlayers, table.ClassicRules()), true)
+	t := r.Table()
+	s := ai.NewHeuristic()
+	n := 0
+	for i := 0; i < numPlayers; i++ {
+		events, err := r.Apply(fmt.Sprintf("1/players/%d/player_number", 100+i), fmt.Sprintf("%d", i))
+		if p, ok := events[0].(replay.PlayerNumEvent); err != nil || !ok || p.UserID != 100+i || p.PlayerNum != i {
+			test.Errorf("Expected user %d to sit at %d, got %v %v", 100+i, i, events, err)
+		}
+	}
+	if !t.AllReadyForNewRound() {
+		test.Errorf("Expected every seated player to be ready")
+	}
+	seed := int64(5)
+	var events []replay.Event
+	for i, h := range t.DealSeed(seed) {
+		value, _ := (&replay.GameCommand{Type: replay.Deal, Player: i, Cards: h, Seed: seed, HasSeed: true}).Encode()
+		events = applyEntry(test, r, &n, 0, value)
+		if d, ok := events[0].(replay.DealEvent); !ok || !d.HasSeed || d.Seed != seed || d.Player != i {
+			test.Errorf("Expected a deal event with seed %d, got %v", seed, events[0])
+		}
+	}
+	if _, ok := events[len(events)-1].(replay.NewRoundEvent); !ok {
+		test.Errorf("Expected the last deal to start a new round")
+	}
+	for i := 0; i < numPlayers; i++ {
+		events = applyEntry(test, r, &n, i, logCommand(replay.Pass, i, s.Pass(t, i)))
+		if p, ok := events[0].(replay.PassEvent); !ok || p.Recipient != t.GetPassRecipient(i) {
+			test.Errorf("Expected a pass event to player %d, got %v", t.GetPassRecipient(i), events[0])
+		}
+	}
+	for i := 0; i < numPlayers; i++ {
+		events = applyEntry(test, r, &n, i, logCommand(replay.Take, i, nil))
+	}
+	if f, ok := events[len(events)-1].(replay.FirstPlayerEvent); !ok || f.Player != t.GetFirstPlayer() {
+		test.Errorf("Expected the last take to reveal the first player, got %v", events)
+	}
+	for !t.RoundOver() || !t.TrickNew() {
+		if t.TrickOver() {
+			recipient := t.GetTrickRecipient()
+			events = applyEntry(test, r, &n, recipient, logCommand(replay.TakeTrick, recipient, nil))
+			if tt, ok := events[0].(replay.TakeTrickEvent); !ok || tt.Recipient != recipient {
+				test.Errorf("Expected player %d to take the trick, got %v", recipient, events[0])
+			}
+			continue
+		}
+		i := t.WhoseTurn()
+		c := s.Play(t, i)
+		events = applyEntry(test, r, &n, i, logCommand(replay.Play, i, []*card.Card{c}))
+		if p, ok := events[0].(replay.PlayEvent); !ok || p.Card != c {
+			test.Errorf("Expected player %d to play %v, got %v", i, c, events[0])
+		}
+	}
+	tt, ok := events[0].(replay.TakeTrickEvent)
+	if !ok || !tt.RoundOver {
+		test.Fatalf("Expected the last trick to end the round, got %v", events[0])
+	}
+	total := 0
+	for _, score := range tt.RoundScores {
+		total += score
+	}
+	if total != 26 && total != 78 {
+		test.Errorf("Expected the round to score 26 points, or 78 if the moon was shot, got %d", total)
+	}
+	if (total == 78) != (tt.MoonShooter >= 0) {
+		test.Errorf("Expected a moon shooter only when the moon was shot, got %d with %d points", tt.MoonShooter, total)
+	}
+	if tt.QueenTaker < 0 || tt.QueenTaker >= numPlayers {
+		test.Errorf("Expected a player to have taken the Queen of Spades, got %d", tt.QueenTaker)
+	} else if tt.MoonShooter < 0 && tt.RoundScores[tt.QueenTaker] < 13 {
+		test.Errorf("Expected player %d to score the Queen of Spades, got %v", tt.QueenTaker, tt.RoundScores)
+	}
+	// a second take of the same trick changes nothing
+	before, _ := json.Marshal(t.Snapshot())
+	events = applyEntry(test, r, &n, 0, logCommand(replay.TakeTrick, 0, nil))
+	after, _ := json.Marshal(t.Snapshot())
+	if len(events) != 0 || string(before) != string(after) {
+		test.Errorf("Expected a repeated take trick to be ignored")
+	}
+	for i := 0; i < numPlayers; i++ {
+		events = applyEntry(test, r, &n, i, logCommand(replay.Ready, i, nil))
+	}
+	if rd, ok := events[0].(replay.ReadyEvent); !ok || !rd.AllReady {
+		test.Errorf("Expected every player to be ready after the last ready command")
+	}
+}
+
+// Testing that malformed entries are rejected without changing the table
+func TestReplayMalformed(test *testing.T) {
+	r := replay.New(table.InitializeGame(4, table.ClassicRules()), true)
+	before, _ := json.Marshal(r.Table().Snapshot())
+	entries := []struct {
+		value string
+		err   error
+	}{
+		{"Play|7:classic h10:END", replay.ErrMalformedEntry},
+		{"Play|x:classic h10:END", replay.ErrMalformedCommand},
+		{"Play|1:classic x10:END", replay.ErrMalformedCommand},
+		{"Play|1:END", replay.ErrMalformedCommand},
+		{"Deal", replay.ErrMalformedCommand},
+	}
+	for _, e := range entries {
+		if _, err := r.Apply("1/log/1-0", e.value); !errors.Is(err, e.err) {
+			test.Errorf("Expected %s to be rejected with %v, got %v", e.value, e.err, err)
+		}
+	}
+	if events, err := r.Apply("1/log/1-0", "Cut|1:END"); events != nil || err != nil {
+		test.Errorf("Expected an unknown command to be ignored, got %v %v", events, err)
+	}
+	if _, err := r.Apply("1/players/5/blessings", "dev.v.io:u:alice"); !errors.Is(err, replay.ErrMalformedEntry) {
+		test.Errorf("Expected unencoded blessings to be rejected with %v, got %v", replay.ErrMalformedEntry, err)
+	}
+	events, err := r.Apply("1/players/5/blessings", `["dev.v.io:u:alice"]`)
+	expected := []replay.Event{replay.BlessingsEvent{UserID: 5, Blessings: []string{"dev.v.io:u:alice"}}}
+	if err != nil || !reflect.DeepEqual(events, expected) {
+		test.Errorf("Expected %v, got %v %v", expected, events, err)
+	}
+	// only the first blessings a user records are accepted
+	if events, err := r.Apply("1/players/5/blessings", `["dev.v.io:u:alice"]`); len(events) != 0 || err != nil {
+		test.Errorf("Expected the same blessings written again to be ignored, got %v %v", events, err)
+	}
+	events, err = r.Apply("1/players/5/blessings", `["..."]`)
+	if v, ok := events[0].(replay.ViolationEvent); err != nil || !ok || !errors.Is(v.Err, replay.ErrBlessingsChanged) {
+		test.Errorf("Expected other blessings written for the same user to be a violation, got %v %v", events, err)
+	}
+	// likewise only the first hand key a user publishes is accepted
+	events, err = r.Apply("1/players/5/hand_key", "first")
+	if err != nil || !reflect.DeepEqual(events, []replay.Event{replay.HandKeyEvent{UserID: 5, Key: "first"}}) {
+		test.Errorf("Expected a hand key event, got %v %v", events, err)
+	}
+	if events, err := r.Apply("1/players/5/hand_key", "first"); len(events) != 0 || err != nil {
+		test.Errorf("Expected the same hand key written again to be ignored, got %v %v", events, err)
+	}
+	events, err = r.Apply("1/players/5/hand_key", "second")
+	if v, ok := events[0].(replay.ViolationEvent); err != nil || !ok || !errors.Is(v.Err, replay.ErrHandKeyChanged) {
+		test.Errorf("Expected another hand key written for the same user to be a violation, got %v %v", events, err)
+	}
+	if events, err := r.Apply("1/players/5/hand_key", "first"); len(events) != 0 || err != nil {
+		test.Errorf("Expected the changed hand key to be ignored, got %v %v", events, err)
+	}
+	after, _ := json.Marshal(r.Table().Snapshot())
+	if string(before) != string(after) {
+		test.Errorf("Expected rejected entries to leave the table unchanged")
+	}
+	if keyTime, playerIndex, ok := replay.ParseLogKey("1/log/1440000000000-3"); !ok || keyTime != 1440000000000 || playerIndex != 3 {
+		test.Errorf("Expected ParseLogKey to read a log key, got %d %d %t", keyTime, playerIndex, ok)
+	}
+	if _, _, ok := replay.ParseLogKey("1/players/5/player_number"); ok {
+		test.Errorf("Expected ParseLogKey to reject a key outside the log")
+	}
+	if key := replay.LogKey(1, 1440000000000, 3); key != "1/log/0000001440000000000-3" {
+		test.Errorf("Expected LogKey to write 1/log/0000001440000000000-3, got %s", key)
+	}
+	if keyTime, playerIndex, ok := replay.ParseLogKey(replay.LogKey(1, 42, 3)); !ok || keyTime != 42 || playerIndex != 3 {
+		test.Errorf("Expected ParseLogKey to read back a padded key, got %d %d %t", keyTime, playerIndex, ok)
+	}
+}
+
+// Testing that entries written after reading another sort after it, whatever the devices' clocks were before
+func TestClock(test *testing.T) {
+	fast := replay.NewClock()
+	slow := replay.NewClock()
+	fast.Observe(1440000000000)
+	first := replay.LogKey(1, fast.Tick(), 3)
+	second := replay.LogKey(1, slow.Tick(), 0)
+	if second > first {
+		test.Errorf("Expected a clock which has seen nothing to stay behind, got %s after %s", second, first)
+	}
+	slow.Observe(1440000000001)
+	third := replay.LogKey(1, slow.Tick(), 0)
+	if third <= first {
+		test.Errorf("Expected %s to sort after the entry it read, %s", third, first)
+	}
+	if replay.LogKey(1, 9, 0) > replay.LogKey(1, 10, 0) {
+		test.Errorf("Expected keys to sort in timestamp order")
+	}
+	if replay.LogKey(1, 10, 0) > replay.LogKey(1, 10, 2) {
+		test.Errorf("Expected entries with the same timestamp to sort by player")
+	}
+	observed := replay.NewClock()
+	observed.Observe(7)
+	observed.Observe(5)
+	if t := observed.Tick(); t != 8 {
+		test.Errorf("Expected the clock to tick past the latest entry it saw, got %d", t)
+	}
+}
+
+// Testing that commands which break the rules are recorded as violations without changing the table
+func TestReplayViolations(test *testing.T) {
+	numPlayers := 4
+	r := replay.New(table.InitializeGame(numPlayers, table.ClassicRules()), true)
+	t := r.Table()
+	n := 0
+	hands := t.DealSeed(11)
+	for i, h := range hands {
+		applyEntry(test, r, &n, 0, logCommand(replay.Deal, i, h))
+	}
+	hand := t.GetPlayers()[1].GetHand()
+	entries := []struct {
+		writer int
+		value  string
+		err    error
+	}{
+		{2, logCommand(replay.Deal, 2, hands[2]), table.ErrAlreadyDealt},
+		{1, logCommand(replay.Pass, 1, hand[:table.PassSize+1]), table.ErrWrongPassSize},
+		{1, logCommand(replay.Pass, 1, hands[2][:table.PassSize]), table.ErrCardNotInHand},
+		{1, logCommand(replay.Take, 1, nil), table.ErrPassingNotDone},
+		{1, logCommand(replay.Play, 1, hand[:1]), table.ErrPassingNotDone},
+		{1, logCommand(replay.Ready, 1, nil), table.ErrRoundNotOver},
+		{2, logCommand(replay.Pass, 1, hand[:table.PassSize]), replay.ErrWrongWriter},
+	}
+	before, _ := json.Marshal(t.Snapshot())
+	for _, e := range entries {
+		events := applyEntry(test, r, &n, e.writer, e.value)
+		if v, ok := events[0].(replay.ViolationEvent); !ok || v.Writer != e.writer || !errors.Is(v.Err, e.err) {
+			test.Errorf("Expected %s to be a violation with %v, got %v", e.value, e.err, events)
+		}
+	}
+	// an entry whose key names no writer is skipped
+	events, err := r.Apply("1/log/99-one", logCommand(replay.Pass, 1, hand[:table.PassSize]))
+	if v, ok := events[0].(replay.ViolationEvent); err != nil || !ok || v.Writer != -1 || !errors.Is(v.Err, replay.ErrMalformedEntry) {
+		test.Errorf("Expected an entry under an unreadable key to be a violation, got %v %v", events, err)
+	}
+	after, _ := json.Marshal(t.Snapshot())
+	if string(before) != string(after) {
+		test.Errorf("Expected violations to leave the table unchanged")
+	}
+	if len(r.Violations()) != len(entries)+1 {
+		test.Errorf("Expected %d violations to be recorded, got %d", len(entries)+1, len(r.Violations()))
+	}
+	applyEntry(test, r, &n, 1, logCommand(replay.Pass, 1, hand[:table.PassSize]))
+	if len(r.Violations()) != len(entries)+1 || !t.GetPlayers()[1].GetDonePassing() {
+		test.Errorf("Expected a valid pass to be applied")
+	}
+}
+
+// Testing agreement on the lowest of two conflicting proposals, and that it is settled once committed
+func TestReplayProposals(test *testing.T) {
+	numPlayers := 4
+	r := replay.New(table.InitializeGame(numPlayers, table.ClassicRules()), true)
+	propose := func(seat int, p *replay.Proposal) replay.ProposalEvent {
+		value, _ := p.Encode()
+		events, err := r.Apply(replay.ProposalKey(1, seat), value)
+		if err != nil || len(events) != 1 {
+			test.Fatalf("Expected one event for the proposal of seat %d, got %v %v", seat, events, err)
+		}
+		return events[0].(replay.ProposalEvent)
+	}
+	late := &replay.Proposal{Timestamp: 20, Command: logCommand(replay.Ready, 2, nil), PlayerNumber: 2}
+	early := &replay.Proposal{Timestamp: 20, Command: logCommand(replay.Ready, 1, nil), PlayerNumber: 1}
+	if e := propose(2, late); !e.Lowest.Equal(late) || e.Agreed {
+		test.Errorf("Expected the only proposal to be the lowest, got %v", e.Lowest)
+	}
+	if e := propose(1, early); !e.Lowest.Equal(early) || e.Agreed {
+		test.Errorf("Expected a tie to go to the lower player number, got %v", e.Lowest)
+	}
+	var e replay.ProposalEvent
+	for _, seat := range []int{0, 2, 3} {
+		e = propose(seat, early)
+	}
+	if !e.Agreed || !r.AgreedProposal().Equal(early) || !e.Lowest.Equal(early) {
+		test.Errorf("Expected every seat to agree with the earlier proposal")
+	}
+	// the commit is written under a later timestamp than the proposal, and names it
+	commit, err := early.Commit()
+	if err != nil || commit != "Ready|1:proposal 20-1:END" {
+		test.Fatalf("Expected the commit to name the proposal, got %q %v", commit, err)
+	}
+	if _, err := r.Apply(replay.LogKey(1, 35, 1), commit); err != nil {
+		test.Errorf("Apply error for the committed proposal: %v", err)
+	}
+	if r.LowestProposal() != nil || !r.Table().GetPlayers()[1].GetDoneScoring() {
+		test.Errorf("Expected the committed proposal to be run and settled")
+	}
+	value, _ := early.Encode()
+	if events, err := r.Apply(replay.ProposalKey(1, 3), value); len(events) != 0 || err != nil {
+		test.Errorf("Expected a committed proposal read again to be ignored, got %v %v", events, err)
+	}
+	if _, err := r.Apply(replay.ProposalKey(1, 7), value); !errors.Is(err, replay.ErrMalformedEntry) {
+		test.Errorf("Expected a proposal from a seat not at the table to be rejected, got %v", err)
+	}
+	if seat, ok := replay.ParseProposalKey("1/log/proposals/3"); !ok || seat != 3 {
+		test.Errorf("Expected ParseProposalKey to read seat 3, got %d %t", seat, ok)
+	}
+}
+
+// Testing which status changes a game may make
+func TestStatusTransitions(test *testing.T) {
+	tests := []struct {
+		from  replay.Status
+		to    replay.Status
+		valid bool
+	}{
+		{replay.NoStatus, replay.Created, true},
+		{replay.NoStatus, replay.Running, false},
+		{replay.Created, replay.Running, true},
+		{replay.Created, replay.Abandoned, true},
+		{replay.Created, replay.Finished, false},
+		{replay.Running, replay.Finished, true},
+		{replay.Running, replay.Created, false},
+		{replay.Running, replay.Paused, true},
+		{replay.Paused, replay.Running, true},
+		{replay.Paused, replay.Abandoned, true},
+		{replay.Paused, replay.Finished, false},
+		{replay.Created, replay.Paused, false},
+		{replay.Finished, replay.Running, true},
+		{replay.Finished, replay.Abandoned, false},
+		{replay.Abandoned, replay.Running, false},
+		{replay.Abandoned, replay.Created, false},
+	}
+	for _, t := range tests {
+		err := t.from.ValidTransition(t.to)
+		if t.valid && err != nil {
+			test.Errorf("Expected %q -> %q to be valid, got %v", t.from, t.to, err)
+		} else if !t.valid && !errors.Is(err, replay.ErrBadTransition) {
+			test.Errorf("Expected %q -> %q to be rejected, got %v", t.from, t.to, err)
+		}
+	}
+	for _, s := range []replay.Status{replay.Finished, replay.Abandoned} {
+		if !s.Over() {
+			test.Errorf("Expected %q to be over", s)
+		}
+	}
+	for _, s := range []replay.Status{replay.Running, replay.Paused} {
+		if s.Over() || !s.Started() {
+			test.Errorf("Expected %q to be started and not over", s)
+		}
+	}
+	if replay.Created.Started() || replay.Finished.Started() {
+		test.Errorf("Expected games which haven't started or have ended not to be started")
+	}
+}
+
+// Testing that the status and result keys are read into events
+func TestReplayStatus(test *testing.T) {
+	r := replay.New(table.InitializeGame(4, table.ClassicRules()), true)
+	// a device reading the log late may find the game already running
+	events, err := r.Apply(replay.StatusKey(1), "RUNNING")
+	if err != nil || len(events) != 1 || events[0].(replay.StatusEvent).Status != replay.Running || r.Status() != replay.Running {
+		test.Errorf("Expected a RUNNING status event, got %v %v", events, err)
+	}
+	if _, err := r.Apply(replay.StatusKey(1), "SLEEPING"); !errors.Is(err, replay.ErrMalformedEntry) {
+		test.Errorf("Expected an unknown status to be rejected, got %v", err)
+	}
+	events, err = r.Apply(replay.StatusKey(1), "CREATED")
+	if err != nil || len(events) != 1 || !errors.Is(events[0].(replay.ViolationEvent).Err, replay.ErrBadTransition) {
+		test.Errorf("Expected a running game moving back to CREATED to be a violation, got %v %v", events, err)
+	}
+	if r.Status() != replay.Running || len(r.Violations()) != 1 {
+		test.Errorf("Expected an invalid status to leave the status unchanged, got %q", r.Status())
+	}
+	events, err = r.Apply(replay.StatusKey(1), "PAUSED")
+	if err != nil || len(events) != 1 || r.Status() != replay.Paused {
+		test.Errorf("Expected a running game to be paused, got %v %v", events, err)
+	}
+	if events, _ = r.Apply(replay.StatusKey(1), "RUNNING"); len(events) != 1 || r.Status() != replay.Running {
+		test.Errorf("Expected a paused game to be resumed, got %v %q", events, r.Status())
+	}
+	r.Apply(replay.StatusKey(1), "ABANDONED")
+	if events, _ = r.Apply(replay.StatusKey(1), "RUNNING"); len(events) != 1 || r.Status() != replay.Abandoned {
+		test.Errorf("Expected an abandoned game to stay abandoned, got %v %q", events, r.Status())
+	}
+	value, _ := (&replay.Result{Scores: []int{101, 40, 62, 57}, Winners: []int{1}}).Encode()
+	events, err = r.Apply(replay.ResultKey(1), value)
+	if err != nil || len(events) != 1 {
+		test.Fatalf("Expected a result event, got %v %v", events, err)
+	}
+	if result := events[0].(replay.ResultEvent).Result; !reflect.DeepEqual(result.Scores, []int{101, 40, 62, 57}) || !reflect.DeepEqual(result.Winners, []int{1}) {
+		test.Errorf("Expected the result to be read back, got %v", result)
+	}
+}
+
+// Testing that a player who runs out of time may only have their lowest legal card played for them
+func TestReplayTimeout(test *testing.T) {
+	numPlayers := 4
+	r := replay.New(table.InitializeGame(numPlayers, table.ClassicRules()), true)
+	t := r.Table()
+	s := ai.NewHeuristic()
+	n := 0
+	events, err := r.Apply("1/turn_limit", "30")
+	if l, ok := events[0].(replay.TurnLimitEvent); err != nil || !ok || l.Limit != 30*time.Second || r.TurnLimit() != 30*time.Second {
+		test.Errorf("Expected a turn limit of 30 seconds, got %v %v", events, err)
+	}
+	if _, err := r.Apply("1/turn_limit", "-3"); !errors.Is(err, replay.ErrMalformedEntry) || r.TurnLimit() != 30*time.Second {
+		test.Errorf("Expected a negative turn limit to be rejected, got %v", err)
+	}
+	for i := 0; i < numPlayers; i++ {
+		r.Apply(fmt.Sprintf("1/players/%d/player_number", 100+i), fmt.Sprintf("%d", i))
+	}
+	for i, h := range t.DealSeed(5) {
+		applyEntry(test, r, &n, 0, logCommand(replay.Deal, i, h))
+	}
+	for i := 0; i < numPlayers; i++ {
+		applyEntry(test, r, &n, i, logCommand(replay.Pass, i, s.Pass(t, i)))
+	}
+	for i := 0; i < numPlayers; i++ {
+		applyEntry(test, r, &n, i, logCommand(replay.Take, i, nil))
+	}
+	// the first trick, so that the next lead has a choice of cards
+	for !t.TrickOver() {
+		i := t.WhoseTurn()
+		applyEntry(test, r, &n, i, logCommand(replay.Play, i, []*card.Card{s.Play(t, i)}))
+	}
+	applyEntry(test, r, &n, t.GetTrickRecipient(), logCommand(replay.TakeTrick, t.GetTrickRecipient(), nil))
+	player := t.WhoseTurn()
+	lowest := t.LowestLegalPlay(player)
+	legal := t.LegalPlays(player)
+	if lowest == nil || len(legal) < 2 {
+		test.Fatalf("Expected player %d to have a choice of plays, got %v", player, legal)
+	}
+	for _, c := range legal {
+		if c.GetFace() < lowest.GetFace() {
+			test.Errorf("Expected %v to be the lowest legal play, but %v is lower", lowest, c)
+		}
+	}
+	other := legal[0]
+	if other == lowest {
+		other = legal[1]
+	}
+	events = applyEntry(test, r, &n, player, logCommand(replay.Timeout, player, []*card.Card{other}))
+	if v, ok := events[0].(replay.ViolationEvent); !ok || !errors.Is(v.Err, replay.ErrNotLowestPlay) {
+		test.Errorf("Expected a timeout playing %v rather than %v to be a violation, got %v", other, lowest, events)
+	}
+	events = applyEntry(test, r, &n, player, logCommand(replay.Timeout, player, []*card.Card{lowest}))
+	if p, ok := events[0].(replay.PlayEvent); !ok || !p.TimedOut || p.Player != player || p.Card != lowest {
+		test.Errorf("Expected %v to be played for player %d, got %v", lowest, player, events)
+	}
+	if t.GetPlayers()[player].HasCard(lowest) || t.WhoseTurn() == player {
+		test.Errorf("Expected the timeout to play %v from player %d's hand", lowest, player)
+	}
+}
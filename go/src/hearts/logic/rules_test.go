@@ -0,0 +1,172 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"hearts/logic/card"
+	"hearts/logic/table"
+	"testing"
+)
+
+// Returns the cards in deck which match the filter
+func filterCards(deck []*card.Card, filter func(c *card.Card) bool) []*card.Card {
+	cards := make([]*card.Card, 0)
+	for _, c := range deck {
+		if filter(c) {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+func isMoonCard(c *card.Card) bool {
+	return c.WorthPoints()
+}
+
+func isJackOfDiamonds(c *card.Card) bool {
+	return c.GetSuit() == card.Diamond && c.GetFace() == card.Jack
+}
+
+func isAnyCard(c *card.Card) bool {
+	return true
+}
+
+// Testing scoring under each rule variant
+func TestRulesScoring(test *testing.T) {
+	tests := []struct {
+		name   string
+		rules  table.Rules
+		tricks [][]func(c *card.Card) bool
+		expect []int
+	}{
+		{
+			name:   "classic moon",
+			rules:  *table.ClassicRules(),
+			tricks: [][]func(c *card.Card) bool{{isMoonCard}, nil, nil, nil},
+			expect: []int{0, 26, 26, 26},
+		},
+		{
+			name:   "subtract from shooter",
+			rules:  table.Rules{Moon: table.SubtractFromShooter},
+			tricks: [][]func(c *card.Card) bool{{isMoonCard}, nil, nil, nil},
+			expect: []int{-26, 0, 0, 0},
+		},
+		{
+			name:   "add to self",
+			rules:  table.Rules{Moon: table.AddToSelf},
+			tricks: [][]func(c *card.Card) bool{{isMoonCard}, nil, nil, nil},
+			expect: []int{26, 0, 0, 0},
+		},
+		{
+			name:   "shoot the sun",
+			rules:  table.Rules{ShootTheSun: true},
+			tricks: [][]func(c *card.Card) bool{{isAnyCard}, nil, nil, nil},
+			expect: []int{0, 52, 52, 52},
+		},
+		{
+			name:   "shoot the sun subtract from shooter",
+			rules:  table.Rules{ShootTheSun: true, Moon: table.SubtractFromShooter},
+			tricks: [][]func(c *card.Card) bool{{isAnyCard}, nil, nil, nil},
+			expect: []int{-52, 0, 0, 0},
+		},
+		{
+			name:   "shoot the sun without every trick",
+			rules:  table.Rules{ShootTheSun: true},
+			tricks: [][]func(c *card.Card) bool{{isMoonCard}, nil, nil, nil},
+			expect: []int{0, 26, 26, 26},
+		},
+		{
+			name:   "jack of diamonds",
+			rules:  table.Rules{JackOfDiamonds: true},
+			tricks: [][]func(c *card.Card) bool{{isMoonCard}, {isJackOfDiamonds}, nil, nil},
+			expect: []int{0, 16, 26, 26},
+		},
+		{
+			name:   "jack of diamonds ignored",
+			rules:  *table.ClassicRules(),
+			tricks: [][]func(c *card.Card) bool{{isMoonCard}, {isJackOfDiamonds}, nil, nil},
+			expect: []int{0, 26, 26, 26},
+		},
+		{
+			name:   "jack of diamonds taken by shooter",
+			rules:  table.Rules{JackOfDiamonds: true, ShootTheSun: true},
+			tricks: [][]func(c *card.Card) bool{{isAnyCard}, nil, nil, nil},
+			expect: []int{-10, 52, 52, 52},
+		},
+	}
+	for _, tt := range tests {
+		rules := tt.rules
+		t := table.InitializeGame(len(tt.expect), &rules)
+		for i, filters := range tt.tricks {
+			for _, f := range filters {
+				t.GetPlayers()[i].TakeTrick(filterCards(t.GetAllCards(), f))
+			}
+		}
+		scores := t.ScoreRound()
+		for i, expect := range tt.expect {
+			if scores[i] != expect {
+				test.Errorf("%s: expected %d for player %d, got %d", tt.name, expect, i, scores[i])
+			}
+		}
+	}
+}
+
+// Testing playing a card-- ValidPlayLogic() under each first trick rule
+func TestRulesFirstTrick(test *testing.T) {
+	tests := []struct {
+		name   string
+		rules  table.Rules
+		played *card.Card
+		expect error
+	}{
+		{"classic queen of spades", *table.ClassicRules(), card.NewCard(card.Queen, card.Spade), table.ErrPointsOnFirstTrick},
+		{"classic heart", *table.ClassicRules(), card.NewCard(card.Four, card.Heart), table.ErrPointsOnFirstTrick},
+		{"classic diamond", *table.ClassicRules(), card.NewCard(card.Three, card.Diamond), nil},
+		{"points allowed queen of spades", table.Rules{PointsOnFirstTrick: true}, card.NewCard(card.Queen, card.Spade), nil},
+		{"points allowed heart", table.Rules{PointsOnFirstTrick: true}, card.NewCard(card.Four, card.Heart), nil},
+	}
+	for _, tt := range tests {
+		rules := tt.rules
+		t := table.InitializeGame(2, &rules)
+		players := t.GetPlayers()
+		players[1].AddToHand(card.NewCard(card.Queen, card.Spade))
+		players[1].AddToHand(card.NewCard(card.Four, card.Heart))
+		players[1].AddToHand(card.NewCard(card.Three, card.Diamond))
+		t.SetFirstPlayer(0)
+		t.SetPlayedCard(card.NewCard(card.Two, card.Club), 0)
+		if err := t.ValidPlayLogic(tt.played, 1); !errors.Is(err, tt.expect) {
+			test.Errorf("%s: expected %v, got %v", tt.name, tt.expect, err)
+		}
+	}
+}
+
+// Testing that a player whose moon shots left them below zero can still win the game
+func TestRulesNegativeWinner(test *testing.T) {
+	t := table.InitializeGame(4, &table.Rules{Moon: table.SubtractFromShooter})
+	for i, score := range []int{-1, 105, 50, 60} {
+		t.GetPlayers()[i].UpdateScore(score)
+	}
+	_, winners := t.EndRound()
+	if len(winners) != 1 || winners[0] != 0 {
+		test.Errorf("Expected player 0 to win with a negative score, got %v", winners)
+	}
+}
+
+// Testing that a table set up without rules is played with the classic rules
+func TestRulesDefault(test *testing.T) {
+	t := table.InitializeGame(4, nil)
+	if t.GetRules() == nil || *t.GetRules() != *table.ClassicRules() {
+		test.Fatalf("Expected the classic rules, got %v", t.GetRules())
+	}
+	players := t.GetPlayers()
+	players[1].AddToHand(card.NewCard(card.Queen, card.Spade))
+	players[1].AddToHand(card.NewCard(card.Three, card.Diamond))
+	t.SetFirstPlayer(0)
+	t.SetPlayedCard(card.NewCard(card.Two, card.Club), 0)
+	if err := t.ValidPlayLogic(card.NewCard(card.Queen, card.Spade), 1); !errors.Is(err, table.ErrPointsOnFirstTrick) {
+		test.Errorf("Expected points on the first trick to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,544 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hearts/logic/card"
+	"hearts/logic/table"
+	"hearts/replay"
+	"testing"
+)
+
+// Testing that sealed cards can be opened only with the keys they were sealed to, and revealed only by their opening
+func TestSeal(test *testing.T) {
+	keys := make([]*replay.HandKey, 3)
+	for i := range keys {
+		k, err := replay.NewHandKey()
+		if err != nil {
+			test.Fatalf("NewHandKey error: %v", err)
+		}
+		keys[i] = k
+	}
+	cards := []*card.Card{card.NewCard(card.Ten, card.Heart), card.NewCard(card.Queen, card.Spade)}
+	sealed, opening, err := replay.Seal(cards, []string{keys[0].Public(), keys[1].Public()})
+	if err != nil {
+		test.Fatalf("Seal error: %v", err)
+	}
+	for i, k := range keys[:2] {
+		if o, ok := k.Open(sealed); !ok || o != opening {
+			test.Errorf("Expected key %d to open the sealed cards", i)
+		}
+	}
+	if _, ok := keys[2].Open(sealed); ok {
+		test.Errorf("Expected a key the cards weren't sealed to not to open them")
+	}
+	parsed, err := replay.ParseHandKey(keys[2].String())
+	if err != nil || parsed.Public() != keys[2].Public() {
+		test.Errorf("Expected a key to parse back to itself, got %v", err)
+	}
+	revealed, err := sealed.Reveal(opening)
+	if err != nil || len(revealed) != len(cards) {
+		test.Fatalf("Expected the opening to reveal %d cards, got %v %v", len(cards), revealed, err)
+	}
+	for i, c := range revealed {
+		if c.GetSuit() != cards[i].GetSuit() || c.GetFace() != cards[i].GetFace() {
+			test.Errorf("Expected card %d to be revealed as %v, got %v", i, cards[i], c)
+		}
+	}
+	other, otherOpening, _ := replay.Seal(cards, []string{keys[0].Public()})
+	if _, err := sealed.Reveal(otherOpening); !errors.Is(err, replay.ErrBadSeal) {
+		test.Errorf("Expected the opening of other cards to be rejected, got %v", err)
+	}
+	if other.Commitment == sealed.Commitment {
+		test.Errorf("Expected the same cards sealed twice to have different commitments")
+	}
+}
+
+// a device at a table whose hands are sealed, holding the key of the seat it plays
+type sealedDevice struct {
+	key *replay.HandKey
+	r   *replay.Replayer
+}
+
+// Returns a device for each seat of a running game whose players are ready for the first deal
+func newSealedDevices(test *testing.T, numPlayers int, log *sealedLog) []*sealedDevice {
+	devices := make([]*sealedDevice, numPlayers)
+	for i := range devices {
+		k, err := replay.NewHandKey()
+		if err != nil {
+			test.Fatalf("NewHandKey error: %v", err)
+		}
+		devices[i] = &sealedDevice{key: k, r: replay.New(table.InitializeGame(numPlayers, table.ClassicRules()), true)}
+		devices[i].r.SetHandKey(k)
+	}
+	for _, d := range devices {
+		seatSealedPlayers(d.r, devices)
+	}
+	for i := range devices {
+		applySealed(test, devices, log, i, &replay.GameCommand{Type: replay.Ready, Player: i})
+	}
+	log.add(replay.StatusKey(1), string(replay.Running))
+	for _, d := range devices {
+		d.r.Apply(replay.StatusKey(1), string(replay.Running))
+	}
+	return devices
+}
+
+// Seats the player of each device on r, along with the key their cards are sealed to and the key they sign with
+func seatSealedPlayers(r *replay.Replayer, devices []*sealedDevice) {
+	for i, d := range devices {
+		r.Apply(fmt.Sprintf("1/players/%d/hand_key", 100+i), d.key.Public())
+		r.Apply(fmt.Sprintf("1/players/%d/sign_key", 100+i), d.key.SignKey())
+		r.Apply(fmt.Sprintf("1/players/%d/player_number", 100+i), fmt.Sprintf("%d", i))
+	}
+}
+
+// the entries written to the log of a sealed game, in the order they were written
+type sealedLog struct {
+	n      int // the timestamp of the last command
+	keys   []string
+	values []string
+}
+
+func (l *sealedLog) add(key, value string) {
+	l.keys = append(l.keys, key)
+	l.values = append(l.values, value)
+}
+
+// Writes c as the player at playerIndex, signed by their device, and applies it on every device, returning the events of each
+func applySealed(test *testing.T, devices []*sealedDevice, log *sealedLog, playerIndex int, c *replay.GameCommand) [][]replay.Event {
+	value, err := c.Encode()
+	if err != nil {
+		test.Fatalf("Encode error for %v: %v", c, err)
+	}
+	log.n++
+	key := fmt.Sprintf("1/log/%d-%d", log.n, playerIndex)
+	if value, err = devices[playerIndex].key.Sign(key, value); err != nil {
+		test.Fatalf("Sign error for %v: %v", c, err)
+	}
+	log.add(key, value)
+	all := make([][]replay.Event, len(devices))
+	for i, d := range devices {
+		events, err := d.r.Apply(key, value)
+		if err != nil {
+			test.Fatalf("Apply error on device %d for %s: %v", i, value, err)
+		}
+		all[i] = events
+	}
+	return all
+}
+
+func violationsIn(events []replay.Event) []replay.ViolationEvent {
+	found := make([]replay.ViolationEvent, 0)
+	for _, e := range events {
+		if v, ok := e.(replay.ViolationEvent); ok {
+			found = append(found, v)
+		}
+	}
+	return found
+}
+
+// Deals, passes and plays a whole round with sealed hands, each player moving from what their own device can see
+// Each play is chosen by choose if it returns a card, otherwise the first legal play is made, unless choose wrote one
+// Returns the hand each device was dealt, failing test if any device finds a violation
+func playSealedRound(test *testing.T, devices []*sealedDevice, log *sealedLog, choose func(t *table.Table, playerIndex int) *card.Card) [][]*card.Card {
+	check := func(all [][]replay.Event) {
+		for i, events := range all {
+			if v := violationsIn(events); len(v) > 0 {
+				test.Fatalf("Expected device %d to accept the move, got %v", i, v)
+			}
+		}
+	}
+	// every device writes the steps of its own seat, as soon as it has one to write
+	for dealing := true; dealing; {
+		dealing = false
+		for i, d := range devices {
+			c, err := d.r.DealStep(i)
+			if err != nil {
+				test.Fatalf("DealStep error on device %d: %v", i, err)
+			}
+			if c != nil {
+				check(applySealed(test, devices, log, i, c))
+				dealing = true
+			}
+		}
+	}
+	hands := make([][]*card.Card, len(devices))
+	for i, d := range devices {
+		hands[i] = append(hands[i], d.r.Table().GetPlayers()[i].GetHand()...)
+		if len(hands[i]) != 52/len(devices) {
+			test.Fatalf("Expected device %d to be dealt %d cards, got %v", i, 52/len(devices), hands[i])
+		}
+	}
+	for i, d := range devices {
+		t := d.r.Table()
+		recipient := t.GetPassRecipient(i)
+		keys := []string{d.key.Public(), devices[recipient].key.Public()}
+		sealed, _, err := replay.Seal(t.LegalPasses(i)[:table.PassSize], keys)
+		if err != nil {
+			test.Fatalf("Seal error: %v", err)
+		}
+		check(applySealed(test, devices, log, i, &replay.GameCommand{Type: replay.Pass, Player: i, Sealed: sealed}))
+	}
+	for i := range devices {
+		check(applySealed(test, devices, log, i, &replay.GameCommand{Type: replay.Take, Player: i}))
+	}
+	public := devices[0].r.Table()
+	for !public.RoundOver() || !public.TrickNew() {
+		if public.TrickOver() {
+			recipient := public.GetTrickRecipient()
+			check(applySealed(test, devices, log, recipient, &replay.GameCommand{Type: replay.TakeTrick, Player: -1}))
+			continue
+		}
+		player := -1
+		for i, d := range devices {
+			if d.r.Table().WhoseTurn() == i {
+				player = i
+			}
+		}
+		if player < 0 {
+			test.Fatalf("Expected one device to know whose turn it is")
+		}
+		t := devices[player].r.Table()
+		c := choose(t, player)
+		if t.GetPlayers()[player].GetDonePlaying() {
+			// choose played for the player itself
+			continue
+		}
+		if c == nil {
+			c = t.LegalPlays(player)[0]
+		}
+		check(applySealed(test, devices, log, player, &replay.GameCommand{Type: replay.Play, Player: player, Cards: []*card.Card{c}}))
+	}
+	return hands
+}
+
+// Reveals the sealed cards of every player from their own device, returning the events of the last reveal
+func revealSealedRound(test *testing.T, devices []*sealedDevice, log *sealedLog) [][]replay.Event {
+	var all [][]replay.Event
+	for i, d := range devices {
+		due := d.r.DueReveals()
+		if len(due) != 1 || len(due[i]) != 2 {
+			test.Fatalf("Expected device %d to owe only the reveal of its own seed and pass, got %v", i, due)
+		}
+		all = applySealed(test, devices, log, i, &replay.GameCommand{Type: replay.Reveal, Player: i, Openings: due[i]})
+	}
+	return all
+}
+
+// Testing that a round with sealed hands is hidden from the other players, and audited once every hand is revealed
+// Player 2 sits across from player 0, so neither passes cards to the other
+func TestSealedRound(test *testing.T) {
+	log := &sealedLog{}
+	devices := newSealedDevices(test, 4, log)
+	var seen, hidden []*card.Card
+	hands := playSealedRound(test, devices, log, func(t *table.Table, playerIndex int) *card.Card {
+		if seen == nil {
+			seen = append(seen, devices[0].r.Table().GetPlayers()[0].GetHand()...)
+			hidden = append(hidden, devices[2].r.Table().GetPlayers()[0].GetHand()...)
+		}
+		return nil
+	})
+	for _, c := range seen {
+		if c.Hidden() {
+			test.Errorf("Expected a device to see its own hand")
+			break
+		}
+	}
+	for _, c := range hidden {
+		if !c.Hidden() {
+			test.Errorf("Expected a device to see only hidden cards in another player's hand, got %v", c)
+			break
+		}
+	}
+	for i, d := range devices[1:] {
+		for p, player := range d.r.Table().GetPlayers() {
+			if player.GetScore() != devices[0].r.Table().GetPlayers()[p].GetScore() {
+				test.Errorf("Expected device %d to score player %d as device 0 does", i+1, p)
+			}
+		}
+	}
+	for i, events := range applySealed(test, devices, log, 0, &replay.GameCommand{Type: replay.Ready, Player: 0}) {
+		if v := violationsIn(events); len(v) != 1 || !errors.Is(v[0].Err, replay.ErrNotRevealed) {
+			test.Errorf("Expected device %d to refuse a ready before the reveal, got %v", i, events)
+		}
+	}
+	forged := devices[0].r.DueReveals()[0]
+	for i, events := range applySealed(test, devices, log, 1, &replay.GameCommand{Type: replay.Reveal, Player: 1, Openings: forged}) {
+		if v := violationsIn(events); len(v) != 1 || !errors.Is(v[0].Err, replay.ErrBadSeal) {
+			test.Errorf("Expected device %d to refuse another player's openings, got %v", i, events)
+		}
+	}
+	for i, events := range revealSealedRound(test, devices, log) {
+		if len(events) != 2 {
+			test.Fatalf("Expected the last reveal on device %d to be followed by a clean audit, got %v", i, events)
+		}
+		if r, ok := events[0].(replay.RevealEvent); !ok || len(r.Cards) != len(hands[3]) {
+			test.Errorf("Expected device %d to reveal the hand dealt to player 3, got %v", i, events[0])
+		} else {
+			for j, c := range r.Cards {
+				if c.GetSuit() != hands[3][j].GetSuit() || c.GetFace() != hands[3][j].GetFace() {
+					test.Errorf("Expected card %d of player 3 to be revealed as %v, got %v", j, hands[3][j], c)
+				}
+			}
+		}
+		if a, ok := events[1].(replay.AuditEvent); !ok || len(a.Violations) != 0 {
+			test.Errorf("Expected device %d to audit the round cleanly, got %v", i, events[1])
+		}
+	}
+	for i := range devices {
+		for j, events := range applySealed(test, devices, log, i, &replay.GameCommand{Type: replay.Ready, Player: i}) {
+			if v := violationsIn(events); len(v) > 0 {
+				test.Errorf("Expected device %d to accept player %d getting ready, got %v", j, i, v)
+			}
+		}
+	}
+}
+
+// Testing that a play no device could check while the hands were sealed is found by the audit
+func TestSealedAudit(test *testing.T) {
+	log := &sealedLog{}
+	devices := newSealedDevices(test, 4, log)
+	cheated := false
+	playSealedRound(test, devices, log, func(t *table.Table, playerIndex int) *card.Card {
+		if cheated || t.GetFirstPlayer() < 0 || t.GetTrick()[t.GetFirstPlayer()] == nil {
+			return nil
+		}
+		lead := t.GetTrick()[t.GetFirstPlayer()]
+		hand := t.GetPlayers()[playerIndex].GetHand()
+		if !t.GetPlayers()[playerIndex].HasSuit(lead.GetSuit()) {
+			return nil
+		}
+		for _, c := range hand {
+			if c.GetSuit() != lead.GetSuit() {
+				cheated = true
+				return c
+			}
+		}
+		return nil
+	})
+	if !cheated {
+		test.Fatalf("Expected a player to be able to fail to follow suit")
+	}
+	for i, events := range revealSealedRound(test, devices, log) {
+		v := violationsIn(events)
+		if len(v) == 0 || !errors.Is(v[0].Err, replay.ErrAudit) || !errors.Is(v[0].Err, table.ErrMustFollowSuit) {
+			test.Errorf("Expected device %d to find the player failing to follow suit, got %v", i, v)
+		}
+		if a, ok := events[len(events)-1].(replay.AuditEvent); !ok || len(a.Violations) != len(v) {
+			test.Errorf("Expected device %d to end the audit with its violations, got %v", i, events[len(events)-1])
+		}
+	}
+}
+
+// Testing that the steps of a deal are taken in turn, each player shuffling with a seed of their own
+func TestSealedDealOrder(test *testing.T) {
+	log := &sealedLog{}
+	devices := newSealedDevices(test, 4, log)
+	if c, err := devices[1].r.DealStep(1); c != nil || err != nil {
+		test.Errorf("Expected player 1 to wait for player 0 to start the deal, got %v %v", c, err)
+	}
+	start, err := devices[0].r.DealStep(0)
+	if err != nil || start == nil || start.Type != replay.Shuffle {
+		test.Fatalf("Expected player 0 to start the deal with a shuffle, got %v %v", start, err)
+	}
+	lock := &replay.GameCommand{Type: replay.Lock, Player: 0, Points: start.Points}
+	for i, events := range applySealed(test, devices, log, 0, lock) {
+		if v := violationsIn(events); len(v) != 1 || !errors.Is(v[0].Err, replay.ErrDealOrder) {
+			test.Errorf("Expected device %d to refuse a lock before the deck is shuffled, got %v", i, events)
+		}
+	}
+	applySealed(test, devices, log, 0, start)
+	if c, _ := devices[1].r.DealStep(1); c == nil || c.Type != replay.Shuffle || c.Commitment == start.Commitment {
+		test.Errorf("Expected player 1 to shuffle next with a seed of their own, got %v", c)
+	}
+	if c, _ := devices[0].r.DealStep(0); c != nil {
+		test.Errorf("Expected player 0 to wait for the other players to shuffle, got %v", c)
+	}
+}
+
+// Testing that a device holding no key reproduces every hand of a sealed round from the seeds its players reveal
+func TestSealedReproduce(test *testing.T) {
+	log := &sealedLog{}
+	devices := newSealedDevices(test, 4, log)
+	hands := playSealedRound(test, devices, log, func(t *table.Table, playerIndex int) *card.Card { return nil })
+	revealSealedRound(test, devices, log)
+	r := replay.New(table.InitializeGame(len(devices), table.ClassicRules()), true)
+	seatSealedPlayers(r, devices)
+	revealed := make(map[int][]*card.Card)
+	for i, key := range log.keys {
+		events, err := r.Apply(key, log.values[i])
+		if err != nil {
+			test.Fatalf("Apply error for %s: %v", log.values[i], err)
+		}
+		if v := violationsIn(events); len(v) > 0 {
+			test.Fatalf("Expected a device holding no key to accept %s, got %v", log.values[i], v)
+		}
+		for _, e := range events {
+			if e, ok := e.(replay.RevealEvent); ok {
+				revealed[e.Player] = e.Cards
+			}
+		}
+	}
+	for p, h := range hands {
+		if len(revealed[p]) != len(h) {
+			test.Fatalf("Expected the hand of player %d to be reproduced, got %v", p, revealed[p])
+		}
+		for j, c := range revealed[p] {
+			if c.GetSuit() != h[j].GetSuit() || c.GetFace() != h[j].GetFace() {
+				test.Errorf("Expected card %d of player %d to be reproduced as %v, got %v", j, p, h[j], c)
+			}
+		}
+	}
+}
+
+// Testing that the hand of a player who stalls is released by the other players, after which a Timeout written for
+// them by another player is checked in full, and that the player counts as revealed when the round is audited
+func TestSealedRelease(test *testing.T) {
+	log := &sealedLog{}
+	devices := newSealedDevices(test, 4, log)
+	stalled, refused := -1, 0
+	playSealedRound(test, devices, log, func(t *table.Table, playerIndex int) *card.Card {
+		if stalled >= 0 || t.TrickNew() {
+			return nil
+		}
+		stalled = playerIndex
+		writer := (stalled + 1) % len(devices)
+		if c := devices[writer].r.ReleaseHand(stalled, stalled); c != nil {
+			test.Errorf("Expected no player to release a share of their own seed, got %v", c)
+		}
+		for i, d := range devices {
+			if i == stalled {
+				continue
+			}
+			c := d.r.ReleaseHand(stalled, i)
+			if c == nil {
+				test.Fatalf("Expected device %d to hold a share of the seed of player %d", i, stalled)
+			}
+			all := applySealed(test, devices, log, i, c)
+			for j, events := range all {
+				if v := violationsIn(events); len(v) > 0 {
+					test.Fatalf("Expected device %d to accept the release by player %d, got %v", j, i, v)
+				}
+			}
+			if i == writer {
+				for j, events := range applySealed(test, devices, log, i, c) {
+					if len(events) != 0 {
+						test.Errorf("Expected device %d to ignore a release written twice, got %v", j, events)
+					}
+				}
+			}
+		}
+		want := t.GetPlayers()[stalled].GetHand()
+		for i, d := range devices {
+			hand := d.r.Table().GetPlayers()[stalled].GetHand()
+			if len(withoutHidden(hand)) != len(want) {
+				test.Fatalf("Expected device %d to hold the released hand %v, got %v", i, want, hand)
+			}
+		}
+		lowest := devices[writer].r.Table().LowestLegalPlay(stalled)
+		for _, c := range devices[writer].r.Table().LegalPlays(stalled) {
+			if c == lowest {
+				continue
+			}
+			refused++
+			for j, events := range applySealed(test, devices, log, writer, &replay.GameCommand{Type: replay.Timeout, Player: stalled, Cards: []*card.Card{c}}) {
+				if v := violationsIn(events); len(v) != 1 || !errors.Is(v[0].Err, replay.ErrNotLowestPlay) {
+					test.Errorf("Expected device %d to refuse a Timeout of another card of the released hand, got %v", j, events)
+				}
+			}
+			break
+		}
+		for j, events := range applySealed(test, devices, log, writer, &replay.GameCommand{Type: replay.Timeout, Player: stalled, Cards: []*card.Card{lowest}}) {
+			if v := violationsIn(events); len(v) > 0 {
+				test.Errorf("Expected device %d to accept another player's Timeout for the released hand, got %v", j, v)
+			}
+		}
+		return nil
+	})
+	if stalled < 0 {
+		test.Fatalf("Expected a player to stall")
+	}
+	audited := make([]bool, len(devices))
+	for i, d := range devices {
+		due := d.r.DueReveals()
+		if i == stalled {
+			if len(due) != 0 {
+				test.Errorf("Expected the released player to owe no reveal, got %v", due)
+			}
+			continue
+		}
+		for j, events := range applySealed(test, devices, log, i, &replay.GameCommand{Type: replay.Reveal, Player: i, Openings: due[i]}) {
+			if v := violationsIn(events); len(v) > 0 {
+				test.Errorf("Expected device %d to accept the reveal of player %d, got %v", j, i, v)
+			}
+			for _, e := range events {
+				if _, ok := e.(replay.AuditEvent); ok {
+					audited[j] = true
+				}
+			}
+		}
+	}
+	for i, d := range devices {
+		if !audited[i] {
+			test.Errorf("Expected device %d to audit the round once the other players revealed", i)
+		}
+		if a := d.r.Violations(); len(a) != refused {
+			test.Errorf("Expected device %d to audit the round with only the refused Timeout, got %v", i, a)
+		}
+	}
+}
+
+// Returns the cards of hand which aren't hidden
+func withoutHidden(hand []*card.Card) []*card.Card {
+	shown := make([]*card.Card, 0, len(hand))
+	for _, c := range hand {
+		if !c.Hidden() {
+			shown = append(shown, c)
+		}
+	}
+	return shown
+}
+
+// Testing that once a player has published a sign key, only entries they signed are accepted under their seat
+func TestSignedEntries(test *testing.T) {
+	log := &sealedLog{}
+	devices := newSealedDevices(test, 4, log)
+	r := devices[2].r
+	value, _ := (&replay.GameCommand{Type: replay.Ready, Player: 0}).Encode()
+	signed, err := devices[0].key.Sign("1/log/100-0", value)
+	if err != nil {
+		test.Fatalf("Sign error: %v", err)
+	}
+	if c, err := replay.Decode(signed); err != nil || c.Type != replay.Ready || c.Player != 0 || c.Signature == "" {
+		test.Errorf("Expected a signed command to decode, got %v %v", c, err)
+	}
+	forged, _ := devices[1].key.Sign("1/log/100-0", value)
+	entries := []struct {
+		key   string
+		value string
+	}{
+		// written under player 0's seat by another player
+		{"1/log/100-0", forged},
+		{"1/log/100-0", value},
+		// player 0's entry copied under another key
+		{"1/log/101-0", signed},
+	}
+	for _, e := range entries {
+		events, err := r.Apply(e.key, e.value)
+		if v := violationsIn(events); err != nil || len(v) != 1 || !errors.Is(v[0].Err, replay.ErrBadSignature) {
+			test.Errorf("Expected %s under %s to be a violation, got %v %v", e.value, e.key, events, err)
+		}
+	}
+	events, _ := r.Apply("1/players/100/sign_key", devices[1].key.SignKey())
+	if v := violationsIn(events); len(v) != 1 || !errors.Is(v[0].Err, replay.ErrSignKeyChanged) {
+		test.Errorf("Expected another sign key written for a player to be a violation, got %v", events)
+	}
+	if events, _ := r.Apply("1/log/100-0", signed); len(violationsIn(events)) != 0 {
+		test.Errorf("Expected the entry player 0 signed to be accepted, got %v", events)
+	}
+}
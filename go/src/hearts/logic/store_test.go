@@ -0,0 +1,321 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hearts/ai"
+	"hearts/logic/card"
+	"hearts/logic/table"
+	"hearts/replay"
+	"hearts/store"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Permissions letting every device read, write and administer a syncgroup
+var openAccess = store.Permissions{
+	Admin: []string{store.AllUsers},
+	Write: []string{store.AllUsers},
+	Read:  []string{store.AllUsers},
+}
+
+// Testing that rows under a syncgroup prefix are shared between the devices which join it, and only those rows
+func TestMemoryStoreSync(test *testing.T) {
+	n := store.NewNetwork()
+	a := n.NewDevice("a")
+	b := n.NewDevice("b")
+	if err := a.Put("games", "1/status", []byte("RUNNING")); !errors.Is(err, store.ErrNoTable) {
+		test.Errorf("Expected %v, got %v", store.ErrNoTable, err)
+	}
+	for _, d := range []*store.Memory{a, b} {
+		if err := d.CreateTable("games"); err != nil {
+			test.Fatalf("CreateTable error: %v", err)
+		}
+	}
+	a.Put("games", "1/status", []byte("RUNNING"))
+	a.Put("games", "2/status", []byte("RUNNING"))
+	if err := b.JoinSyncgroup("game-1", false); !errors.Is(err, store.ErrNoSyncgroup) {
+		test.Errorf("Expected %v, got %v", store.ErrNoSyncgroup, err)
+	}
+	prefixes := []store.Prefix{{Table: "games", Row: "1"}}
+	if err := a.CreateSyncgroup("game-1", prefixes, openAccess); err != nil {
+		test.Fatalf("CreateSyncgroup error: %v", err)
+	}
+	if err := b.CreateSyncgroup("game-1", prefixes, openAccess); !errors.Is(err, store.ErrSyncgroupExists) {
+		test.Errorf("Expected %v, got %v", store.ErrSyncgroupExists, err)
+	}
+	watch, _ := a.Watch("games", "1")
+	if err := b.JoinSyncgroup("game-1", false); err != nil {
+		test.Fatalf("JoinSyncgroup error: %v", err)
+	}
+	rows, _ := b.Scan("games", "")
+	if len(rows) != 1 || rows[0].Key != "1/status" || string(rows[0].Value) != "RUNNING" {
+		test.Errorf("Expected b to receive the shared row only, got %v", rows)
+	}
+	b.Put("games", "1/log/5-1", []byte("Ready|1:END"))
+	if !watch.Advance() || watch.Change().Key != "1/log/5-1" || string(watch.Change().Value) != "Ready|1:END" {
+		test.Errorf("Expected a to watch the row written by b, got %v", watch.Change())
+	}
+	watch.Cancel()
+	if watch.Advance() {
+		test.Errorf("Expected a cancelled watch to stop")
+	}
+	if members, _ := a.Members("game-1"); !reflect.DeepEqual(members, []string{"a", "b"}) {
+		test.Errorf("Expected a and b to be members, got %v", members)
+	}
+	if blessings, _ := b.MemberBlessings("game-1"); !reflect.DeepEqual(blessings, []string{"a", "b"}) {
+		test.Errorf("Expected the members to have authenticated as a and b, got %v", blessings)
+	}
+	if names, _ := b.Syncgroups(); !reflect.DeepEqual(names, []string{"game-1"}) {
+		test.Errorf("Expected b to be in game-1, got %v", names)
+	}
+}
+
+func TestMatchesBlessings(test *testing.T) {
+	cases := []struct {
+		patterns  []string
+		blessings []string
+		expected  bool
+	}{
+		{[]string{store.AllUsers}, []string{"dev.v.io:u:alice"}, true},
+		{[]string{"dev.v.io:u:alice"}, []string{"dev.v.io:u:alice"}, true},
+		{[]string{"dev.v.io:u:alice"}, []string{"dev.v.io:u:alice:phone"}, true},
+		{[]string{"dev.v.io:u:alice"}, []string{"dev.v.io:u:alicex"}, false},
+		{[]string{"dev.v.io:u:alice:phone"}, []string{"dev.v.io:u:alice"}, false},
+		{[]string{"dev.v.io:u:bob", "dev.v.io:u:alice"}, []string{"other", "dev.v.io:u:alice"}, true},
+		{nil, []string{"dev.v.io:u:alice"}, false},
+		{[]string{"dev.v.io:u:alice:$"}, []string{"dev.v.io:u:alice"}, true},
+		{[]string{"dev.v.io:u:alice:$"}, []string{"dev.v.io:u:alice:phone"}, false},
+	}
+	for _, c := range cases {
+		if m := store.Matches(c.patterns, c.blessings); m != c.expected {
+			test.Errorf("Matches(%v, %v): expected %v, got %v", c.patterns, c.blessings, c.expected, m)
+		}
+	}
+	// only blessings a member authenticated with are let in, and only themselves
+	members := []string{"dev.v.io:u:alice", "dev.v.io:u:bob"}
+	claimed := []string{store.AllUsers, "dev.v.io:u", "dev.v.io:u:alice:$", "dev.v.io:u:carol", "dev.v.io:u:alice"}
+	if patterns := store.Authenticated(claimed, members); !reflect.DeepEqual(patterns, []string{"dev.v.io:u:alice:$"}) {
+		test.Errorf("Expected only alice's own blessing to be let in, got %v", patterns)
+	}
+}
+
+// Testing that syncgroup permissions decide who may join, write and change them
+// The owner opens the log to everyone, then closes it to the seated player once the game starts
+func TestMemoryStorePermissions(test *testing.T) {
+	n := store.NewNetwork()
+	owner := n.NewDevice("owner")
+	player := n.NewDevice("player")
+	watcher := n.NewDevice("watcher")
+	for _, d := range []*store.Memory{owner, player, watcher} {
+		d.CreateTable("games")
+	}
+	prefixes := []store.Prefix{{Table: "games", Row: "1"}}
+	open := store.Permissions{
+		Admin: owner.Blessings(),
+		Write: []string{store.AllUsers},
+		Read:  []string{store.AllUsers},
+	}
+	if err := owner.CreateSyncgroup("game-1", prefixes, open); err != nil {
+		test.Fatalf("CreateSyncgroup error: %v", err)
+	}
+	for _, d := range []*store.Memory{player, watcher} {
+		if err := d.JoinSyncgroup("game-1", false); err != nil {
+			test.Fatalf("JoinSyncgroup error: %v", err)
+		}
+		if err := d.Put("games", "1/players/5/player_number", []byte("1")); err != nil {
+			test.Errorf("Expected anyone to write before the game starts, got %v", err)
+		}
+	}
+	started := store.Permissions{
+		Admin: owner.Blessings(),
+		Write: append(owner.Blessings(), player.Blessings()...),
+		Read:  []string{store.AllUsers},
+	}
+	if err := player.SetPermissions("game-1", started); !errors.Is(err, store.ErrNoAccess) {
+		test.Errorf("Expected %v for a player changing permissions, got %v", store.ErrNoAccess, err)
+	}
+	if err := owner.SetPermissions("game-1", started); err != nil {
+		test.Fatalf("SetPermissions error: %v", err)
+	}
+	if err := player.Put("games", "1/log/2-1", []byte("Ready|1:END")); err != nil {
+		test.Errorf("Expected the player to write once the game starts, got %v", err)
+	}
+	if err := watcher.Put("games", "1/log/3-3", []byte("Ready|3:END")); !errors.Is(err, store.ErrNoAccess) {
+		test.Errorf("Expected %v for a watcher writing once the game starts, got %v", store.ErrNoAccess, err)
+	}
+	if rows, _ := watcher.Scan("games", "1/log"); len(rows) != 1 || rows[0].Key != "1/log/2-1" {
+		test.Errorf("Expected the watcher to read only the player's entry, got %v", rows)
+	}
+	if err := watcher.Put("games", "2/status", []byte("RUNNING")); err != nil {
+		test.Errorf("Expected rows outside the syncgroup to stay writable, got %v", err)
+	}
+	private := store.Permissions{Admin: owner.Blessings(), Write: owner.Blessings(), Read: owner.Blessings()}
+	if err := owner.CreateSyncgroup("settings-1", []store.Prefix{{Table: "games", Row: "users/1"}}, private); err != nil {
+		test.Fatalf("CreateSyncgroup error: %v", err)
+	}
+	if err := player.JoinSyncgroup("settings-1", false); !errors.Is(err, store.ErrNoAccess) {
+		test.Errorf("Expected %v for joining without read access, got %v", store.ErrNoAccess, err)
+	}
+}
+
+// A simulated device, playing one seat with the heuristic strategy from what it reads in its own store
+type memoryDevice struct {
+	seat    int
+	s       store.Store
+	r       *replay.Replayer
+	pending bool
+}
+
+// Writes the next move of d's seat, if it has one and its last move hasn't been read back yet
+// Moves are chosen the same way hearts/sync moves computer players
+func (d *memoryDevice) move(test *testing.T, clock *int64) {
+	t := d.r.Table()
+	strategy := ai.NewHeuristic()
+	var c *replay.GameCommand
+	switch {
+	case d.pending:
+		return
+	case t.AllDoneDealing() && len(t.LegalPasses(d.seat)) > 0:
+		c = &replay.GameCommand{Type: replay.Pass, Player: d.seat, Cards: strategy.Pass(t, d.seat)}
+	case t.ValidTake(d.seat) == nil && t.AllDonePassing():
+		c = &replay.GameCommand{Type: replay.Take, Player: d.seat}
+	case t.TrickOver() && t.GetTrickRecipient() == d.seat:
+		c = &replay.GameCommand{Type: replay.TakeTrick, Player: -1}
+	case t.GetPlayers()[d.seat].GetDoneTaking() && len(t.LegalPlays(d.seat)) > 0:
+		c = &replay.GameCommand{Type: replay.Play, Player: d.seat, Cards: []*card.Card{strategy.Play(t, d.seat)}}
+	default:
+		return
+	}
+	value, err := c.Encode()
+	if err != nil {
+		test.Errorf("Encode error: %v", err)
+		return
+	}
+	d.pending = true
+	d.s.Put("games", replay.LogKey(1, atomic.AddInt64(clock, 1), d.seat), []byte(value))
+}
+
+// Testing a whole round between four devices, each replaying the game log from its own memory store
+func TestMemoryStoreGame(test *testing.T) {
+	numPlayers := 4
+	n := store.NewNetwork()
+	var clock int64
+	devices := make([]*memoryDevice, numPlayers)
+	watches := make([]store.WatchStream, numPlayers)
+	for i := range devices {
+		s := n.NewDevice(fmt.Sprintf("device-%d", i))
+		s.CreateTable("games")
+		if i == 0 {
+			s.CreateSyncgroup("game-1", []store.Prefix{{Table: "games", Row: "1"}}, openAccess)
+		} else if err := s.JoinSyncgroup("game-1", false); err != nil {
+			test.Fatalf("JoinSyncgroup error: %v", err)
+		}
+		devices[i] = &memoryDevice{seat: i, s: s, r: replay.New(table.InitializeGame(numPlayers, table.ClassicRules()), true)}
+		watches[i], _ = s.Watch("games", "1")
+	}
+	snapshots := make(chan string, numPlayers)
+	for i, d := range devices {
+		go func(d *memoryDevice, watch store.WatchStream) {
+			defer watch.Cancel()
+			for watch.Advance() {
+				c := watch.Change()
+				events, err := d.r.Apply(c.Key, string(c.Value))
+				if err != nil {
+					test.Errorf("Device %d could not apply %s: %v", d.seat, c.Value, err)
+				}
+				if _, writer, ok := replay.ParseLogKey(c.Key); ok && writer == d.seat {
+					d.pending = false
+				}
+				for _, e := range events {
+					if v, ok := e.(replay.ViolationEvent); ok {
+						test.Errorf("Device %d found a violation: %v", d.seat, v)
+					}
+					if tt, ok := e.(replay.TakeTrickEvent); ok && tt.RoundOver {
+						encoded, _ := json.Marshal(d.r.Table().Snapshot())
+						snapshots <- string(encoded)
+						return
+					}
+				}
+				d.move(test, &clock)
+			}
+		}(d, watches[i])
+	}
+	dealer := devices[0]
+	for i, h := range dealer.r.Table().DealSeed(17) {
+		value, _ := (&replay.GameCommand{Type: replay.Deal, Player: i, Cards: h, Seed: 17, HasSeed: true}).Encode()
+		dealer.s.Put("games", replay.LogKey(1, atomic.AddInt64(&clock, 1), 0), []byte(value))
+	}
+	var first string
+	for i := 0; i < numPlayers; i++ {
+		select {
+		case s := <-snapshots:
+			if i == 0 {
+				first = s
+			} else if s != first {
+				test.Errorf("Expected every device to end the round in the same state")
+			}
+		case <-time.After(10 * time.Second):
+			test.Fatalf("Timed out waiting for the round to end on every device")
+		}
+	}
+}
+
+// Testing the waits between attempts double from Initial and stop growing at Max
+func TestBackoffDelay(test *testing.T) {
+	b := store.Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Attempts: 5}
+	expected := []time.Duration{10, 20, 40, 50, 50, 50}
+	for n, e := range expected {
+		if d := b.Delay(n); d != e*time.Millisecond {
+			test.Errorf("Delay(%d): expected %v, got %v", n, e*time.Millisecond, d)
+		}
+	}
+}
+
+// Testing that Retry stops once f succeeds, gives up as offline after the last attempt, doesn't retry refused
+// access, and stops when cancelled
+func TestRetry(test *testing.T) {
+	b := store.Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond, Attempts: 4}
+	failing := errors.New("Put failed")
+	failTimes := func(n int, calls *int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= n {
+				return failing
+			}
+			return nil
+		}
+	}
+	calls := 0
+	if err := store.Retry(nil, b, failTimes(2, &calls)); err != nil || calls != 3 {
+		test.Errorf("Expected success on the third attempt, got %v after %d attempts", err, calls)
+	}
+	calls = 0
+	err := store.Retry(nil, b, failTimes(10, &calls))
+	if !errors.Is(err, store.ErrOffline) || calls != b.Attempts {
+		test.Errorf("Expected %v after %d attempts, got %v after %d attempts", store.ErrOffline, b.Attempts, err, calls)
+	}
+	calls = 0
+	err = store.Retry(nil, b, func() error {
+		calls++
+		return fmt.Errorf("%w: not a player", store.ErrNoAccess)
+	})
+	if !errors.Is(err, store.ErrNoAccess) || errors.Is(err, store.ErrOffline) || calls != 1 {
+		test.Errorf("Expected %v after 1 attempt, got %v after %d attempts", store.ErrNoAccess, err, calls)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = store.Retry(ctx, store.Backoff{Initial: time.Hour, Max: time.Hour, Attempts: 3}, failTimes(10, &calls))
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		test.Errorf("Expected %v after 1 attempt, got %v after %d attempts", context.Canceled, err, calls)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// errors.go contains the errors returned when a move breaks the rules of Hearts
+// Callers can compare against them with errors.Is; their text is what the UI displays by default
+
+package table
+
+import (
+	"errors"
+)
+
+var (
+	// the card does not follow the suit that was led, and the player holds a card of that suit
+	ErrMustFollowSuit = errors.New("Must follow suit")
+	// a heart was led before hearts were broken, and the player holds a card of another suit
+	ErrHeartsNotBroken = errors.New("Hearts have not been broken")
+	// the first trick of the round was not opened with the lowest club
+	ErrMustOpenWithLead = errors.New("Must open with the lowest Club")
+	// a point card was played on the first trick, and the player holds a card worth no points
+	ErrPointsOnFirstTrick = errors.New("Point cards not allowed in the first round")
+	// the player is not the next to play in the current trick
+	ErrNotYourTurn = errors.New("It is not your turn")
+	// the player has already played a card in the current trick
+	ErrAlreadyPlayed = errors.New("You have already played a card in this trick")
+	// cards are being played before every player has finished passing
+	ErrPassingNotDone = errors.New("Not all players have passed their cards")
+	// the wrong number of cards are being passed
+	ErrWrongPassSize = errors.New("Must pass exactly three cards")
+	// the player doesn't hold a card they are playing or passing, or is passing the same card twice
+	ErrCardNotInHand = errors.New("You don't hold that card")
+	// cards are being passed or taken in a round without passing
+	ErrNoPassing = errors.New("There is no passing this round")
+	// the player has already passed cards this round
+	ErrAlreadyPassed = errors.New("You have already passed your cards")
+	// the player has already taken the cards passed to them this round
+	ErrAlreadyTaken = errors.New("You have already taken your cards")
+	// a hand is being dealt to a player who already holds one
+	ErrAlreadyDealt = errors.New("Cards have already been dealt this round")
+	// the player is getting ready for the next round before the current one is over
+	ErrRoundNotOver = errors.New("The round is not over")
+	// a predefined deal doesn't split the whole deck evenly between the players
+	ErrInvalidLayout = errors.New("Hands must split the deck evenly between the players")
+	// a snapshot was written by a different version of the snapshot encoding
+	ErrSnapshotVersion = errors.New("Unsupported snapshot version")
+	// a snapshot is missing state, or names cards that aren't in the deck
+	ErrInvalidSnapshot = errors.New("Invalid snapshot")
+	// a snapshot holds the same card in two places, which Restore returns wrapped in ErrInvalidSnapshot
+	ErrDuplicateCard = errors.New("Card held in two places")
+	// a snapshot holds more or fewer cards than the deck deals the players, which Restore returns wrapped in ErrInvalidSnapshot
+	ErrCardCount = errors.New("Cards don't match the deck")
+)
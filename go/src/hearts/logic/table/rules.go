@@ -0,0 +1,82 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// rules.go contains the Rules struct, which holds the rule variants a table is played with
+
+package table
+
+import (
+	"hearts/logic/card"
+)
+
+// Moon determines how points are assigned when a player takes every point card in a round
+type Moon int
+
+const (
+	// every other player receives the points, the shooter receives none (classic rules)
+	AddToOthers Moon = iota
+	// the shooter has the points subtracted from their score, the other players receive none
+	SubtractFromShooter
+	// shooting the moon is not rewarded, the shooter keeps the points like in any other round
+	AddToSelf
+)
+
+const (
+	// jackOfDiamondsPoints is the value of the Jack of Diamonds when Rules.JackOfDiamonds is set
+	jackOfDiamondsPoints = -10
+)
+
+type Rules struct {
+	// PointsOnFirstTrick allows point cards to be played on the first trick of a round
+	PointsOnFirstTrick bool `json:"points_on_first_trick"`
+	// JackOfDiamonds makes the Jack of Diamonds worth -10 points to whoever takes it
+	JackOfDiamonds bool `json:"jack_of_diamonds"`
+	// ShootTheSun doubles the moon points if the shooter also took every trick of the round
+	ShootTheSun bool `json:"shoot_the_sun"`
+	// Moon decides who the points go to when a player shoots the moon
+	Moon Moon `json:"moon"`
+}
+
+// Returns the rules of traditional Hearts
+func ClassicRules() *Rules {
+	return &Rules{
+		PointsOnFirstTrick: false,
+		JackOfDiamonds:     false,
+		ShootTheSun:        false,
+		Moon:               AddToOthers,
+	}
+}
+
+// Returns the number of points c is worth under r, not counting points awarded for shooting the moon
+func (r *Rules) CardPoints(c *card.Card) int {
+	switch {
+	case c.GetSuit() == card.Heart:
+		return 1
+	case c.GetSuit() == card.Spade && c.GetFace() == card.Queen:
+		return 13
+	case r.JackOfDiamonds && c.GetSuit() == card.Diamond && c.GetFace() == card.Jack:
+		return jackOfDiamondsPoints
+	default:
+		return 0
+	}
+}
+
+// Given each player's score from the cards they took, applies the moon rules of r
+// shooter is the index of the player who took every point card, and moonPoints the value of those cards
+func (r *Rules) applyMoon(roundScores []int, shooter, moonPoints int, tookAllTricks bool) {
+	if r.Moon == AddToSelf {
+		return
+	}
+	roundScores[shooter] -= moonPoints
+	if r.ShootTheSun && tookAllTricks {
+		moonPoints *= 2
+	}
+	for i := range roundScores {
+		if r.Moon == SubtractFromShooter && i == shooter {
+			roundScores[i] -= moonPoints
+		} else if r.Moon == AddToOthers && i != shooter {
+			roundScores[i] += moonPoints
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// snapshot.go contains Snapshot, a serializable copy of the whole state of a table
+// Snapshots encode to JSON; cards are written the same way the game log writes them, for example "h10" or "sq",
+// and cards hidden from the device taking the snapshot are written as "??"
+// SnapshotVersion must be increased whenever the encoding changes, so that old snapshots are rejected rather than misread
+
+package table
+
+import (
+	"fmt"
+
+	"hearts/img/direction"
+	"hearts/logic/card"
+	"hearts/logic/player"
+)
+
+// SnapshotVersion is the version of the Snapshot encoding written by this code
+const SnapshotVersion = 1
+
+// the encoding of a hidden card
+const hiddenCard = "??"
+
+var dirNames = map[direction.Direction]string{
+	direction.Right:  "right",
+	direction.Left:   "left",
+	direction.Across: "across",
+	direction.None:   "none",
+}
+
+type Snapshot struct {
+	Version      int              `json:"version"`
+	Rules        Rules            `json:"rules"`
+	Players      []PlayerSnapshot `json:"players"`
+	Trick        []string         `json:"trick"` // indexed by player, "" where no card has been played
+	FirstPlayer  int              `json:"first_player"`
+	HeartsBroken bool             `json:"hearts_broken"`
+	FirstTrick   bool             `json:"first_trick"`
+	WinCondition int              `json:"win_condition"`
+	Dir          string           `json:"dir"`
+}
+
+type PlayerSnapshot struct {
+	Hand        []string `json:"hand"`
+	PassedFrom  []string `json:"passed_from"`
+	PassedTo    []string `json:"passed_to"`
+	Tricks      []string `json:"tricks"`
+	NumTricks   int      `json:"num_tricks"`
+	Score       int      `json:"score"`
+	DonePassing bool     `json:"done_passing"`
+	DoneTaking  bool     `json:"done_taking"`
+	DonePlaying bool     `json:"done_playing"`
+	DoneScoring bool     `json:"done_scoring"`
+}
+
+// Returns a snapshot of the current state of t
+// The source of randomness of t is not included: each deal can be reproduced from the game log instead,
+// which carries the cards of a plain Deal, and the seeds every player reveals once a sealed round is over
+func (t *Table) Snapshot() *Snapshot {
+	s := &Snapshot{
+		Version:      SnapshotVersion,
+		Rules:        *t.rules,
+		Players:      make([]PlayerSnapshot, len(t.players)),
+		Trick:        encodeCards(t.trick),
+		FirstPlayer:  t.firstPlayer,
+		HeartsBroken: t.heartsBroken,
+		FirstTrick:   t.firstTrick,
+		WinCondition: t.winCondition,
+		Dir:          dirNames[t.dir],
+	}
+	for i, p := range t.players {
+		s.Players[i] = PlayerSnapshot{
+			Hand:        encodeCards(p.GetHand()),
+			PassedFrom:  encodeCards(p.GetPassedFrom()),
+			PassedTo:    encodeCards(p.GetPassedTo()),
+			Tricks:      encodeCards(p.GetTricks()),
+			NumTricks:   p.GetNumTricks(),
+			Score:       p.GetScore(),
+			DonePassing: p.GetDonePassing(),
+			DoneTaking:  p.GetDoneTaking(),
+			DonePlaying: p.GetDonePlaying(),
+			DoneScoring: p.GetDoneScoring(),
+		}
+	}
+	return s
+}
+
+// Returns a table in the state recorded by s
+// Returns ErrSnapshotVersion if s was written by a different version of the encoding, or ErrInvalidSnapshot if s can't be read.
+// A snapshot holding a card twice, or cards which the deck for its number of players couldn't have dealt, is also
+// invalid: ErrInvalidSnapshot then wraps ErrDuplicateCard or ErrCardCount
+func Restore(s *Snapshot) (*Table, error) {
+	if s.Version != SnapshotVersion {
+		return nil, ErrSnapshotVersion
+	}
+	if len(s.Players) == 0 {
+		return nil, ErrInvalidSnapshot
+	}
+	rules := s.Rules
+	players := make([]*player.Player, len(s.Players))
+	for i := range players {
+		players[i] = player.NewPlayer(i)
+	}
+	t := makeTable(players, &rules)
+	t.GenerateClassicCards()
+	dir, ok := parseDir(s.Dir)
+	if !ok || len(s.Trick) != len(players) || s.FirstPlayer < -1 || s.FirstPlayer >= len(players) {
+		return nil, ErrInvalidSnapshot
+	}
+	t.dir = dir
+	t.firstPlayer = s.FirstPlayer
+	t.heartsBroken = s.HeartsBroken
+	t.firstTrick = s.FirstTrick
+	t.winCondition = s.WinCondition
+	var err error
+	if t.trick, err = t.decodeCards(s.Trick); err != nil {
+		return nil, err
+	}
+	for i, ps := range s.Players {
+		p := players[i]
+		hand, err := t.decodeCards(ps.Hand)
+		if err != nil {
+			return nil, err
+		}
+		passedFrom, err := t.decodeCards(ps.PassedFrom)
+		if err != nil {
+			return nil, err
+		}
+		passedTo, err := t.decodeCards(ps.PassedTo)
+		if err != nil {
+			return nil, err
+		}
+		tricks, err := t.decodeCards(ps.Tricks)
+		if err != nil {
+			return nil, err
+		}
+		p.SetHand(hand)
+		p.SetPassedFrom(passedFrom)
+		p.SetPassedTo(passedTo)
+		p.SetTricks(tricks, ps.NumTricks)
+		p.UpdateScore(ps.Score)
+		p.SetDonePassing(ps.DonePassing)
+		p.SetDoneTaking(ps.DoneTaking)
+		p.SetDonePlaying(ps.DonePlaying)
+		p.SetDoneScoring(ps.DoneScoring)
+	}
+	if err := t.checkCards(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+	return t, nil
+}
+
+// Returns ErrDuplicateCard if a card of t is held in two places, or passed twice, and ErrCardCount if t doesn't hold
+// whole hands of its deck, the whole deck once play has begun, or holds a hand larger than a dealt hand and a pass
+// Cards passed to a player who hasn't taken them yet are held by no one, so they are counted from the passer's pass
+func (t *Table) checkCards() error {
+	located := make(map[*card.Card]bool)
+	hidden := 0
+	locate := func(cards []*card.Card) error {
+		for _, c := range cards {
+			switch {
+			case c == nil:
+			case c.Hidden():
+				hidden++
+			case located[c]:
+				return ErrDuplicateCard
+			default:
+				located[c] = true
+			}
+		}
+		return nil
+	}
+	if err := locate(t.trick); err != nil {
+		return err
+	}
+	played := len(located) > 0
+	perHand := len(t.allCards) / len(t.players)
+	passedFrom := make([][]*card.Card, 0, len(t.players))
+	passedTo := make([][]*card.Card, 0, len(t.players))
+	for _, p := range t.players {
+		if len(p.GetHand()) > perHand+PassSize {
+			return ErrCardCount
+		}
+		if err := locate(p.GetHand()); err != nil {
+			return err
+		}
+		if err := locate(p.GetTricks()); err != nil {
+			return err
+		}
+		played = played || p.GetNumTricks() > 0
+		passedFrom = append(passedFrom, p.GetPassedFrom())
+		passedTo = append(passedTo, p.GetPassedTo())
+	}
+	if !distinctCards(passedFrom) || !distinctCards(passedTo) {
+		return ErrDuplicateCard
+	}
+	for i, p := range t.players {
+		if t.dir == direction.None || !p.GetDonePassing() || t.players[t.GetPassRecipient(i)].GetDoneTaking() {
+			continue
+		}
+		for _, c := range p.GetPassedFrom() {
+			if c.Hidden() {
+				hidden++
+			} else {
+				located[c] = true
+			}
+		}
+	}
+	count := len(located) + hidden
+	if count > len(t.allCards) || count%perHand != 0 || (played && count != len(t.allCards)) {
+		return ErrCardCount
+	}
+	return nil
+}
+
+// Returns true if no card appears twice in piles, leaving out hidden cards
+func distinctCards(piles [][]*card.Card) bool {
+	seen := make(map[*card.Card]bool)
+	for _, pile := range piles {
+		for _, c := range pile {
+			if c == nil || c.Hidden() {
+				continue
+			}
+			if seen[c] {
+				return false
+			}
+			seen[c] = true
+		}
+	}
+	return true
+}
+
+func parseDir(name string) (direction.Direction, bool) {
+	for dir, dirName := range dirNames {
+		if dirName == name {
+			return dir, true
+		}
+	}
+	return direction.None, false
+}
+
+// Returns cards in the format the game log uses, with "" in place of nil cards
+func encodeCards(cards []*card.Card) []string {
+	encoded := make([]string, len(cards))
+	for i, c := range cards {
+		if c != nil {
+			encoded[i] = c.GetSuit().String() + c.GetFace().String()
+		}
+	}
+	return encoded
+}
+
+// Returns the cards of t named by encoded, with nil in place of "" and new hidden cards in place of hiddenCard
+func (t *Table) decodeCards(encoded []string) ([]*card.Card, error) {
+	cards := make([]*card.Card, len(encoded))
+	for i, e := range encoded {
+		if e == "" {
+			continue
+		}
+		if e == hiddenCard {
+			cards[i] = card.NewHiddenCard()
+			continue
+		}
+		cards[i] = t.GetCard(card.ConvertToFace(e[1:]), card.ConvertToSuit(e[:1]))
+		if cards[i] == nil {
+			return nil, ErrInvalidSnapshot
+		}
+	}
+	return cards, nil
+}
@@ -7,28 +7,42 @@
 package table
 
 import (
-	"golang.org/x/mobile/exp/sprite"
 	"hearts/img/direction"
 	"hearts/logic/card"
 	"hearts/logic/player"
 	"math/rand"
 	"sort"
+	"time"
 )
 
-// Returns a table instance with player set length numPlayers
-func InitializeGame(numPlayers int, texs map[string]sprite.SubTex) *Table {
+// Returns a table instance with player set length numPlayers, played with the given rules, or the classic rules if nil
+func InitializeGame(numPlayers int, rules *Rules) *Table {
+	if rules == nil {
+		rules = ClassicRules()
+	}
 	players := make([]*player.Player, 0)
 	for i := 0; i < numPlayers; i++ {
 		players = append(players, player.NewPlayer(i))
 	}
-	t := makeTable(players)
+	t := makeTable(players, rules)
 	t.GenerateClassicCards()
 	t.NewRound()
 	return t
 }
 
-// Given a group of players, returns a table instance with that group as its player set
-func makeTable(p []*player.Player) *Table {
+// PassSize is the number of cards each player passes at the start of a round
+const PassSize = 3
+
+// removedCards lists the cards taken out of the deck so that it can be dealt evenly, keyed by number of players
+var removedCards = map[int][]*card.Card{
+	3: {card.NewCard(card.Two, card.Club)},
+	5: {card.NewCard(card.Two, card.Club), card.NewCard(card.Two, card.Diamond)},
+	6: {card.NewCard(card.Two, card.Club), card.NewCard(card.Two, card.Diamond),
+		card.NewCard(card.Three, card.Club), card.NewCard(card.Three, card.Diamond)},
+}
+
+// Given a group of players and a set of rules, returns a table instance with that group as its player set
+func makeTable(p []*player.Player, r *Rules) *Table {
 	return &Table{
 		players:      p,
 		trick:        make([]*card.Card, len(p)),
@@ -38,6 +52,8 @@ func makeTable(p []*player.Player) *Table {
 		firstTrick:   true,
 		winCondition: 100,
 		dir:          direction.Right,
+		rules:        r,
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
@@ -48,7 +64,7 @@ type Table struct {
 	trick []*card.Card
 	// firstPlayer is the index in trick of the card played first
 	firstPlayer int
-	// allCards contains all 52 cards in the deck. GenerateCards() populates this
+	// allCards contains all cards in the deck. GenerateClassicCards() populates this
 	allCards []*card.Card
 	// heartsBroken returns true if a heart has been played yet in the round, otherwise false
 	heartsBroken bool
@@ -59,6 +75,22 @@ type Table struct {
 	winCondition int
 	// dir is the current round's passing direction
 	dir direction.Direction
+	// rules contains the rule variants used for validating plays and scoring rounds
+	rules *Rules
+	// rng is the source of randomness used to pick the seed of each deal
+	rng *rand.Rand
+}
+
+// Returns a copy of t which can be played on without affecting t, for instance to simulate the rest of a round
+// The cards, rules and source of randomness are shared between t and the copy
+func (t *Table) Copy() *Table {
+	c := *t
+	c.players = make([]*player.Player, len(t.players))
+	for i, p := range t.players {
+		c.players[i] = p.Copy()
+	}
+	c.trick = append([]*card.Card(nil), t.trick...)
+	return &c
 }
 
 // Returns the player set of t
@@ -85,12 +117,77 @@ func (t *Table) GetDir() direction.Direction {
 	return t.dir
 }
 
+// Returns the card in the deck of t with the given face and suit, or nil if it was removed from the deck
+func (t *Table) GetCard(face card.Face, suit card.Suit) *card.Card {
+	for _, c := range t.allCards {
+		if c.GetSuit() == suit && c.GetFace() == face {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns the card which must open each round: the lowest club in the deck
+func (t *Table) GetFirstLead() *card.Card {
+	for _, c := range t.allCards {
+		if c.GetSuit() == card.Club {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns the index of the player who receives the cards passed by the player at playerIndex this round
+// Returns -1 if there is no passing this round
+func (t *Table) GetPassRecipient(playerIndex int) int {
+	numPlayers := len(t.players)
+	switch t.dir {
+	case direction.Right:
+		return (playerIndex + numPlayers - 1) % numPlayers
+	case direction.Left:
+		return (playerIndex + 1) % numPlayers
+	case direction.Across:
+		return (playerIndex + numPlayers/2) % numPlayers
+	}
+	return -1
+}
+
+// Returns the index of the player who passes cards to the player at playerIndex this round
+// Returns -1 if there is no passing this round
+func (t *Table) GetPassSender(playerIndex int) int {
+	numPlayers := len(t.players)
+	switch t.dir {
+	case direction.Right:
+		return (playerIndex + 1) % numPlayers
+	case direction.Left:
+		return (playerIndex + numPlayers - 1) % numPlayers
+	case direction.Across:
+		return (playerIndex + numPlayers - numPlayers/2) % numPlayers
+	}
+	return -1
+}
+
+// Returns the passing directions rotated through from round to round
+// Passing across only makes sense with an even number of players
+func (t *Table) passDirections() []direction.Direction {
+	if len(t.players)%2 == 0 {
+		return []direction.Direction{direction.Right, direction.Left, direction.Across, direction.None}
+	}
+	return []direction.Direction{direction.Right, direction.Left, direction.None}
+}
+
+// Returns the rules t is played with
+func (t *Table) GetRules() *Rules {
+	return t.rules
+}
+
 // Sets the firstplayer variable of t to index
 func (t *Table) SetFirstPlayer(index int) {
 	t.firstPlayer = index
 }
 
 // Returns the index of the player whose turn it is, -1 if this cannot be determined at this time
+// Before the first lead of a round whose hands are hidden, the first player is only known to the device holding the lead
 func (t *Table) WhoseTurn() int {
 	allNil := true
 	for i, c := range t.trick {
@@ -103,6 +200,13 @@ func (t *Table) WhoseTurn() int {
 		}
 	}
 	if allNil {
+		if t.firstPlayer < 0 && t.firstTrick && t.AllDoneTaking() {
+			for _, p := range t.players {
+				if p.HasCard(t.GetFirstLead()) {
+					return p.GetPlayerIndex()
+				}
+			}
+		}
 		return t.firstPlayer
 	}
 	return -1
@@ -111,6 +215,7 @@ func (t *Table) WhoseTurn() int {
 // This function generates a traditional deck of 52 cards, with 13 in each of the four suits
 // Each card has a suit (Club, Diamond, Spade, or Heart)
 // Each card also has a face from Two to Ace (Aces are high in Hearts)
+// Low clubs and diamonds are then removed as needed so the deck deals evenly between the players of t
 func (t *Table) GenerateClassicCards() {
 	cardsPerSuit := 13
 	t.allCards = make([]*card.Card, 0)
@@ -122,6 +227,14 @@ func (t *Table) GenerateClassicCards() {
 		t.allCards = append(t.allCards, card.NewCard(cardFaces[i], card.Spade))
 		t.allCards = append(t.allCards, card.NewCard(cardFaces[i], card.Heart))
 	}
+	for _, r := range removedCards[len(t.players)] {
+		for i, c := range t.allCards {
+			if c.GetSuit() == r.GetSuit() && c.GetFace() == r.GetFace() {
+				t.allCards = append(t.allCards[:i], t.allCards[i+1:]...)
+				break
+			}
+		}
+	}
 	sort.Sort(card.CardSorter(t.allCards))
 }
 
@@ -133,22 +246,182 @@ func (t *Table) SetPlayedCard(c *card.Card, playerIndex int) {
 	}
 }
 
-// Returns true if there are exactly three cards being passed (specified by Hearts logic)
-func (t *Table) ValidPass(cardsPassed []*card.Card) bool {
-	return len(cardsPassed) == 3
+// Returns nil if there are exactly PassSize cards being passed (specified by Hearts logic)
+// Otherwise returns ErrWrongPassSize
+func (t *Table) ValidPass(cardsPassed []*card.Card) error {
+	if len(cardsPassed) != PassSize {
+		return ErrWrongPassSize
+	}
+	return nil
+}
+
+// Given the cards passed by the player at playerIndex, returns nil if the player may pass them right now
+// Checks that there is passing this round and the player hasn't passed yet, before checking the cards with ValidPass
+// and making sure the player holds each of them once
+func (t *Table) ValidPassFrom(cardsPassed []*card.Card, playerIndex int) error {
+	p := t.players[playerIndex]
+	if t.dir == direction.None {
+		return ErrNoPassing
+	}
+	if p.GetDonePassing() {
+		return ErrAlreadyPassed
+	}
+	if err := t.ValidPass(cardsPassed); err != nil {
+		return err
+	}
+	for i, c := range cardsPassed {
+		if !p.HasCard(c) {
+			return ErrCardNotInHand
+		}
+		for _, other := range cardsPassed[:i] {
+			if other == c {
+				return ErrCardNotInHand
+			}
+		}
+	}
+	return nil
+}
+
+// Returns nil if the player at playerIndex may take the cards passed to them right now
+// The player must have passed their own cards, and the player passing to them must have done so too
+func (t *Table) ValidTake(playerIndex int) error {
+	p := t.players[playerIndex]
+	if t.dir == direction.None {
+		return ErrNoPassing
+	}
+	if p.GetDoneTaking() {
+		return ErrAlreadyTaken
+	}
+	if !p.GetDonePassing() || !t.players[t.GetPassSender(playerIndex)].GetDonePassing() {
+		return ErrPassingNotDone
+	}
+	return nil
+}
+
+// Given a hand and the index of the player it is dealt to, returns nil if it may be dealt right now
+// Returns ErrRoundNotOver while cards are still in play, ErrAlreadyDealt if the player already holds a hand,
+// and ErrInvalidLayout if the hand isn't an even share of the deck made of cards no other player holds
+func (t *Table) ValidDeal(hand []*card.Card, playerIndex int) error {
+	if !t.TrickNew() {
+		return ErrRoundNotOver
+	}
+	if len(t.players[playerIndex].GetHand()) > 0 {
+		return ErrAlreadyDealt
+	}
+	if len(hand) != len(t.allCards)/len(t.players) {
+		return ErrInvalidLayout
+	}
+	for i, c := range hand {
+		if c == nil {
+			return ErrInvalidLayout
+		}
+		for _, other := range hand[:i] {
+			if other == c {
+				return ErrInvalidLayout
+			}
+		}
+		for _, p := range t.players {
+			if p.HasCard(c) {
+				return ErrInvalidLayout
+			}
+		}
+	}
+	return nil
+}
+
+// Returns nil if the player at playerIndex may get ready for the next round, otherwise returns ErrRoundNotOver
+func (t *Table) ValidReady(playerIndex int) error {
+	if len(t.players[playerIndex].GetHand()) > 0 {
+		return ErrRoundNotOver
+	}
+	return nil
+}
+
+// Returns nil if it is valid for the player at playerIndex to play a card, otherwise returns ErrNotYourTurn
+func (t *Table) ValidPlayOrder(playerIndex int) error {
+	if t.WhoseTurn() != playerIndex {
+		return ErrNotYourTurn
+	}
+	return nil
+}
+
+// Given a card and the index of its player, returns nil if the player may play that card right now
+// Checks that the player hasn't played yet this trick, that passing is over, that it is their turn,
+// and that they hold the card, before checking the card itself against game logic
+func (t *Table) ValidPlay(c *card.Card, playerIndex int) error {
+	if t.players[playerIndex].GetDonePlaying() {
+		return ErrAlreadyPlayed
+	}
+	if !t.AllDonePassing() {
+		return ErrPassingNotDone
+	}
+	if err := t.ValidPlayOrder(playerIndex); err != nil {
+		return err
+	}
+	if !t.players[playerIndex].HasCard(c) {
+		return ErrCardNotInHand
+	}
+	return t.ValidPlayLogic(c, playerIndex)
+}
+
+// Given a card and the index of a player whose hand this device can't see, returns nil if they may have played it
+// Only the checks which every device can make are made, so that all of them accept the same plays: ValidPlay can
+// check the rest once the hands are revealed. The first player must have been set, since only the device holding the first lead could tell who it is otherwise
+func (t *Table) ValidHiddenPlay(c *card.Card, playerIndex int) error {
+	if t.players[playerIndex].GetDonePlaying() {
+		return ErrAlreadyPlayed
+	}
+	if !t.AllDonePassing() {
+		return ErrPassingNotDone
+	}
+	if t.firstPlayer < 0 {
+		return ErrNotYourTurn
+	}
+	if err := t.ValidPlayOrder(playerIndex); err != nil {
+		return err
+	}
+	if t.cardPlayed(c) {
+		return ErrCardNotInHand
+	}
+	if t.firstTrick && t.firstPlayer == playerIndex && c != t.GetFirstLead() {
+		return ErrMustOpenWithLead
+	}
+	return nil
 }
 
-// Returns whether it is valid for the player at playerIndex to play a card
-func (t *Table) ValidPlayOrder(playerIndex int) bool {
-	return t.WhoseTurn() == playerIndex
+// Returns nil if the player at playerIndex, whose hand this device can't see, may pass right now
+// Only the checks which don't depend on the hand are made: ValidPassFrom can check the rest once the hand is revealed
+func (t *Table) ValidHiddenPass(playerIndex int) error {
+	if t.dir == direction.None {
+		return ErrNoPassing
+	}
+	if t.players[playerIndex].GetDonePassing() {
+		return ErrAlreadyPassed
+	}
+	return nil
 }
 
-// Given a card and the index of its player, returns "" if this move was valid based on game logic
-// Otherwise returns a string explaining the error
-func (t *Table) ValidPlayLogic(c *card.Card, playerIndex int) string {
-	validPlay := ""
+// Returns true if c has already been played this round, in the current trick or a trick already taken
+func (t *Table) cardPlayed(c *card.Card) bool {
+	if t.TrickTaker(c) >= 0 {
+		return true
+	}
+	for _, played := range t.trick {
+		if played == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Given a card and the index of its player, returns nil if this move was valid based on game logic
+// Otherwise returns one of the errors in errors.go explaining why it was not
+func (t *Table) ValidPlayLogic(c *card.Card, playerIndex int) error {
+	var validPlay error
 	player := t.players[playerIndex]
-	if t.firstPlayer == playerIndex {
+	// before the first lead of a hidden round is played, only the device holding it knows who leads
+	leads := t.firstPlayer == playerIndex || t.firstPlayer < 0 && t.WhoseTurn() == playerIndex
+	if leads {
 		if !t.firstTrick {
 			if c.GetSuit() != card.Heart || t.heartsBroken {
 				return validPlay
@@ -156,32 +429,67 @@ func (t *Table) ValidPlayLogic(c *card.Card, playerIndex int) string {
 				if player.HasOnlyHearts() {
 					return validPlay
 				} else {
-					return "Hearts have not been broken"
+					return ErrHeartsNotBroken
 				}
 			}
-		} else if c.GetSuit() == card.Club && c.GetFace() == card.Two {
+		} else if lead := t.GetFirstLead(); c.GetSuit() == lead.GetSuit() && c.GetFace() == lead.GetFace() {
 			return validPlay
 		} else {
-			return "Must open with the Two of Clubs"
+			return ErrMustOpenWithLead
 		}
 	} else {
 		firstPlayedSuit := t.trick[t.firstPlayer].GetSuit()
 		if c.GetSuit() == firstPlayedSuit || !player.HasSuit(firstPlayedSuit) {
-			if !t.firstTrick {
+			if !t.firstTrick || t.rules.PointsOnFirstTrick {
 				return validPlay
 			} else if !c.WorthPoints() {
 				return validPlay
 			} else if player.HasAllPoints() {
 				return validPlay
 			} else {
-				return "Point cards not allowed in the first round"
+				return ErrPointsOnFirstTrick
 			}
 		} else {
-			return "Must follow suit"
+			return ErrMustFollowSuit
 		}
 	}
 }
 
+// Returns the cards in the hand of the player at playerIndex which ValidPlay accepts right now
+// Returns an empty list if the player can't play at all, for instance because it isn't their turn
+func (t *Table) LegalPlays(playerIndex int) []*card.Card {
+	legal := make([]*card.Card, 0)
+	for _, c := range t.players[playerIndex].GetHand() {
+		if t.ValidPlay(c, playerIndex) == nil {
+			legal = append(legal, c)
+		}
+	}
+	return legal
+}
+
+// Returns the legal play of the player at playerIndex with the lowest face, the lowest suit breaking ties
+// This is the card played for a player who runs out of time. Returns nil if they have no legal play
+func (t *Table) LowestLegalPlay(playerIndex int) *card.Card {
+	var lowest *card.Card
+	for _, c := range t.LegalPlays(playerIndex) {
+		if lowest == nil || c.GetFace() < lowest.GetFace() || c.GetFace() == lowest.GetFace() && c.GetSuit() < lowest.GetSuit() {
+			lowest = c
+		}
+	}
+	return lowest
+}
+
+// Returns the cards in the hand of the player at playerIndex which may be chosen for their pass
+// Any PassSize of them make a valid pass. Returns an empty list if the player has nothing to pass this round
+func (t *Table) LegalPasses(playerIndex int) []*card.Card {
+	legal := make([]*card.Card, 0)
+	p := t.players[playerIndex]
+	if t.dir == direction.None || p.GetDonePassing() || len(p.GetHand()) < PassSize {
+		return legal
+	}
+	return append(legal, p.GetHand()...)
+}
+
 // Returns true if all players have their initial dealt hands
 func (t *Table) AllDoneDealing() bool {
 	for _, p := range t.players {
@@ -295,29 +603,59 @@ func (t *Table) SendTrick(recipient int) bool {
 }
 
 // Returns the score of the current round
-// Accounts for a player possibly shooting the moon
+// Accounts for a player possibly shooting the moon, as well as any other rule variants of t
 func (t *Table) ScoreRound() []int {
-	allPoints := 26
 	roundScores := make([]int, len(t.players))
-	shotMoon := false
-	shooter := -1
-	for i := 0; i < len(t.players); i++ {
-		roundScores[i] = t.players[i].CalculateScore()
-		if roundScores[i] == allPoints {
-			shotMoon = true
-			shooter = i
+	for i, p := range t.players {
+		for _, c := range p.GetTricks() {
+			roundScores[i] += t.rules.CardPoints(c)
 		}
 	}
-	if shotMoon {
-		for i := 0; i < len(t.players); i++ {
-			if i == shooter {
-				roundScores[i] = 0
-			} else {
-				roundScores[i] = allPoints
+	if shooter := t.Shooter(); shooter != -1 {
+		tookAllTricks := len(t.players[shooter].GetTricks()) == len(t.allCards)
+		t.rules.applyMoon(roundScores, shooter, t.moonPoints(), tookAllTricks)
+	}
+	return roundScores
+}
+
+// Returns the index of the player who has taken every point card this round, -1 if no player has
+func (t *Table) Shooter() int {
+	allPoints := t.moonPoints()
+	for i, p := range t.players {
+		points := 0
+		for _, c := range p.GetTricks() {
+			if c.WorthPoints() {
+				points += t.rules.CardPoints(c)
 			}
 		}
+		if points == allPoints {
+			return i
+		}
 	}
-	return roundScores
+	return -1
+}
+
+// Returns the points of all the point cards in the deck
+func (t *Table) moonPoints() int {
+	allPoints := 0
+	for _, c := range t.allCards {
+		if c.WorthPoints() {
+			allPoints += t.rules.CardPoints(c)
+		}
+	}
+	return allPoints
+}
+
+// Returns the index of the player who has taken c in a trick this round, -1 if no player has
+func (t *Table) TrickTaker(c *card.Card) int {
+	for i, p := range t.players {
+		for _, taken := range p.GetTricks() {
+			if taken == c {
+				return i
+			}
+		}
+	}
+	return -1
 }
 
 // Adds the scores of the current round to the players total scores
@@ -327,32 +665,78 @@ func (t *Table) UpdatePlayerScores(roundScores []int) {
 	}
 }
 
+// Sets the source of randomness t deals from, so that a series of deals can be reproduced
+func (t *Table) SetRand(r *rand.Rand) {
+	t.rng = r
+}
+
+// Returns a new random seed for DealSeed, drawn from the source of randomness of t
+func (t *Table) NewDealSeed() int64 {
+	return t.rng.Int63()
+}
+
 // Returns set of hands with random, even card distribution
 func (t *Table) Deal() [][]*card.Card {
+	return t.DealSeed(t.NewDealSeed())
+}
+
+// Returns set of hands with even card distribution, shuffled by seed
+// Dealing the same seed on tables with the same number of players always gives the same hands
+func (t *Table) DealSeed(seed int64) [][]*card.Card {
 	numPlayers := len(t.players)
 	allHands := make([][]*card.Card, numPlayers)
-	shuffle := rand.Perm(len(t.allCards))
+	shuffle := rand.New(rand.NewSource(seed)).Perm(len(t.allCards))
 	for i := 0; i < len(t.allCards); i++ {
 		allHands[i%numPlayers] = append(allHands[i%numPlayers], t.allCards[shuffle[i]])
 	}
 	return allHands
 }
 
+// Given a predefined set of hands, one per player, returns the same hands made of the cards of t
+// Returns ErrInvalidLayout unless every card in the deck of t appears exactly once, split evenly between the players
+func (t *Table) DealLayout(layout [][]*card.Card) ([][]*card.Card, error) {
+	if len(layout) != len(t.players) {
+		return nil, ErrInvalidLayout
+	}
+	allHands := make([][]*card.Card, len(layout))
+	dealt := make(map[*card.Card]bool)
+	for i, hand := range layout {
+		if len(hand) != len(t.allCards)/len(t.players) {
+			return nil, ErrInvalidLayout
+		}
+		for _, c := range hand {
+			tableCard := t.GetCard(c.GetFace(), c.GetSuit())
+			if tableCard == nil || dealt[tableCard] {
+				return nil, ErrInvalidLayout
+			}
+			dealt[tableCard] = true
+			allHands[i] = append(allHands[i], tableCard)
+		}
+	}
+	return allHands, nil
+}
+
 // Returns an array of the current round's scores, and an array of the game winners
 // The winners array is empty if the game hasn't been won yet, contains all playerIndices of the winners if it has
 func (t *Table) EndRound() ([]int, []int) {
 	roundScores := t.ScoreRound()
 	t.UpdatePlayerScores(roundScores)
-	lowestScore := -1
+	lowestScore := t.players[0].GetScore()
 	winningPlayers := make([]int, 0)
 	winTriggered := false
-	t.dir = (t.dir + 1) % 4
+	dirs := t.passDirections()
+	for i, d := range dirs {
+		if d == t.dir {
+			t.dir = dirs[(i+1)%len(dirs)]
+			break
+		}
+	}
 	for _, p := range t.players {
 		p.ResetTricks()
 		if p.GetScore() >= t.winCondition {
 			winTriggered = true
 		}
-		if p.GetScore() < lowestScore || lowestScore == -1 {
+		if p.GetScore() < lowestScore {
 			lowestScore = p.GetScore()
 		}
 	}
@@ -379,7 +763,7 @@ func (t *Table) NewRound() {
 		} else {
 			p.SetDonePassing(true)
 			p.SetDoneTaking(true)
-			if p.HasTwoOfClubs() {
+			if p.HasCard(t.GetFirstLead()) {
 				t.SetFirstPlayer(p.GetPlayerIndex())
 			}
 		}
@@ -8,6 +8,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"time"
 
 	"v.io/v23"
@@ -88,7 +89,11 @@ func onStart(glctx gl.Context, u *uistate.UIState) {
 	ctx, shutdown := v23.Init()
 	u.Shutdown = shutdown
 	u.Ctx = ctx
-	u.Service = syncbase.NewService(util.MountPoint + "/croupier/" + util.SBName)
+	// v23.Init has parsed the command line, config flags included
+	if err := util.LoadConfig(); err != nil {
+		fmt.Println("Config error:", err)
+	}
+	service := syncbase.NewService(util.MountPoint + "/croupier/" + util.SBName)
 	namespace := v23.GetNamespace(u.Ctx)
 	allAccess := access.AccessList{In: []security.BlessingPattern{"..."}}
 	permissions := access.Permissions{
@@ -98,14 +103,16 @@ func onStart(glctx gl.Context, u *uistate.UIState) {
 		"Resolve": allAccess,
 		"Debug":   allAccess,
 	}
+	// every device mounts its syncgroups under the mount point, so the namespace stays open to all
 	namespace.SetPermissions(u.Ctx, util.MountPoint, permissions, "")
 	namespace.SetPermissions(u.Ctx, util.MountPoint+"/croupier", permissions, "")
-	u.Service.SetPermissions(u.Ctx, permissions, "")
+	service.SetPermissions(u.Ctx, sync.ServicePermissions(u.Ctx), "")
+	u.Store = sync.NewSyncbaseStore(service, u.Ctx)
 	u.Images = glutil.NewImages(glctx)
 	fps = debug.NewFPS(u.Images)
 	u.Eng = glsprite.Engine(u.Images)
 	u.Texs = texture.LoadTextures(u.Eng)
-	u.CurTable = table.InitializeGame(u.NumPlayers, u.Texs)
+	u.CurTable = table.InitializeGame(u.NumPlayers, table.ClassicRules())
 	sound.InitPlayers(u)
 	sync.CreateTables(u)
 	// Create watch stream to update game state based on Syncbase updates
@@ -122,7 +129,8 @@ func onStop(u *uistate.UIState) {
 }
 
 func onPaint(glctx gl.Context, sz size.Event, u *uistate.UIState) {
-	if u.CurView == uistate.None {
+	// the game the app was in when it last stopped is rejoined, if it is still going
+	if u.CurView == uistate.None && !sync.ResumeGame(u) {
 		u.ScanChan = make(chan bool)
 		go sync.ScanForSG(u.Ctx, u.ScanChan, u)
 		view.LoadDiscoveryView(u)
@@ -0,0 +1,134 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// rating estimates how well each user plays from the games they finish.
+// A rating is a TrueSkill-style belief about a user's skill: a mean and the uncertainty around it.
+// Hearts games have four-way outcomes, so each game is scored as every pair of players meeting,
+// the one with the lower total winning, and the updates from each pairing are summed.
+
+package rating
+
+import (
+	"math"
+)
+
+const (
+	// the mean and uncertainty a user starts with
+	InitialMu    = 25.0
+	InitialSigma = InitialMu / 3
+	// the spread of performances around a player's skill in a single game
+	beta = InitialSigma / 2
+	// the uncertainty added before each game, so ratings keep moving as players improve
+	tau = InitialSigma / 100
+	// how often two equally rated players draw, which sets how close their performances must be to count as one
+	drawProbability = 0.1
+	// the smallest fraction of its variance an update may leave a rating with
+	minVarianceFactor = 0.0001
+)
+
+// how close two performances must be to count as a draw
+var drawMargin = 2 * beta * math.Erfinv(drawProbability)
+
+type Rating struct {
+	Mu    float64 // the estimated skill
+	Sigma float64 // the uncertainty of the estimate
+	Games int     // the number of games the rating was updated from
+}
+
+// Returns the rating of a user who hasn't finished a game
+func Default() Rating {
+	return Rating{Mu: InitialMu, Sigma: InitialSigma}
+}
+
+// Returns the skill the user is very likely to have at least, which is what leaderboards show
+// Every user starts at 0, and the value never drops below it
+func (r Rating) Display() int {
+	conservative := r.Mu - 3*r.Sigma
+	if conservative < 0 {
+		return 0
+	}
+	return int(conservative + 0.5)
+}
+
+// Returns the ratings of the players after a game in which they scored scores
+// ratings and scores are indexed by seat; the lower score ranks higher, and equal scores draw
+func Update(ratings []Rating, scores []int) []Rating {
+	updated := make([]Rating, len(ratings))
+	for i, ri := range ratings {
+		varI := ri.Sigma*ri.Sigma + tau*tau
+		sigmaI := math.Sqrt(varI)
+		var meanShift, varianceShrink float64
+		for j, rj := range ratings {
+			if i == j {
+				continue
+			}
+			varJ := rj.Sigma*rj.Sigma + tau*tau
+			c := math.Sqrt(2*beta*beta + varI + varJ)
+			t := (ri.Mu - rj.Mu) / c
+			e := drawMargin / c
+			switch {
+			case scores[i] < scores[j]:
+				meanShift += varI / c * vWin(t, e)
+				varianceShrink += varI / (c * c) * wWin(t, e)
+			case scores[i] > scores[j]:
+				meanShift -= varI / c * vWin(-t, e)
+				varianceShrink += varI / (c * c) * wWin(-t, e)
+			default:
+				meanShift += varI / c * vDraw(t, e)
+				varianceShrink += varI / (c * c) * wDraw(t, e)
+			}
+		}
+		updated[i] = Rating{
+			Mu:    ri.Mu + meanShift,
+			Sigma: sigmaI * math.Sqrt(math.Max(1-varianceShrink, minVarianceFactor)),
+			Games: ri.Games + 1,
+		}
+	}
+	return updated
+}
+
+func pdf(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+func cdf(x float64) float64 {
+	return math.Erfc(-x/math.Sqrt2) / 2
+}
+
+// Returns how far the mean of the winner of a pairing moves, in units of the pairing's spread
+func vWin(t, e float64) float64 {
+	d := cdf(t - e)
+	if d < 1e-12 {
+		return e - t
+	}
+	return pdf(t-e) / d
+}
+
+// Returns how much the variance of the winner of a pairing shrinks
+func wWin(t, e float64) float64 {
+	v := vWin(t, e)
+	return v * (v + t - e)
+}
+
+// Returns how far the mean of a player who drew a pairing moves
+func vDraw(t, e float64) float64 {
+	d := cdf(e-t) - cdf(-e-t)
+	if d < 1e-12 {
+		if t < 0 {
+			return -t - e
+		}
+		return -t + e
+	}
+	return (pdf(-e-t) - pdf(e-t)) / d
+}
+
+// Returns how much the variance of a player who drew a pairing shrinks
+func wDraw(t, e float64) float64 {
+	d := cdf(e-t) - cdf(-e-t)
+	if d < 1e-12 {
+		return 1
+	}
+	v := vDraw(t, e)
+	return v*v + ((e-t)*pdf(e-t)+(e+t)*pdf(e+t))/d
+}
@@ -0,0 +1,41 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// clock.go contains Clock, the Lamport clock game log keys are timestamped with
+// Every device advances its clock past each log entry it reads, so an entry written after reading another
+// always sorts after it, however far apart the devices' own clocks are.
+// Entries written at the same time on different devices are ordered by the player in their keys.
+
+package replay
+
+import (
+	"sync"
+)
+
+type Clock struct {
+	mu   sync.Mutex
+	time int64
+}
+
+// Returns a clock which has seen nothing yet
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Advances the clock to the timestamp of an entry read from the log, if that is later
+func (c *Clock) Observe(timestamp int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if timestamp > c.time {
+		c.time = timestamp
+	}
+}
+
+// Advances the clock and returns the timestamp for a new entry, later than every entry seen or written so far
+func (c *Clock) Tick() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.time++
+	return c.time
+}
@@ -0,0 +1,307 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// command.go contains GameCommand, the typed form of a game log value, and the codec between the two.
+// A command is written as <Type>|<player>:<field>:...:END, where each field is either a card such as
+// "classic h10", or the seed of a deal such as "seed 42". TakeTrick has no player: TakeTrick|END
+// A sealed Pass carries its commitment and boxes instead of cards, such as "commit 3fa9...:box Qm9v...".
+// The steps of a sealed deal carry points and keys, such as "point AbC...": a Shuffle commits to the player's seed
+// and carries the deck it leaves, as does a Lock, and an Unlock carries keys such as "key QkR...".
+// A Shuffle also escrows shares of the seed with the other players, such as "share 9c1e...:escrow Zm9v...",
+// and a Release hands one of them over for a player who stalled, along with the openings of passes it knows.
+// A Reveal carries the player's seed and the opening of their sealed pass, such as "opening MWYy..."
+// A command committed through the proposals protocol names the proposal it commits, such as "proposal 20-1"
+// A signed command ends with its writer's signature, such as "sig Rk9v...", which sign.go describes
+
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"hearts/logic/card"
+)
+
+var (
+	// ErrMalformedCommand is returned by Decode for a value which doesn't follow the log syntax,
+	// and by Encode for a command which can't be written in it
+	ErrMalformedCommand = errors.New("Malformed game command")
+	// ErrUnknownCommand is returned by Decode and Encode for a command type they don't know
+	ErrUnknownCommand = errors.New("Unknown game command")
+)
+
+type GameCommand struct {
+	Type       string       // one of Deal, Pass, Take, Play, TakeTrick, Ready, Reveal, Timeout, Shuffle, Lock, Unlock or Release
+	Player     int          // the player the command is about, -1 for TakeTrick, and for a Release the player who stalled
+	Cards      []*card.Card // cards dealt, passed or played, by the player or by a Timeout. These aren't the cards of any table
+	Seed       int64        // the seed a Deal was shuffled with
+	HasSeed    bool         // true if Seed is set
+	Sealed     *Sealed      // for a Pass, the cards hidden from the other players, set instead of Cards
+	Commitment string       // for a Shuffle, the commitment to the seed the player deals with
+	Points     []string     // for a Shuffle or Lock, the deck the step leaves
+	Keys       []string     // for an Unlock, the keys to the player's locks on every card not dealt to them
+	Shares     []string     // for a Shuffle, the commitment to each share of the seed it escrows, and for a Release, the share
+	Escrow     []string     // for a Shuffle, each share sealed to the player it is escrowed with, in seat order
+	Openings   []string     // for a Reveal, the player's seed and then the opening of their pass; for a Release, passes it opened
+	Proposal   string       // for a command committed through the proposals protocol, the ID of the proposal
+	Signature  string       // the writer's signature, read by Decode. Encode leaves it out: commands are signed with HandKey.Sign
+}
+
+// Returns the number of cards a command of type commandType must carry, or -1 if it may carry any number
+func cardCount(commandType string) (int, error) {
+	switch commandType {
+	case Deal, Pass:
+		return -1, nil
+	case Play, Timeout:
+		return 1, nil
+	case Take, TakeTrick, Ready, Reveal, Shuffle, Lock, Unlock, Release:
+		return 0, nil
+	}
+	return 0, ErrUnknownCommand
+}
+
+// Returns c written in the log syntax
+func (c *GameCommand) Encode() (string, error) {
+	count, err := cardCount(c.Type)
+	if err != nil {
+		return "", err
+	}
+	if (count >= 0 && len(c.Cards) != count) || (c.HasSeed && c.Type != Deal) || !c.validSecrets() {
+		return "", ErrMalformedCommand
+	}
+	if c.Type == TakeTrick {
+		return TakeTrick + Bar + End, nil
+	}
+	if c.Player < 0 {
+		return "", ErrMalformedCommand
+	}
+	value := c.Type + Bar + strconv.Itoa(c.Player) + Colon
+	for _, cd := range c.Cards {
+		if cd == nil || cd.GetSuit() == card.UnknownSuit || cd.GetFace() == card.UnknownFace {
+			return "", ErrMalformedCommand
+		}
+		value += CardType + Space + cd.GetSuit().String() + cd.GetFace().String() + Colon
+	}
+	if c.Sealed != nil {
+		value += Commit + Space + c.Sealed.Commitment + Colon
+		for _, box := range c.Sealed.Boxes {
+			value += Box + Space + box + Colon
+		}
+	}
+	if c.Commitment != "" {
+		value += Commit + Space + c.Commitment + Colon
+	}
+	for _, point := range c.Points {
+		value += Point + Space + point + Colon
+	}
+	for _, key := range c.Keys {
+		value += LockKey + Space + key + Colon
+	}
+	for _, share := range c.Shares {
+		value += Share + Space + share + Colon
+	}
+	for _, box := range c.Escrow {
+		value += Escrow + Space + box + Colon
+	}
+	for _, opening := range c.Openings {
+		value += Opening + Space + opening + Colon
+	}
+	if c.Proposal != "" {
+		value += Proposed + Space + c.Proposal + Colon
+	}
+	if c.HasSeed {
+		value += Seed + Space + strconv.FormatInt(c.Seed, 10) + Colon
+	}
+	return value + End, nil
+}
+
+// Returns the command written in value
+func Decode(value string) (*GameCommand, error) {
+	commandParts := strings.Split(value, Bar)
+	if len(commandParts) != 2 {
+		return nil, ErrMalformedCommand
+	}
+	c := &GameCommand{Type: commandParts[0], Player: -1, Cards: make([]*card.Card, 0)}
+	count, err := cardCount(c.Type)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(commandParts[1], Colon)
+	if fields[len(fields)-1] != End {
+		return nil, ErrMalformedCommand
+	}
+	fields = fields[:len(fields)-1]
+	if n := len(fields); n > 0 {
+		if last := strings.Split(fields[n-1], Space); len(last) == 2 && last[0] == Signature {
+			c.Signature = last[1]
+			fields = fields[:n-1]
+		}
+	}
+	if c.Type == TakeTrick {
+		if len(fields) != 0 {
+			return nil, ErrMalformedCommand
+		}
+		return c, nil
+	}
+	if len(fields) == 0 {
+		return nil, ErrMalformedCommand
+	}
+	if c.Player, err = parseIndex(fields[0]); err != nil {
+		return nil, err
+	}
+	for _, field := range fields[1:] {
+		fieldParts := strings.Split(field, Space)
+		if len(fieldParts) != 2 || c.HasSeed {
+			return nil, ErrMalformedCommand
+		}
+		switch fieldParts[0] {
+		case CardType:
+			cd, err := parseCard(fieldParts[1])
+			if err != nil {
+				return nil, err
+			}
+			c.Cards = append(c.Cards, cd)
+		case Seed:
+			if c.Type != Deal {
+				return nil, ErrMalformedCommand
+			}
+			if c.Seed, err = strconv.ParseInt(fieldParts[1], 10, 64); err != nil {
+				return nil, ErrMalformedCommand
+			}
+			c.HasSeed = true
+		case Commit:
+			if c.Sealed != nil || c.Commitment != "" {
+				return nil, ErrMalformedCommand
+			}
+			if c.Type == Shuffle {
+				c.Commitment = fieldParts[1]
+			} else {
+				c.Sealed = &Sealed{Commitment: fieldParts[1]}
+			}
+		case Box:
+			if c.Sealed == nil {
+				return nil, ErrMalformedCommand
+			}
+			c.Sealed.Boxes = append(c.Sealed.Boxes, fieldParts[1])
+		case Point:
+			c.Points = append(c.Points, fieldParts[1])
+		case LockKey:
+			c.Keys = append(c.Keys, fieldParts[1])
+		case Share:
+			c.Shares = append(c.Shares, fieldParts[1])
+		case Escrow:
+			c.Escrow = append(c.Escrow, fieldParts[1])
+		case Opening:
+			c.Openings = append(c.Openings, fieldParts[1])
+		case Proposed:
+			if c.Proposal != "" {
+				return nil, ErrMalformedCommand
+			}
+			c.Proposal = fieldParts[1]
+		default:
+			return nil, ErrMalformedCommand
+		}
+	}
+	if (count >= 0 && len(c.Cards) != count) || !c.validSecrets() {
+		return nil, ErrMalformedCommand
+	}
+	return c, nil
+}
+
+// Returns true if c carries sealed cards, the steps of a sealed deal, shares or openings only where its type allows
+// them, and they can be written. Sealed cards replace the cards of a Pass
+func (c *GameCommand) validSecrets() bool {
+	fields := append(append(append([]string{}, c.Points...), c.Keys...), c.Openings...)
+	fields = append(append(fields, c.Shares...), c.Escrow...)
+	if c.Sealed != nil {
+		if c.Type != Pass || len(c.Cards) > 0 || len(c.Sealed.Boxes) == 0 {
+			return false
+		}
+		fields = append(append(fields, c.Sealed.Commitment), c.Sealed.Boxes...)
+	}
+	if c.Commitment != "" {
+		fields = append(fields, c.Commitment)
+	}
+	if c.Proposal != "" {
+		fields = append(fields, c.Proposal)
+	}
+	if (c.Type == Shuffle) != (c.Commitment != "") || (c.Type == Shuffle || c.Type == Lock) != (len(c.Points) > 0) ||
+		(c.Type == Unlock) != (len(c.Keys) > 0) || (c.Type == Shuffle) != (len(c.Escrow) > 0) ||
+		(c.Type == Shuffle || c.Type == Release) != (len(c.Shares) > 0) {
+		return false
+	}
+	if c.Type == Release {
+		if len(c.Shares) != 1 {
+			return false
+		}
+	} else if (c.Type == Reveal) != (len(c.Openings) > 0) {
+		return false
+	}
+	for _, f := range fields {
+		if !validField(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if s can be written as the value of a field
+func validField(s string) bool {
+	return s != "" && !strings.ContainsAny(s, Bar+Colon+Space)
+}
+
+// Reads a card written like "h10" or "sq"
+func parseCard(suitFace string) (*card.Card, error) {
+	if len(suitFace) < 2 {
+		return nil, ErrMalformedCommand
+	}
+	suit := card.ConvertToSuit(suitFace[:1])
+	face := card.ConvertToFace(suitFace[1:])
+	if suit == card.UnknownSuit || face == card.UnknownFace {
+		return nil, ErrMalformedCommand
+	}
+	return card.NewCard(face, suit), nil
+}
+
+// the number of digits in the longest int64
+const timestampDigits = 19
+
+// Reads a non-negative index written in decimal, rejecting signs and leading zeros so every index has one spelling
+func parseIndex(s string) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 || strconv.Itoa(i) != s {
+		return -1, ErrMalformedCommand
+	}
+	return i, nil
+}
+
+// Returns the key of a game log entry written by playerIndex at timestamp, read from a Clock
+// The timestamp is padded with zeros to a fixed width, so that keys sort in the order of their timestamps
+// Note: Croupier in Dart/Flutter writes unpadded wall clock milliseconds here instead, so its keys sort apart from these.
+func LogKey(gameID int, timestamp int64, playerIndex int) string {
+	return fmt.Sprintf("%d/log/%0*d%s%d", gameID, timestampDigits, timestamp, Dash, playerIndex)
+}
+
+// Returns the timestamp and writer of a game log key, and false if key isn't a game log key
+func ParseLogKey(key string) (int64, int, bool) {
+	keyParts := strings.Split(key, "/")
+	if len(keyParts) != 3 || keyParts[1] != "log" {
+		return 0, -1, false
+	}
+	timePlayer := strings.Split(keyParts[2], Dash)
+	if len(timePlayer) != 2 {
+		return 0, -1, false
+	}
+	timestamp, err := strconv.ParseInt(timePlayer[0], 10, 64)
+	if err != nil {
+		return 0, -1, false
+	}
+	playerIndex, err := parseIndex(timePlayer[1])
+	if err != nil {
+		return 0, -1, false
+	}
+	return timestamp, playerIndex, true
+}
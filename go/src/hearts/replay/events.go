@@ -0,0 +1,180 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// events.go contains the events a Replayer produces as it applies log entries
+// Each event describes a change that has already been made to the table
+
+package replay
+
+import (
+	"time"
+
+	"hearts/logic/card"
+)
+
+// Event is implemented by every event type in this file
+type Event interface {
+	event()
+}
+
+// A player was seated, or moved to another seat
+type PlayerNumEvent struct {
+	UserID    int
+	PlayerNum int
+}
+
+// A player advertised the name of the syncgroup holding their settings
+type SettingsEvent struct {
+	UserID int
+	Name   string
+}
+
+// A player recorded the blessings their device writes to the game log with
+type BlessingsEvent struct {
+	UserID    int
+	Blessings []string
+}
+
+// A player published the public key cards are sealed to for them
+type HandKeyEvent struct {
+	UserID int
+	Key    string
+}
+
+// The status of the game changed
+type StatusEvent struct {
+	Status Status
+}
+
+// The game finished, with Result
+type ResultEvent struct {
+	Result *Result
+}
+
+// Player wrote Step, one of Shuffle, Lock or Unlock, of the deal of a sealed round
+type DealStepEvent struct {
+	Player int
+	Step   string
+}
+
+// A hand was dealt to Player. Cards are hidden cards if the hand was dealt sealed to another player
+type DealEvent struct {
+	Player  int
+	Cards   []*card.Card
+	Seed    int64
+	HasSeed bool // false if the deal was logged without a seed
+}
+
+// The hand of Player, who stalled, was released by the other players. Cards are the cards they now hold
+type ReleaseEvent struct {
+	Player int
+	Cards  []*card.Card
+}
+
+// Every player has been dealt a hand and a new round has begun
+type NewRoundEvent struct{}
+
+// Player passed Cards to Recipient
+type PassEvent struct {
+	Player    int
+	Recipient int
+	Cards     []*card.Card
+}
+
+// Player took the Cards passed to them
+type TakeEvent struct {
+	Player int
+	Cards  []*card.Card
+}
+
+// Player holds the first lead and will open the first trick
+type FirstPlayerEvent struct {
+	Player int
+}
+
+// Player played Card. If that completed the trick, TrickOver is set and Recipient will take it
+type PlayEvent struct {
+	Player    int
+	Card      *card.Card
+	TrickOver bool
+	Recipient int
+	TimedOut  bool // true if Player ran out of time, and Card was played for them
+}
+
+// Recipient took the trick made of Cards
+// If that ended the round, RoundOver is set along with the round's scores, and the game's winners if there are any
+type TakeTrickEvent struct {
+	Recipient   int
+	Cards       []*card.Card
+	RoundOver   bool
+	RoundScores []int
+	Winners     []int
+	MoonShooter int // once the round is over, the player who took every point card, or -1
+	QueenTaker  int // once the round is over, the player who took the Queen of Spades
+}
+
+// The owner set the time limit on each move. A Limit of 0 means there is none
+type TurnLimitEvent struct {
+	Limit time.Duration
+}
+
+// Player is ready for the next round. AllReady is set once every player is
+type ReadyEvent struct {
+	Player   int
+	AllReady bool
+}
+
+// A command which breaks the rules was found in the log, and ignored
+// Writer is the player whose key the entry was written under; Player is the player the command is about
+// An entry which can't be told apart as a player's command, such as an invalid status or a log entry under a key
+// which can't be read, has Writer and Player -1 and Command set to the kind of key it was written under
+type ViolationEvent struct {
+	Writer  int
+	Player  int
+	Command string
+	Err     error
+}
+
+// Player revealed their sealed cards, after the round. Cards is the hand they were dealt
+type RevealEvent struct {
+	Player int
+	Cards  []*card.Card
+}
+
+// Every player has revealed their sealed cards, and the round was replayed with every hand known
+// Violations holds the commands found to break the rules, which were applied when the round was played
+type AuditEvent struct {
+	Violations []ViolationEvent
+}
+
+// Player wrote a proposal, either their own or one they agree with
+// Proposals holds the proposal of every seat; Agreed is set once they all hold Lowest, and it may be committed
+type ProposalEvent struct {
+	Player    int
+	Proposals []*Proposal
+	Lowest    *Proposal
+	Agreed    bool
+}
+
+func (PlayerNumEvent) event()   {}
+func (SettingsEvent) event()    {}
+func (BlessingsEvent) event()   {}
+func (HandKeyEvent) event()     {}
+func (StatusEvent) event()      {}
+func (ResultEvent) event()      {}
+func (DealStepEvent) event()    {}
+func (DealEvent) event()        {}
+func (ReleaseEvent) event()     {}
+func (NewRoundEvent) event()    {}
+func (PassEvent) event()        {}
+func (TakeEvent) event()        {}
+func (FirstPlayerEvent) event() {}
+func (PlayEvent) event()        {}
+func (TakeTrickEvent) event()   {}
+func (ReadyEvent) event()       {}
+func (ViolationEvent) event()   {}
+func (RevealEvent) event()      {}
+func (AuditEvent) event()       {}
+func (ProposalEvent) event()    {}
+func (TurnLimitEvent) event()   {}
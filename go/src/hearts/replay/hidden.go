@@ -0,0 +1,265 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// hidden.go applies rounds whose hands are sealed, which no device can see all of.
+// Cards a device can't open are held as hidden cards, so that every hand keeps its size. The moves of a player whose
+// hand is sealed are only checked against what every device can see, so that all devices accept the same entries.
+// Once the round is over each player reveals the seed they dealt with and the opening of their sealed pass, and when
+// all of them have, the steps of the deal are checked against the seeds and the round is replayed with every hand
+// known, to check the rest: the passes and every play.
+
+package replay
+
+import (
+	"errors"
+	"fmt"
+
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+var (
+	// ErrNothingToReveal is returned in a ViolationEvent for a Reveal of cards which weren't sealed this round,
+	// or written before the round is over
+	ErrNothingToReveal = errors.New("No sealed cards to reveal")
+	// ErrAlreadyRevealed is returned in a ViolationEvent for a player revealing different openings a second time
+	ErrAlreadyRevealed = errors.New("Cards already revealed")
+	// ErrNotRevealed is returned in a ViolationEvent for a player getting ready for the next round before revealing
+	// their sealed cards, which would leave the round unaudited
+	ErrNotRevealed = errors.New("Sealed cards not revealed")
+	// ErrAudit wraps the violations found when a sealed round is replayed with every hand known
+	ErrAudit = errors.New("Round failed audit")
+)
+
+// sealedRound records a round dealt with sealed hands, until it has been audited
+type sealedRound struct {
+	start        *table.Table // the table before the round was dealt
+	deal         *shuffleDeal
+	entries      []roundEntry // the commands applied since the deal, in log order
+	passes       map[int]*Sealed
+	openedPasses map[int]string         // the openings of the passes this device could open
+	revealed     map[int][]string       // the openings each player has revealed, or which were released for them
+	hands        map[int][]*card.Card   // the hands the players' revealed seeds unlock
+	releases     map[int]map[int][]byte // the shares released of the seed of each player who stalled, by holder
+	released     map[int]bool           // true for a player whose hand has been released
+}
+
+type roundEntry struct {
+	writer  int
+	command *GameCommand
+}
+
+func newSealedRound(start *table.Table, deal *shuffleDeal) *sealedRound {
+	return &sealedRound{
+		start:        start,
+		deal:         deal,
+		passes:       make(map[int]*Sealed),
+		openedPasses: make(map[int]string),
+		revealed:     make(map[int][]string),
+		hands:        make(map[int][]*card.Card),
+		releases:     make(map[int]map[int][]byte),
+		released:     make(map[int]bool),
+	}
+}
+
+// Sets the key r opens the cards sealed to this device with
+func (r *Replayer) SetHandKey(k *HandKey) {
+	r.key = k
+}
+
+// Returns the openings of each player this device dealt for, once the round is over and until that player has
+// revealed them: the seed they dealt with, followed by the opening of their pass if they made one
+func (r *Replayer) DueReveals() map[int][]string {
+	due := make(map[int][]string)
+	if !r.sealedHand(0) || !r.table.RoundOver() || !r.table.TrickNew() {
+		return due
+	}
+	for p, seed := range r.round.deal.seeds {
+		if r.round.revealed[p] != nil {
+			continue
+		}
+		openings := []string{encoding.EncodeToString(seed)}
+		if r.round.passes[p] != nil {
+			pass, ok := r.round.openedPasses[p]
+			if !ok {
+				continue
+			}
+			openings = append(openings, pass)
+		}
+		due[p] = openings
+	}
+	return due
+}
+
+// Returns true if the hand of the player at playerIndex was dealt sealed this round
+func (r *Replayer) sealedHand(playerIndex int) bool {
+	return r.round != nil && r.round.deal.dealt
+}
+
+// Returns true if the player at playerIndex has sealed cards this round which they haven't revealed
+func (r *Replayer) unrevealed(playerIndex int) bool {
+	return r.sealedHand(playerIndex) && r.round.revealed[playerIndex] == nil
+}
+
+// Returns the cards of the table of r a sealed command carries, and the opening they were read from
+// Cards this device can't open are returned as new hidden cards, with an empty opening
+func (r *Replayer) unseal(c *GameCommand) ([]*card.Card, string, error) {
+	if r.key != nil {
+		if opening, ok := r.key.Open(c.Sealed); ok {
+			cards, err := c.Sealed.Reveal(opening)
+			if err != nil {
+				return nil, "", err
+			}
+			tableCards, err := r.tableCards(cards)
+			return tableCards, opening, err
+		}
+	}
+	return hiddenCards(table.PassSize), "", nil
+}
+
+// Returns n new hidden cards
+func hiddenCards(n int) []*card.Card {
+	hidden := make([]*card.Card, n)
+	for i := range hidden {
+		hidden[i] = card.NewHiddenCard()
+	}
+	return hidden
+}
+
+// Records the applied command c, carrying sealed cards opened from opening if it had any
+func (r *Replayer) record(writer int, c *GameCommand, opening string) {
+	if r.round == nil || c.Type == Ready {
+		return
+	}
+	r.round.entries = append(r.round.entries, roundEntry{writer: writer, command: c})
+	if c.Sealed == nil {
+		return
+	}
+	r.round.passes[c.Player] = c.Sealed
+	if opening != "" {
+		r.round.openedPasses[c.Player] = opening
+	}
+}
+
+// Removes c from the hand of the player at playerIndex, or a hidden card in its place if the hand is sealed
+func removeFromHand(t *table.Table, c *card.Card, playerIndex int) {
+	p := t.GetPlayers()[playerIndex]
+	for _, h := range p.GetHand() {
+		if h == c {
+			p.RemoveFromHand(c)
+			return
+		}
+	}
+	for _, h := range p.GetHand() {
+		if h.Hidden() {
+			p.RemoveFromHand(h)
+			return
+		}
+	}
+}
+
+// Checks the seed and pass opening revealed by a player against their Shuffle and pass,
+// and audits the round once every player has revealed
+func (r *Replayer) onReveal(writer int, c *GameCommand) ([]Event, error) {
+	violation := func(err error) ([]Event, error) {
+		return r.violation(ViolationEvent{Writer: writer, Player: c.Player, Command: c.Type, Err: err}), nil
+	}
+	round := r.round
+	if !r.sealedHand(c.Player) || !r.table.RoundOver() || !r.table.TrickNew() {
+		return violation(ErrNothingToReveal)
+	}
+	if previous := round.revealed[c.Player]; previous != nil {
+		if fmt.Sprint(previous) == fmt.Sprint(c.Openings) {
+			// the same openings written twice, for instance by a device which restarted
+			return nil, nil
+		}
+		return violation(ErrAlreadyRevealed)
+	}
+	pass := round.passes[c.Player]
+	expected := 1
+	if pass != nil {
+		expected = 2
+	}
+	if len(c.Openings) != expected {
+		return violation(ErrBadSeal)
+	}
+	seed, err := encoding.DecodeString(c.Openings[0])
+	if err != nil || commitment(seed) != round.deal.commitments[c.Player] {
+		return violation(ErrBadSeal)
+	}
+	if pass != nil {
+		if _, err := pass.Reveal(c.Openings[1]); err != nil {
+			return violation(ErrBadSeal)
+		}
+	}
+	// a seed which doesn't unlock a hand is left for the audit to blame on the step which went wrong
+	hand, _ := round.deal.hand(seed, c.Player, len(r.table.GetPlayers()))
+	round.revealed[c.Player] = c.Openings
+	round.hands[c.Player] = hand
+	events := []Event{RevealEvent{Player: c.Player, Cards: hand}}
+	if len(round.revealed) == len(r.table.GetPlayers()) {
+		events = append(events, r.audit()...)
+	}
+	return events, nil
+}
+
+// Checks every step of the deal against the seeds the players revealed, then replays the recorded round with every
+// hand known, on a copy of the table it started from
+// Returns the violations found, each wrapping ErrAudit, followed by an AuditEvent
+func (r *Replayer) audit() []Event {
+	found := r.auditDeal()
+	if len(found) == 0 {
+		a := New(r.round.start.Copy(), r.sequentialPhases)
+		entries := make([]roundEntry, 0, len(r.round.hands)+len(r.round.entries))
+		for p := range r.table.GetPlayers() {
+			entries = append(entries, roundEntry{writer: p, command: &GameCommand{Type: Deal, Player: p, Cards: r.round.hands[p]}})
+		}
+		for _, e := range append(entries, r.round.entries...) {
+			c := *e.command
+			if c.Sealed != nil {
+				c.Cards, _ = c.Sealed.Reveal(r.round.revealed[c.Player][1])
+				c.Sealed = nil
+			}
+			// the writer of a Timeout for a released hand was checked when it was applied
+			writer := e.writer
+			if c.Type == Timeout {
+				writer = c.Player
+			}
+			events, err := a.apply(writer, &c)
+			if err != nil {
+				found = append(found, ViolationEvent{Writer: e.writer, Player: c.Player, Command: c.Type, Err: err})
+			}
+			for _, event := range events {
+				if v, ok := event.(ViolationEvent); ok {
+					found = append(found, v)
+				}
+			}
+		}
+	}
+	events := make([]Event, 0, len(found)+1)
+	for i := range found {
+		found[i].Err = fmt.Errorf("%w: %w", ErrAudit, found[i].Err)
+		r.violations = append(r.violations, found[i])
+		events = append(events, found[i])
+	}
+	return append(events, AuditEvent{Violations: found})
+}
+
+// Returns a violation for each step of the deal which isn't the one the seed its player revealed makes
+func (r *Replayer) auditDeal() []ViolationEvent {
+	d := r.round.deal
+	numPlayers := len(r.table.GetPlayers())
+	found := make([]ViolationEvent, 0)
+	for _, step := range []string{Shuffle, Lock, Unlock} {
+		for p := 0; p < numPlayers; p++ {
+			seed, _ := encoding.DecodeString(r.round.revealed[p][0])
+			c, err := d.step(step, seed, p, numPlayers)
+			written := d.written(c)
+			if err != nil || !written {
+				found = append(found, ViolationEvent{Writer: p, Player: p, Command: step, Err: ErrBadShuffle})
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,155 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// proposal.go contains the proposals protocol described in schema.md, used to agree on one of several conflicting commands.
+// A player proposes a command by writing a Proposal under <game_id>/log/proposals/<player_number>. Every player then
+// writes the lowest proposal they have seen, ordered by (timestamp, player_number), under their own proposal key.
+// Once every seat holds the same proposal, its proposer commits it by writing the command to the log under a
+// new timestamp, naming the proposal's ID in the command. The proposals are then settled, and ignored if read again.
+
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Proposal struct {
+	Timestamp    int64  `json:"timestamp"`      // when the proposal was created, read from a Clock
+	Command      string `json:"command_string"` // what ought to be run if the proposal goes through
+	PlayerNumber int    `json:"player_number"`  // the player who proposed the command
+}
+
+// Returns true if p takes priority over other: it has the lower timestamp, or the lower player number if they tie
+func (p *Proposal) Less(other *Proposal) bool {
+	if p.Timestamp != other.Timestamp {
+		return p.Timestamp < other.Timestamp
+	}
+	return p.PlayerNumber < other.PlayerNumber
+}
+
+// Returns true if p and other propose the same command from the same player at the same time, or are both nil
+func (p *Proposal) Equal(other *Proposal) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	return *p == *other
+}
+
+// Returns the ID a command committing p names it by: its timestamp and player number, as <timestamp>-<player_number>
+func (p *Proposal) ID() string {
+	return fmt.Sprintf("%d%s%d", p.Timestamp, Dash, p.PlayerNumber)
+}
+
+// Returns the command p proposes, naming p, to be written to the log once every player agrees with it
+func (p *Proposal) Commit() (string, error) {
+	c, err := Decode(p.Command)
+	if err != nil {
+		return "", err
+	}
+	c.Proposal = p.ID()
+	return c.Encode()
+}
+
+// Returns p encoded to be written to the log
+func (p *Proposal) Encode() (string, error) {
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+// Returns the proposal encoded in value
+func DecodeProposal(value string) (*Proposal, error) {
+	p := &Proposal{}
+	if err := json.Unmarshal([]byte(value), p); err != nil {
+		return nil, ErrMalformedEntry
+	}
+	return p, nil
+}
+
+// Returns the key holding the proposal of the player at playerNumber
+func ProposalKey(gameID, playerNumber int) string {
+	return fmt.Sprintf("%d/log/proposals/%d", gameID, playerNumber)
+}
+
+// Returns the player number of a proposal key, and false if key isn't a proposal key
+func ParseProposalKey(key string) (int, bool) {
+	keyParts := strings.Split(key, "/")
+	if len(keyParts) != 4 || keyParts[1] != "log" || keyParts[2] != "proposals" {
+		return -1, false
+	}
+	playerNumber, err := parseIndex(keyParts[3])
+	if err != nil {
+		return -1, false
+	}
+	return playerNumber, true
+}
+
+// Returns the current proposal of each seat, with nil for seats which have none
+func (r *Replayer) Proposals() []*Proposal {
+	proposals := make([]*Proposal, len(r.table.GetPlayers()))
+	for i := range proposals {
+		proposals[i] = r.proposals[i]
+	}
+	return proposals
+}
+
+// Returns the lowest proposal held by any seat, or nil if there are none
+func (r *Replayer) LowestProposal() *Proposal {
+	var lowest *Proposal
+	for _, p := range r.proposals {
+		if lowest == nil || p.Less(lowest) {
+			lowest = p
+		}
+	}
+	return lowest
+}
+
+// Returns the proposal every seat agrees on, or nil if they don't all hold the same one
+func (r *Replayer) AgreedProposal() *Proposal {
+	lowest := r.LowestProposal()
+	if lowest == nil {
+		return nil
+	}
+	for i := range r.table.GetPlayers() {
+		if !lowest.Equal(r.proposals[i]) {
+			return nil
+		}
+	}
+	return lowest
+}
+
+func (r *Replayer) onProposal(playerNumber int, value string) ([]Event, error) {
+	p, err := DecodeProposal(value)
+	if err != nil {
+		return nil, err
+	}
+	numPlayers := len(r.table.GetPlayers())
+	if playerNumber >= numPlayers || p.PlayerNumber < 0 || p.PlayerNumber >= numPlayers {
+		return nil, ErrMalformedEntry
+	}
+	// a proposal which has already been committed is left over from an earlier round of the protocol
+	if r.settled[p.ID()] {
+		return nil, nil
+	}
+	r.proposals[playerNumber] = p
+	agreed := r.AgreedProposal()
+	return []Event{ProposalEvent{
+		Player:    playerNumber,
+		Proposals: r.Proposals(),
+		Lowest:    r.LowestProposal(),
+		Agreed:    agreed != nil,
+	}}, nil
+}
+
+// Records that the proposal with the ID id has been committed, settling the proposals if any seat still holds it
+func (r *Replayer) settle(id string) {
+	r.settled[id] = true
+	for _, p := range r.proposals {
+		if p.ID() == id {
+			r.proposals = make(map[int]*Proposal)
+			return
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// release.go lets a round whose hands are sealed go on when a player stops moving.
+// Each player's Shuffle escrows their seed with the other players: split into one share for each, sealed to the key
+// of the player who holds it, and committed to so that the share can be checked once it is handed over. When a player
+// has had twice their time for a move, each of the other players releases the share they hold, along with the
+// openings they know of the passes to and from the player who stalled. Once all of them have, every device knows the
+// stalled hand and checks the moves made for it in full, and the player counts as revealed when the round is audited.
+
+package replay
+
+import (
+	"errors"
+
+	"hearts/logic/card"
+)
+
+// ErrBadRelease is returned in a ViolationEvent for a Release written by a player who holds no share of the seed it
+// releases, a second Release of a share with a different value, or a release which leaves a pass of the hand unopened
+var ErrBadRelease = errors.New("Invalid release")
+
+// Returns true if the hand of the player at playerIndex has been released this round
+func (r *Replayer) released(playerIndex int) bool {
+	return r.round != nil && r.round.released[playerIndex]
+}
+
+// Returns the player who holds the i'th share of the seed of the player at playerIndex
+func shareHolder(playerIndex, i int) int {
+	if i >= playerIndex {
+		return i + 1
+	}
+	return i
+}
+
+// Returns the index among the shares of the seed of the player at playerIndex of the share held by holder
+func shareIndex(playerIndex, holder int) int {
+	if holder > playerIndex {
+		return holder - 1
+	}
+	return holder
+}
+
+// Keeps the shares escrowed by the Shuffle c which are sealed to this device
+func (r *Replayer) openEscrow(d *shuffleDeal, c *GameCommand) {
+	for i, box := range c.Escrow {
+		share, ok := r.key.openBox(box)
+		if !ok || commitment(share) != c.Shares[i] {
+			continue
+		}
+		if d.escrowed[c.Player] == nil {
+			d.escrowed[c.Player] = make(map[int][]byte)
+		}
+		d.escrowed[c.Player][shareHolder(c.Player, i)] = share
+	}
+}
+
+// Returns the Release the player at playerIndex writes for the player at stalled, who has stopped moving,
+// or nil if this device holds no share of their seed for playerIndex, or has nothing left to release
+func (r *Replayer) ReleaseHand(stalled, playerIndex int) *GameCommand {
+	if !r.sealedHand(stalled) || r.round.revealed[stalled] != nil || r.round.releases[stalled][playerIndex] != nil {
+		return nil
+	}
+	share, ok := r.round.deal.escrowed[stalled][playerIndex]
+	if !ok {
+		return nil
+	}
+	c := &GameCommand{Type: Release, Player: stalled, Shares: []string{encoding.EncodeToString(share)}}
+	for _, p := range []int{stalled, r.table.GetPassSender(stalled)} {
+		if opening, ok := r.round.openedPasses[p]; ok {
+			c.Openings = append(c.Openings, opening)
+		}
+	}
+	return c
+}
+
+// Checks a share of the seed of a player who stalled, and the pass openings released with it
+// Once every other player has released their share, the stalled hand is held in the clear
+func (r *Replayer) onRelease(writer int, c *GameCommand) ([]Event, error) {
+	violation := func(err error) ([]Event, error) {
+		return r.violation(ViolationEvent{Writer: writer, Player: c.Player, Command: c.Type, Err: err}), nil
+	}
+	numPlayers := len(r.table.GetPlayers())
+	if writer < 0 || writer >= numPlayers || writer == c.Player {
+		return violation(ErrWrongWriter)
+	}
+	if !r.sealedHand(c.Player) || !r.round.deal.dealt {
+		return violation(ErrBadRelease)
+	}
+	round := r.round
+	share, err := encoding.DecodeString(c.Shares[0])
+	if err != nil {
+		return violation(ErrBadSeal)
+	}
+	if previous, ok := round.releases[c.Player][writer]; ok {
+		if string(previous) == string(share) {
+			// the same share written twice, for instance by a device which restarted
+			return nil, nil
+		}
+		return violation(ErrBadRelease)
+	}
+	if round.revealed[c.Player] != nil {
+		// the player revealed their cards before the last share came in, so there is nothing left to release
+		return nil, nil
+	}
+	if commitment(share) != round.deal.shares[c.Player][shareIndex(c.Player, writer)] {
+		return violation(ErrBadSeal)
+	}
+	opened := make(map[int]string)
+	for _, opening := range c.Openings {
+		p := r.passOpened(opening, c.Player)
+		if p < 0 {
+			return violation(ErrBadSeal)
+		}
+		opened[p] = opening
+	}
+	for p, opening := range opened {
+		round.openedPasses[p] = opening
+	}
+	if round.releases[c.Player] == nil {
+		round.releases[c.Player] = make(map[int][]byte)
+	}
+	round.releases[c.Player][writer] = share
+	if len(round.releases[c.Player]) < numPlayers-1 {
+		return nil, nil
+	}
+	return r.releaseHand(c.Player), nil
+}
+
+// Returns the player whose pass opening opens, out of the pass of the player at playerIndex and the pass to them,
+// or -1 if it opens neither
+func (r *Replayer) passOpened(opening string, playerIndex int) int {
+	for _, p := range []int{playerIndex, r.table.GetPassSender(playerIndex)} {
+		if pass := r.round.passes[p]; p >= 0 && pass != nil {
+			if _, err := pass.Reveal(opening); err == nil {
+				return p
+			}
+		}
+	}
+	return -1
+}
+
+// Puts the seed of the player at playerIndex back together from its released shares, and sets their hand to the
+// cards it unlocks, less those they passed and played, and with those passed to them
+func (r *Replayer) releaseHand(playerIndex int) []Event {
+	round := r.round
+	d := round.deal
+	numPlayers := len(r.table.GetPlayers())
+	shares := make([][]byte, numPlayers-1)
+	for holder, share := range round.releases[playerIndex] {
+		shares[shareIndex(playerIndex, holder)] = share
+	}
+	seed := joinShares(shares)
+	if commitment(seed) != d.commitments[playerIndex] {
+		// every share matched its commitment, so the player escrowed shares of another seed
+		return r.violation(ViolationEvent{Writer: playerIndex, Player: playerIndex, Command: Shuffle, Err: ErrBadShuffle})
+	}
+	openings := []string{encoding.EncodeToString(seed)}
+	var passed, received []*card.Card
+	if pass := round.passes[playerIndex]; pass != nil {
+		opening, ok := round.openedPasses[playerIndex]
+		if !ok {
+			return r.violation(ViolationEvent{Writer: -1, Player: playerIndex, Command: Release, Err: ErrBadRelease})
+		}
+		openings = append(openings, opening)
+		passed, _ = pass.Reveal(opening)
+	}
+	sender := r.table.GetPassSender(playerIndex)
+	if pass := round.passes[sender]; sender >= 0 && pass != nil && r.table.GetPlayers()[playerIndex].GetDoneTaking() {
+		opening, ok := round.openedPasses[sender]
+		if !ok {
+			return r.violation(ViolationEvent{Writer: -1, Player: playerIndex, Command: Release, Err: ErrBadRelease})
+		}
+		received, _ = pass.Reveal(opening)
+	}
+	dealt, err := d.hand(seed, playerIndex, numPlayers)
+	if err != nil {
+		return r.violation(ViolationEvent{Writer: -1, Player: playerIndex, Command: Release, Err: err})
+	}
+	held := withoutCards(append(withoutCards(dealt, passed), r.tableCardsOf(received)...), round.played(playerIndex))
+	r.table.GetPlayers()[playerIndex].SetHand(held)
+	round.released[playerIndex] = true
+	round.revealed[playerIndex] = openings
+	round.hands[playerIndex] = dealt
+	events := []Event{ReleaseEvent{Player: playerIndex, Cards: held}}
+	if len(round.revealed) == numPlayers && r.table.RoundOver() && r.table.TrickNew() {
+		events = append(events, r.audit()...)
+	}
+	return events
+}
+
+// Returns the cards the player at playerIndex has played this round
+func (round *sealedRound) played(playerIndex int) []*card.Card {
+	played := make([]*card.Card, 0)
+	for _, e := range round.entries {
+		if (e.command.Type == Play || e.command.Type == Timeout) && e.command.Player == playerIndex {
+			played = append(played, e.command.Cards...)
+		}
+	}
+	return played
+}
+
+// Returns the cards of the table of r matching cards, leaving out any it doesn't have
+func (r *Replayer) tableCardsOf(cards []*card.Card) []*card.Card {
+	tableCards := make([]*card.Card, 0, len(cards))
+	for _, c := range cards {
+		if tc := r.table.GetCard(c.GetFace(), c.GetSuit()); tc != nil {
+			tableCards = append(tableCards, tc)
+		}
+	}
+	return tableCards
+}
+
+// Returns the cards of hand which aren't among cards, comparing them by suit and face
+func withoutCards(hand, cards []*card.Card) []*card.Card {
+	left := make([]*card.Card, 0, len(hand))
+	for _, h := range hand {
+		found := false
+		for _, c := range cards {
+			if c.GetSuit() == h.GetSuit() && c.GetFace() == h.GetFace() {
+				found = true
+			}
+		}
+		if !found {
+			left = append(left, h)
+		}
+	}
+	return left
+}
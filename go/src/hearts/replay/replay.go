@@ -0,0 +1,461 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// replay applies entries of the syncbase game log to a table, without any UI.
+// A Replayer takes the (key, value) entries of a game in order, updates its table
+// the same way for every device, and returns the events each entry caused.
+// A description of the log syntax can be found here: https://docs.google.com/document/d/1uZc9EQ2-F6CjJjGkj7VWvJNFklKGsFGQSVHtpEiJUlQ
+
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+const (
+	Deal      string = "Deal"
+	Pass      string = "Pass"
+	Take      string = "Take"
+	Play      string = "Play"
+	Ready     string = "Ready"
+	TakeTrick string = "TakeTrick"
+	Reveal    string = "Reveal"
+	Timeout   string = "Timeout"
+	Shuffle   string = "Shuffle"
+	Lock      string = "Lock"
+	Unlock    string = "Unlock"
+	Release   string = "Release"
+	Bar       string = "|"
+	Space     string = " "
+	Colon     string = ":"
+	Dash      string = "-"
+	End       string = "END"
+	Seed      string = "seed"
+	CardType  string = "classic"
+	Commit    string = "commit"
+	Box       string = "box"
+	Opening   string = "opening"
+	Proposed  string = "proposal"
+	Point     string = "point"
+	LockKey   string = "key"
+	Share     string = "share"
+	Escrow    string = "escrow"
+	Signature string = "sig"
+)
+
+// ErrMalformedEntry is returned by Apply for an entry naming a player or card not at the table, or a key it can't read
+// Log values which don't follow the log syntax are rejected with ErrMalformedCommand
+var ErrMalformedEntry = errors.New("Malformed log entry")
+
+// ErrWrongWriter is reported for a player's move written under the key of another player
+var ErrWrongWriter = errors.New("Move written by another player")
+
+// ErrBlessingsChanged is reported for a user's blessings written again with a different value
+var ErrBlessingsChanged = errors.New("Blessings changed after they were recorded")
+
+// ErrHandKeyChanged is reported for a user's hand key written again with a different value
+var ErrHandKeyChanged = errors.New("Hand key changed after it was recorded")
+
+type Replayer struct {
+	table            *table.Table
+	sequentialPhases bool
+	// gameOver is true from the trick which won the game until the first deal of the next game,
+	// so that the final scores stay on the table while they are displayed
+	gameOver   bool
+	violations []ViolationEvent
+	// proposals holds the current proposal of each seat, and settled the IDs of every proposal committed so far,
+	// so that proposals which have already been committed can be told apart from new ones
+	proposals map[int]*Proposal
+	settled   map[string]bool
+	// blessings holds the first blessings each user recorded, which are the only ones accepted
+	blessings map[int]string
+	// handKeys and signKeys hold the hand key and sign key each user published, and seatUsers the user in each seat
+	handKeys  map[int]string
+	signKeys  map[int]string
+	seatUsers map[int]int
+	status    Status
+	turnLimit time.Duration
+	// key opens the cards sealed to this device, and round records the current round if its hands are sealed
+	// deals counts the deals started in the log, and rematch is set once the players of a game which is over
+	// start another
+	key     *HandKey
+	round   *sealedRound
+	deals   int
+	rematch bool
+}
+
+// Returns a replayer which applies log entries to t
+// sequentialPhases matches Croupier Flutter: the first lead is only known once every player has taken their passed cards
+func New(t *table.Table, sequentialPhases bool) *Replayer {
+	return &Replayer{
+		table:            t,
+		sequentialPhases: sequentialPhases,
+		proposals:        make(map[int]*Proposal),
+		settled:          make(map[string]bool),
+		blessings:        make(map[int]string),
+		handKeys:         make(map[int]string),
+		signKeys:         make(map[int]string),
+		seatUsers:        make(map[int]int),
+	}
+}
+
+// Returns the table entries are applied to
+func (r *Replayer) Table() *table.Table {
+	return r.table
+}
+
+// Returns every command which broke the rules, in the order they were found
+func (r *Replayer) Violations() []ViolationEvent {
+	return r.violations
+}
+
+// Returns true if commands of type commandType may only be written by the player they are about
+// Deals and taking a trick move the whole table along, and may be written by any player,
+// and a Release is written by the other players for the player who stalled
+func ownMove(commandType string) bool {
+	switch commandType {
+	case Pass, Take, Play, Ready, Timeout, Reveal, Shuffle, Lock, Unlock:
+		return true
+	}
+	return false
+}
+
+// Returns the hand key published by the user in the seat playerIndex, or "" if they haven't published one
+func (r *Replayer) seatKey(playerIndex int) string {
+	userID, ok := r.seatUsers[playerIndex]
+	if !ok {
+		return ""
+	}
+	return r.handKeys[userID]
+}
+
+// Records v, and returns it as the only event of the entry which broke the rules
+func (r *Replayer) violation(v ViolationEvent) []Event {
+	r.violations = append(r.violations, v)
+	return []Event{v}
+}
+
+// Applies one log entry to the table of r, and returns the events it caused
+// Entries of unknown types are ignored. The table is left unchanged if an error is returned,
+// or if the entry is a command which breaks the rules; that entry is recorded and returned as a ViolationEvent
+func (r *Replayer) Apply(key, value string) ([]Event, error) {
+	keyParts := strings.Split(key, "/")
+	if len(keyParts) < 2 {
+		return nil, ErrMalformedEntry
+	}
+	switch keyParts[1] {
+	case "log":
+		if playerNumber, ok := ParseProposalKey(key); ok {
+			return r.onProposal(playerNumber, value)
+		}
+		_, writer, ok := ParseLogKey(key)
+		if !ok {
+			return r.violation(ViolationEvent{Writer: -1, Player: -1, Command: "log", Err: ErrMalformedEntry}), nil
+		}
+		return r.applyCommand(key, writer, value)
+	case "players":
+		if len(keyParts) < 4 {
+			return nil, ErrMalformedEntry
+		}
+		userID, err := strconv.Atoi(keyParts[2])
+		if err != nil {
+			return nil, ErrMalformedEntry
+		}
+		switch keyParts[3] {
+		case "player_number":
+			return r.onPlayerNum(userID, value)
+		case "settings_sg":
+			return []Event{SettingsEvent{UserID: userID, Name: value}}, nil
+		case "hand_key":
+			return r.onHandKey(userID, value)
+		case "sign_key":
+			return r.onSignKey(userID, value)
+		case "blessings":
+			return r.onBlessings(userID, value)
+		}
+	case "status":
+		return r.onStatus(value)
+	case "result":
+		return r.onResult(value)
+	case "turn_limit":
+		return r.onTurnLimit(value)
+	}
+	return nil, nil
+}
+
+// Commands of unknown types are ignored, so that entries written by newer versions don't stop the game
+// Once the user in the writer's seat has published a sign key, the command must carry their signature
+func (r *Replayer) applyCommand(key string, writer int, value string) ([]Event, error) {
+	c, err := Decode(value)
+	if errors.Is(err, ErrUnknownCommand) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if public := r.seatSignKey(writer); public != "" && !verifySignature(public, key, value, c) {
+		return r.violation(ViolationEvent{Writer: writer, Player: c.Player, Command: c.Type, Err: ErrBadSignature}), nil
+	}
+	if c.Proposal != "" {
+		r.settle(c.Proposal)
+	}
+	return r.apply(writer, c)
+}
+
+func (r *Replayer) apply(writer int, c *GameCommand) ([]Event, error) {
+	if c.Type != TakeTrick && c.Player >= len(r.table.GetPlayers()) {
+		return nil, ErrMalformedEntry
+	}
+	// the card of a Timeout for a player whose hand was released is checked in full, so any player may write it
+	if ownMove(c.Type) && writer != c.Player && !(c.Type == Timeout && r.released(c.Player)) {
+		return r.violation(ViolationEvent{Writer: writer, Player: c.Player, Command: c.Type, Err: ErrWrongWriter}), nil
+	}
+	switch c.Type {
+	case Reveal:
+		return r.onReveal(writer, c)
+	case Release:
+		return r.onRelease(writer, c)
+	case Shuffle, Lock, Unlock:
+		return r.onDealStep(writer, c)
+	}
+	var cards []*card.Card
+	var opening string
+	var err error
+	if c.Sealed != nil {
+		cards, opening, err = r.unseal(c)
+	} else {
+		cards, err = r.tableCards(c.Cards)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// no device can tell who holds the first lead of a sealed round until it is played
+	events := make([]Event, 0)
+	firstLead := (c.Type == Play || c.Type == Timeout) && r.round != nil && r.table.GetFirstPlayer() < 0 && r.table.TrickNew() && cards[0] == r.table.GetFirstLead()
+	if firstLead {
+		r.table.SetFirstPlayer(c.Player)
+	}
+	if err := r.validate(c, cards); err != nil {
+		if firstLead {
+			r.table.SetFirstPlayer(-1)
+		}
+		return r.violation(ViolationEvent{Writer: writer, Player: c.Player, Command: c.Type, Err: err}), nil
+	}
+	if firstLead {
+		events = append(events, FirstPlayerEvent{Player: c.Player})
+	}
+	var applied []Event
+	switch c.Type {
+	case Deal:
+		applied, err = r.onDeal(c, cards)
+	case Pass:
+		applied, err = r.onPass(c.Player, cards)
+	case Take:
+		applied, err = r.onTake(c.Player)
+	case Play:
+		applied, err = r.onPlay(c.Player, cards[0])
+	case Timeout:
+		applied, err = r.onTimeout(c.Player, cards[0])
+	case TakeTrick:
+		applied, err = r.onTakeTrick()
+	case Ready:
+		applied, err = r.onReady(c.Player)
+	}
+	r.record(writer, c, opening)
+	return append(events, applied...), err
+}
+
+// Returns nil if the command c, carrying the table cards cards, may be applied to the table right now
+// The moves of a player with a sealed hand are only checked as far as every device can check them,
+// unless their hand has been released
+func (r *Replayer) validate(c *GameCommand, cards []*card.Card) error {
+	hidden := (r.sealedHand(c.Player) && !r.released(c.Player)) || c.Sealed != nil
+	switch c.Type {
+	case Deal:
+		if r.round != nil && !r.round.deal.dealt {
+			return ErrDealOrder
+		}
+		return r.table.ValidDeal(cards, c.Player)
+	case Pass:
+		if hidden {
+			return r.table.ValidHiddenPass(c.Player)
+		}
+		return r.table.ValidPassFrom(cards, c.Player)
+	case Take:
+		if r.sequentialPhases && !r.table.AllDonePassing() {
+			return table.ErrPassingNotDone
+		}
+		return r.table.ValidTake(c.Player)
+	case Play:
+		if hidden {
+			return r.table.ValidHiddenPlay(cards[0], c.Player)
+		}
+		return r.table.ValidPlay(cards[0], c.Player)
+	case Timeout:
+		return r.validTimeout(cards[0], c.Player, hidden)
+	case Ready:
+		if r.unrevealed(c.Player) {
+			return ErrNotRevealed
+		}
+		return r.table.ValidReady(c.Player)
+	}
+	return nil
+}
+
+func (r *Replayer) onPlayerNum(userID int, value string) ([]Event, error) {
+	playerNum, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, ErrMalformedEntry
+	}
+	if playerNum >= 0 && playerNum < len(r.table.GetPlayers()) {
+		r.table.GetPlayers()[playerNum].SetDoneScoring(true)
+		r.seatUsers[playerNum] = userID
+	}
+	return []Event{PlayerNumEvent{UserID: userID, PlayerNum: playerNum}}, nil
+}
+
+// Only the first blessings a user records are accepted, so that no one can write another user's in their place
+func (r *Replayer) onBlessings(userID int, value string) ([]Event, error) {
+	var blessings []string
+	if err := json.Unmarshal([]byte(value), &blessings); err != nil {
+		return nil, ErrMalformedEntry
+	}
+	if first, ok := r.blessings[userID]; ok {
+		if first != value {
+			return r.violation(ViolationEvent{Writer: -1, Player: -1, Command: "blessings", Err: ErrBlessingsChanged}), nil
+		}
+		return nil, nil
+	}
+	r.blessings[userID] = value
+	return []Event{BlessingsEvent{UserID: userID, Blessings: blessings}}, nil
+}
+
+// Only the first hand key a user publishes is accepted, so that no one can have cards sealed to them in another user's place
+func (r *Replayer) onHandKey(userID int, value string) ([]Event, error) {
+	if first, ok := r.handKeys[userID]; ok {
+		if first != value {
+			return r.violation(ViolationEvent{Writer: -1, Player: -1, Command: "hand_key", Err: ErrHandKeyChanged}), nil
+		}
+		return nil, nil
+	}
+	r.handKeys[userID] = value
+	return []Event{HandKeyEvent{UserID: userID, Key: value}}, nil
+}
+
+func (r *Replayer) onDeal(c *GameCommand, curCards []*card.Card) ([]Event, error) {
+	playerInt := c.Player
+	if r.gameOver {
+		r.table.NewGame()
+		r.gameOver = false
+	}
+	// the first deal of a round ends the record of the last one
+	if r.table.RoundOver() {
+		r.round = nil
+	}
+	r.table.GetPlayers()[playerInt].SetHand(curCards)
+	events := []Event{DealEvent{Player: playerInt, Cards: curCards, Seed: c.Seed, HasSeed: c.HasSeed}}
+	if r.table.AllDoneDealing() {
+		r.table.NewRound()
+		events = append(events, NewRoundEvent{})
+	}
+	return events, nil
+}
+
+func (r *Replayer) onPass(playerInt int, curCards []*card.Card) ([]Event, error) {
+	receivingPlayer := r.table.GetPassRecipient(playerInt)
+	players := r.table.GetPlayers()
+	for _, c := range curCards {
+		removeFromHand(r.table, c, playerInt)
+	}
+	players[playerInt].SetPassedFrom(curCards)
+	players[receivingPlayer].SetPassedTo(curCards)
+	players[playerInt].SetDonePassing(true)
+	return []Event{PassEvent{Player: playerInt, Recipient: receivingPlayer, Cards: curCards}}, nil
+}
+
+func (r *Replayer) onTake(playerInt int) ([]Event, error) {
+	p := r.table.GetPlayers()[playerInt]
+	passed := p.GetPassedTo()
+	for _, c := range passed {
+		p.AddToHand(c)
+	}
+	p.SetDoneTaking(true)
+	events := []Event{TakeEvent{Player: playerInt, Cards: passed}}
+	if r.sequentialPhases {
+		if r.table.AllDoneTaking() {
+			for _, player := range r.table.GetPlayers() {
+				if player.HasCard(r.table.GetFirstLead()) {
+					r.setFirstPlayer(player.GetPlayerIndex())
+					events = append(events, FirstPlayerEvent{Player: player.GetPlayerIndex()})
+				}
+			}
+		}
+	} else if p.HasCard(r.table.GetFirstLead()) {
+		r.setFirstPlayer(playerInt)
+		events = append(events, FirstPlayerEvent{Player: playerInt})
+	}
+	return events, nil
+}
+
+// Sets the player who holds the first lead as the first player, unless the round is sealed
+// Only the device holding the lead can see it in a sealed round, which tells it whose turn it is without setting it
+func (r *Replayer) setFirstPlayer(playerIndex int) {
+	if r.round == nil {
+		r.table.SetFirstPlayer(playerIndex)
+	}
+}
+
+func (r *Replayer) onPlay(playerInt int, playedCard *card.Card) ([]Event, error) {
+	p := r.table.GetPlayers()[playerInt]
+	removeFromHand(r.table, playedCard, playerInt)
+	r.table.SetPlayedCard(playedCard, playerInt)
+	p.SetDonePlaying(true)
+	e := PlayEvent{Player: playerInt, Card: playedCard, TrickOver: r.table.TrickOver(), Recipient: -1}
+	if e.TrickOver {
+		e.Recipient = r.table.GetTrickRecipient()
+	}
+	return []Event{e}, nil
+}
+
+// A trick can be taken by more than one player at once; only the first take counts
+func (r *Replayer) onTakeTrick() ([]Event, error) {
+	if !r.table.TrickOver() {
+		return nil, nil
+	}
+	e := TakeTrickEvent{
+		Recipient: r.table.GetTrickRecipient(),
+		Cards:     r.table.GetTrick(),
+	}
+	e.RoundOver = r.table.SendTrick(e.Recipient)
+	if e.RoundOver {
+		e.MoonShooter = r.table.Shooter()
+		e.QueenTaker = r.table.TrickTaker(r.table.GetCard(card.Queen, card.Spade))
+		e.RoundScores, e.Winners = r.table.EndRound()
+		r.gameOver = len(e.Winners) > 0
+	}
+	return []Event{e}, nil
+}
+
+func (r *Replayer) onReady(playerInt int) ([]Event, error) {
+	r.table.GetPlayers()[playerInt].SetDoneScoring(true)
+	return []Event{ReadyEvent{Player: playerInt, AllReady: r.table.AllReadyForNewRound()}}, nil
+}
+
+// Returns the cards of the table of r matching cards
+func (r *Replayer) tableCards(cards []*card.Card) ([]*card.Card, error) {
+	tableCards := make([]*card.Card, len(cards))
+	for i, c := range cards {
+		tableCards[i] = r.table.GetCard(c.GetFace(), c.GetSuit())
+		if tableCards[i] == nil {
+			return nil, ErrMalformedEntry
+		}
+	}
+	return tableCards, nil
+}
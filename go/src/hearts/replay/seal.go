@@ -0,0 +1,193 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// seal.go hides the cards of a pass from everyone but the players meant to see them.
+// Every player publishes the public half of a HandKey. Cards are sealed by encrypting their opening, a random salt
+// followed by the cards, to the key of each player who may see them, and by committing to the opening with its hash.
+// Once the round is over the players reveal their openings, which everyone checks against the commitments.
+// The private half of a HandKey also draws the seeds its holder deals with, as shuffle.go describes.
+
+package replay
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"hearts/logic/card"
+)
+
+// ErrBadSeal is returned for an opening which doesn't match the commitment of the cards it claims to reveal
+var ErrBadSeal = errors.New("Opening doesn't match sealed cards")
+
+// keys, boxes and openings are written to the log in this encoding, which uses none of the log's separators
+var encoding = base64.RawURLEncoding
+
+// the number of random bytes each opening starts with, so that equal cards don't make equal commitments
+const saltSize = 16
+
+// HandKey is the key pair a device opens the cards sealed to it with
+type HandKey struct {
+	private *ecdh.PrivateKey
+}
+
+// Sealed is a set of cards which only the holders of the keys they were sealed to can see
+type Sealed struct {
+	Commitment string   // the hash of the opening
+	Boxes      []string // the opening, encrypted to each key
+}
+
+// Returns a new random key
+func NewHandKey() (*HandKey, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &HandKey{private: private}, nil
+}
+
+// Returns the key written by String
+func ParseHandKey(s string) (*HandKey, error) {
+	b, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	private, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return nil, err
+	}
+	return &HandKey{private: private}, nil
+}
+
+// Returns k, private half included, to be kept on this device
+func (k *HandKey) String() string {
+	return encoding.EncodeToString(k.private.Bytes())
+}
+
+// Returns the public half of k, which other players seal cards to
+func (k *HandKey) Public() string {
+	return encoding.EncodeToString(k.private.PublicKey().Bytes())
+}
+
+// Returns cards sealed to each of the public keys, and the opening which reveals them
+func Seal(cards []*card.Card, keys []string) (*Sealed, string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, "", err
+	}
+	fields := []string{hex.EncodeToString(salt)}
+	for _, c := range cards {
+		fields = append(fields, c.GetSuit().String()+c.GetFace().String())
+	}
+	plain := []byte(strings.Join(fields, Space))
+	s := &Sealed{Commitment: commitment(plain)}
+	for _, key := range keys {
+		box, err := sealBox(plain, key)
+		if err != nil {
+			return nil, "", err
+		}
+		s.Boxes = append(s.Boxes, box)
+	}
+	return s, encoding.EncodeToString(plain), nil
+}
+
+// Returns the opening of s, if one of its boxes was sealed to k
+func (k *HandKey) Open(s *Sealed) (string, bool) {
+	for _, box := range s.Boxes {
+		if plain, ok := k.openBox(box); ok && commitment(plain) == s.Commitment {
+			return encoding.EncodeToString(plain), true
+		}
+	}
+	return "", false
+}
+
+// Returns the cards opening reveals, or ErrBadSeal if it isn't the opening of s
+// The cards aren't the cards of any table
+func (s *Sealed) Reveal(opening string) ([]*card.Card, error) {
+	plain, err := encoding.DecodeString(opening)
+	if err != nil || commitment(plain) != s.Commitment {
+		return nil, ErrBadSeal
+	}
+	fields := strings.Split(string(plain), Space)
+	cards := make([]*card.Card, 0, len(fields)-1)
+	for _, f := range fields[1:] {
+		c, err := parseCard(f)
+		if err != nil {
+			return nil, ErrBadSeal
+		}
+		cards = append(cards, c)
+	}
+	return cards, nil
+}
+
+func commitment(plain []byte) string {
+	sum := sha256.Sum256(plain)
+	return hex.EncodeToString(sum[:])
+}
+
+// Encrypts plain to the public key key, with a new key pair whose public half starts the box
+func sealBox(plain []byte, key string) (string, error) {
+	b, err := encoding.DecodeString(key)
+	if err != nil {
+		return "", err
+	}
+	public, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return "", err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	aead, err := boxCipher(ephemeral, public)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	box := append(ephemeral.PublicKey().Bytes(), nonce...)
+	return encoding.EncodeToString(aead.Seal(box, nonce, plain, nil)), nil
+}
+
+// Returns the contents of box, and false if it wasn't sealed to k
+func (k *HandKey) openBox(box string) ([]byte, bool) {
+	b, err := encoding.DecodeString(box)
+	keySize := len(k.private.PublicKey().Bytes())
+	if err != nil || len(b) < keySize {
+		return nil, false
+	}
+	public, err := ecdh.X25519().NewPublicKey(b[:keySize])
+	if err != nil {
+		return nil, false
+	}
+	aead, err := boxCipher(k.private, public)
+	if err != nil || len(b) < keySize+aead.NonceSize() {
+		return nil, false
+	}
+	nonce := b[keySize : keySize+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, b[keySize+aead.NonceSize():], nil)
+	return plain, err == nil
+}
+
+// Returns the cipher boxes between private and public are sealed with
+func boxCipher(private *ecdh.PrivateKey, public *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(shared)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
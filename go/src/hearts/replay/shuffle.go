@@ -0,0 +1,590 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// shuffle.go deals sealed hands with a shuffle no single device controls, so that no one sees a hand but its holder.
+// Each card of the deck stands for a point of the P-256 curve, written as its x-coordinate. Multiplying a point by
+// a secret number locks it, and multiplying by the inverse unlocks it again. Locks commute, so they may be taken off
+// in any order. A deal takes three steps, each written by every player: the first two in seat order, the last in any:
+//   Shuffle: the player locks every card of the deck with the same number, and shuffles the deck
+//   Lock: the player takes their shuffle lock off, and locks each position of the deck with a number of its own
+//   Unlock: the player publishes the keys to their locks on every position but those dealt to them
+// Once every player has unlocked, each position is locked by the player it's dealt to alone, who is the only one
+// who can see it. Position i is dealt to player i % numPlayers, as Table.DealSeed deals.
+// Every number a player uses is drawn from a seed, which their Shuffle commits to and which they reveal once the
+// round is over, so that every device can check each step and reproduce every hand.
+// The Shuffle also escrows the seed with the other players, split into shares which only all of them together can
+// put back together, so that the hand of a player who stalls can be released, as release.go describes.
+
+package replay
+
+import (
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"hearts/logic/card"
+	"hearts/logic/table"
+)
+
+var (
+	// ErrDealOrder is returned in a ViolationEvent for a step of the deal written out of turn,
+	// or to start a deal before the round is over and every player is ready for the next
+	ErrDealOrder = errors.New("Deal step out of order")
+	// ErrBadShuffle is returned in a ViolationEvent for a step of the deal carrying points or keys which can't be read,
+	// or, wrapped in ErrAudit, for one which doesn't match the seed the player revealed
+	ErrBadShuffle = errors.New("Invalid deal step")
+	// ErrBadDeal is returned in a ViolationEvent for a hand which unlocked to cards outside the deck,
+	// found by the device it was dealt to
+	ErrBadDeal = errors.New("Hand unlocked to unknown cards")
+	// ErrNoHandKey is returned by DealStep when a share of a seed can't be escrowed with a player who has no hand key
+	ErrNoHandKey = errors.New("Player has no hand key")
+)
+
+var curve = elliptic.P256().Params()
+
+// the size of a point's x-coordinate, and of a key, in bytes
+const pointSize = 32
+
+// shuffleDeal records the steps written so far to deal the current round
+type shuffleDeal struct {
+	deck        []*card.Card     // the cards of the table, in the order of its deck
+	points      []string         // the point standing for each card of deck
+	commitments []string         // the commitment of each player's Shuffle to their seed
+	shares      [][]string       // the commitments of each player's Shuffle to the shares of their seed
+	shuffled    [][]string       // the deck left by each player's Shuffle
+	locked      [][]string       // the deck left by each player's Lock
+	unlocks     map[int][]string // the keys of each player's Unlock, once written
+	seeds       map[int][]byte   // the seeds of the players this device deals for
+	// the shares this device opened, by the player whose seed they split and then the player they were escrowed with
+	escrowed map[int]map[int][]byte
+	dealt    bool // true once every hand is dealt
+}
+
+func newShuffleDeal(deck []*card.Card) (*shuffleDeal, error) {
+	d := &shuffleDeal{
+		deck:     deck,
+		unlocks:  make(map[int][]string),
+		seeds:    make(map[int][]byte),
+		escrowed: make(map[int]map[int][]byte),
+	}
+	for _, c := range deck {
+		p, err := cardPoint(c)
+		if err != nil {
+			return nil, err
+		}
+		d.points = append(d.points, p)
+	}
+	return d, nil
+}
+
+// Returns the deck the player at playerIndex shuffles, locks or unlocks, for step
+func (d *shuffleDeal) input(step string, playerIndex int) []string {
+	switch {
+	case step == Shuffle && playerIndex == 0:
+		return d.points
+	case step == Shuffle:
+		return d.shuffled[playerIndex-1]
+	case playerIndex == 0:
+		return d.shuffled[len(d.shuffled)-1]
+	}
+	return d.locked[playerIndex-1]
+}
+
+// Returns the cards of the hand dealt to the player at playerIndex, unlocked with the seed they dealt with
+// Every player must have unlocked
+func (d *shuffleDeal) hand(seed []byte, playerIndex, numPlayers int) ([]*card.Card, error) {
+	keys := newDealKeys(seed, len(d.deck))
+	cards := make(map[string]*card.Card)
+	for i, p := range d.points {
+		cards[p] = d.deck[i]
+	}
+	final := d.locked[numPlayers-1]
+	hand := make([]*card.Card, 0, len(d.deck)/numPlayers)
+	for i := playerIndex; i < len(final); i += numPlayers {
+		k := new(big.Int).ModInverse(keys.locks[i], curve.N)
+		for s := 0; s < numPlayers; s++ {
+			if s == playerIndex {
+				continue
+			}
+			unlock, err := decodeScalar(d.unlocks[s][unlockIndex(i, s, numPlayers)])
+			if err != nil {
+				return nil, err
+			}
+			k.Mul(k, unlock).Mod(k, curve.N)
+		}
+		p, err := multiply(k, final[i])
+		if err != nil {
+			return nil, err
+		}
+		c, ok := cards[p]
+		if !ok {
+			return nil, ErrBadDeal
+		}
+		for _, h := range hand {
+			if h == c {
+				return nil, ErrBadDeal
+			}
+		}
+		hand = append(hand, c)
+	}
+	return hand, nil
+}
+
+// Returns the index among the keys of the player at playerIndex of the key to position i, which isn't theirs
+func unlockIndex(i, playerIndex, numPlayers int) int {
+	index := i / numPlayers * (numPlayers - 1)
+	if i%numPlayers < playerIndex {
+		return index + i%numPlayers
+	}
+	return index + i%numPlayers - 1
+}
+
+// Returns the step the player at playerIndex makes with seed, on the deck d holds so far
+func (d *shuffleDeal) step(step string, seed []byte, playerIndex, numPlayers int) (*GameCommand, error) {
+	keys := newDealKeys(seed, len(d.deck))
+	c := &GameCommand{Type: step, Player: playerIndex}
+	var err error
+	switch step {
+	case Shuffle:
+		c.Commitment = commitment(seed)
+		for _, share := range escrowShares(seed, playerIndex, numPlayers) {
+			c.Shares = append(c.Shares, commitment(share))
+		}
+		c.Points, err = keys.shuffle(d.input(step, playerIndex))
+	case Lock:
+		c.Points, err = keys.lock(d.input(step, playerIndex))
+	case Unlock:
+		c.Keys = keys.unlock(playerIndex, numPlayers)
+	}
+	return c, err
+}
+
+// Returns the shares the seed of the player at playerIndex is split into, one for each other player in seat order
+// Every share but the last is drawn from the seed, and the last is the seed xored with all of them
+func escrowShares(seed []byte, playerIndex, numPlayers int) [][]byte {
+	shares := make([][]byte, numPlayers-1)
+	last := append([]byte{}, seed...)
+	for i := range shares[:len(shares)-1] {
+		mac := hmac.New(sha256.New, seed)
+		fmt.Fprintf(mac, "share %d %d", playerIndex, i)
+		shares[i] = mac.Sum(nil)
+		for j := range last {
+			last[j] ^= shares[i][j]
+		}
+	}
+	shares[len(shares)-1] = last
+	return shares
+}
+
+// Returns the seed split into shares
+func joinShares(shares [][]byte) []byte {
+	seed := make([]byte, len(shares[0]))
+	for _, share := range shares {
+		for j := range seed {
+			seed[j] ^= share[j]
+		}
+	}
+	return seed
+}
+
+// dealKeys are the numbers one player deals a round with, drawn from their seed
+type dealKeys struct {
+	shuffleKey *big.Int   // locks every card the player shuffles
+	perm       []int      // the order the player shuffles the deck into
+	locks      []*big.Int // lock each position of the deck
+}
+
+func newDealKeys(seed []byte, size int) *dealKeys {
+	s := &seedStream{seed: seed}
+	k := &dealKeys{shuffleKey: s.scalar(), perm: make([]int, size)}
+	for i := range k.perm {
+		k.perm[i] = i
+	}
+	for i := size - 1; i > 0; i-- {
+		j := int(s.uint64() % uint64(i+1))
+		k.perm[i], k.perm[j] = k.perm[j], k.perm[i]
+	}
+	for i := 0; i < size; i++ {
+		k.locks = append(k.locks, s.scalar())
+	}
+	return k
+}
+
+// Returns deck locked with the shuffle key and shuffled
+func (k *dealKeys) shuffle(deck []string) ([]string, error) {
+	out := make([]string, len(deck))
+	for i, j := range k.perm {
+		p, err := multiply(k.shuffleKey, deck[j])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = p
+	}
+	return out, nil
+}
+
+// Returns deck with the shuffle key taken off, and each position locked with its own key
+func (k *dealKeys) lock(deck []string) ([]string, error) {
+	unshuffle := new(big.Int).ModInverse(k.shuffleKey, curve.N)
+	out := make([]string, len(deck))
+	for i, p := range deck {
+		key := new(big.Int).Mul(unshuffle, k.locks[i])
+		p, err := multiply(key.Mod(key, curve.N), p)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = p
+	}
+	return out, nil
+}
+
+// Returns the keys to the locks on every position not dealt to the player at playerIndex, in deck order
+func (k *dealKeys) unlock(playerIndex, numPlayers int) []string {
+	keys := make([]string, 0, len(k.locks))
+	for i, l := range k.locks {
+		if i%numPlayers != playerIndex {
+			keys = append(keys, encodeScalar(new(big.Int).ModInverse(l, curve.N)))
+		}
+	}
+	return keys
+}
+
+// seedStream draws numbers from a seed, hashing it with a counter
+type seedStream struct {
+	seed    []byte
+	counter uint64
+}
+
+func (s *seedStream) next() []byte {
+	h := sha256.New()
+	h.Write(s.seed)
+	binary.Write(h, binary.BigEndian, s.counter)
+	s.counter++
+	return h.Sum(nil)
+}
+
+func (s *seedStream) uint64() uint64 {
+	return binary.BigEndian.Uint64(s.next())
+}
+
+// Returns a number between 1 and the order of the curve, which locks points
+func (s *seedStream) scalar() *big.Int {
+	k := new(big.Int).SetBytes(append(s.next(), s.next()...))
+	k.Mod(k, new(big.Int).Sub(curve.N, big.NewInt(1)))
+	return k.Add(k, big.NewInt(1))
+}
+
+// Returns the seed the player at playerIndex deals the deal'th round of the log with, drawn from k
+func (k *HandKey) dealSeed(deal, playerIndex int) []byte {
+	mac := hmac.New(sha256.New, k.private.Bytes())
+	fmt.Fprintf(mac, "deal %d %d", deal, playerIndex)
+	return mac.Sum(nil)
+}
+
+// Returns the point standing for c: the first x-coordinate of a point of the curve hashed from its name
+func cardPoint(c *card.Card) (string, error) {
+	for i := 0; i < 256; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("hearts card %s%s %d", c.GetSuit().String(), c.GetFace().String(), i)))
+		x := new(big.Int).SetBytes(sum[:])
+		if _, err := curvePoint(x); err == nil {
+			return encoding.EncodeToString(sum[:]), nil
+		}
+	}
+	return "", ErrBadShuffle
+}
+
+// Returns the x-coordinate of k times the point with x-coordinate x
+func multiply(k *big.Int, x string) (string, error) {
+	b, err := encoding.DecodeString(x)
+	if err != nil || len(b) != pointSize {
+		return "", ErrBadShuffle
+	}
+	public, err := curvePoint(new(big.Int).SetBytes(b))
+	if err != nil {
+		return "", err
+	}
+	private, err := ecdh.P256().NewPrivateKey(k.FillBytes(make([]byte, pointSize)))
+	if err != nil {
+		return "", ErrBadShuffle
+	}
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return "", ErrBadShuffle
+	}
+	return encoding.EncodeToString(shared), nil
+}
+
+// Returns a point of the curve with x-coordinate x, or ErrBadShuffle if there is none
+// Either of the two points with x will do, since multiplying them gives points with the same x-coordinate
+func curvePoint(x *big.Int) (*ecdh.PublicKey, error) {
+	if x.Cmp(curve.P) >= 0 {
+		return nil, ErrBadShuffle
+	}
+	// y² = x³ - 3x + b
+	y := new(big.Int).Exp(x, big.NewInt(3), curve.P)
+	y.Sub(y, new(big.Int).Mul(x, big.NewInt(3)))
+	y.Add(y, curve.B).Mod(y, curve.P)
+	if y.ModSqrt(y, curve.P) == nil {
+		return nil, ErrBadShuffle
+	}
+	b := make([]byte, 1+2*pointSize)
+	b[0] = 4
+	x.FillBytes(b[1 : 1+pointSize])
+	y.FillBytes(b[1+pointSize:])
+	public, err := ecdh.P256().NewPublicKey(b)
+	if err != nil {
+		return nil, ErrBadShuffle
+	}
+	return public, nil
+}
+
+func encodeScalar(k *big.Int) string {
+	return encoding.EncodeToString(k.FillBytes(make([]byte, pointSize)))
+}
+
+// Returns the key written by encodeScalar, or ErrBadShuffle if it isn't a number between 1 and the order of the curve
+func decodeScalar(s string) (*big.Int, error) {
+	b, err := encoding.DecodeString(s)
+	if err != nil || len(b) != pointSize {
+		return nil, ErrBadShuffle
+	}
+	k := new(big.Int).SetBytes(b)
+	if k.Sign() == 0 || k.Cmp(curve.N) >= 0 {
+		return nil, ErrBadShuffle
+	}
+	return k, nil
+}
+
+// Returns true if c is a step of d which has already been written, with the same points or keys
+func (d *shuffleDeal) written(c *GameCommand) bool {
+	switch c.Type {
+	case Shuffle:
+		// the boxes a share is escrowed in are sealed anew each time, so only the commitments to the shares are compared
+		return c.Player < len(d.shuffled) && d.commitments[c.Player] == c.Commitment &&
+			equalFields(d.shares[c.Player], c.Shares) && equalFields(d.shuffled[c.Player], c.Points)
+	case Lock:
+		return c.Player < len(d.locked) && equalFields(d.locked[c.Player], c.Points)
+	}
+	return d.unlocks[c.Player] != nil && equalFields(d.unlocks[c.Player], c.Keys)
+}
+
+// Returns true if c is the next step of d for its player
+func (d *shuffleDeal) next(c *GameCommand, numPlayers int) bool {
+	switch c.Type {
+	case Shuffle:
+		return c.Player == len(d.shuffled)
+	case Lock:
+		return len(d.shuffled) == numPlayers && c.Player == len(d.locked)
+	}
+	return len(d.locked) == numPlayers && d.unlocks[c.Player] == nil
+}
+
+// Returns ErrBadShuffle unless every point or key c carries can be read, and it carries one for each it must
+func (d *shuffleDeal) check(c *GameCommand, numPlayers int) error {
+	if c.Type == Unlock {
+		if len(c.Keys) != len(d.deck)-len(d.deck)/numPlayers {
+			return ErrBadShuffle
+		}
+		for _, k := range c.Keys {
+			if _, err := decodeScalar(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(c.Points) != len(d.deck) || (c.Type == Shuffle && (len(c.Shares) != numPlayers-1 || len(c.Escrow) != numPlayers-1)) {
+		return ErrBadShuffle
+	}
+	for _, p := range c.Points {
+		b, err := encoding.DecodeString(p)
+		if err != nil || len(b) != pointSize {
+			return ErrBadShuffle
+		}
+		if _, err := curvePoint(new(big.Int).SetBytes(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *shuffleDeal) add(c *GameCommand) {
+	switch c.Type {
+	case Shuffle:
+		d.commitments = append(d.commitments, c.Commitment)
+		d.shares = append(d.shares, c.Shares)
+		d.shuffled = append(d.shuffled, c.Points)
+	case Lock:
+		d.locked = append(d.locked, c.Points)
+	case Unlock:
+		d.unlocks[c.Player] = c.Keys
+	}
+}
+
+func equalFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the first player may start a deal: the round is over, every player is ready for the next,
+// and the game is running. A game which is over is only dealt again once its players have started another
+func (r *Replayer) canDeal() bool {
+	t := r.table
+	return t.RoundOver() && t.TrickNew() && t.AllReadyForNewRound() && r.status == Running && (!r.gameOver || r.rematch)
+}
+
+// Returns the step of the deal the player at playerIndex must write next, made with this device's key,
+// or nil if they have none to write
+func (r *Replayer) DealStep(playerIndex int) (*GameCommand, error) {
+	numPlayers := len(r.table.GetPlayers())
+	if r.key == nil || playerIndex < 0 || playerIndex >= numPlayers {
+		return nil, nil
+	}
+	if r.round == nil || r.round.deal.dealt {
+		if playerIndex != 0 || !r.canDeal() {
+			return nil, nil
+		}
+		d, err := newShuffleDeal(r.table.GetAllCards())
+		if err != nil {
+			return nil, err
+		}
+		return r.shuffleStep(d, r.key.dealSeed(r.deals+1, playerIndex), playerIndex)
+	}
+	d := r.round.deal
+	if len(d.shuffled) < numPlayers {
+		if playerIndex != len(d.shuffled) {
+			return nil, nil
+		}
+		return r.shuffleStep(d, r.key.dealSeed(r.deals, playerIndex), playerIndex)
+	}
+	seed, ok := d.seeds[playerIndex]
+	if !ok {
+		return nil, nil
+	}
+	if len(d.locked) < numPlayers {
+		if playerIndex != len(d.locked) {
+			return nil, nil
+		}
+		return d.step(Lock, seed, playerIndex, numPlayers)
+	}
+	if d.unlocks[playerIndex] != nil {
+		return nil, nil
+	}
+	return d.step(Unlock, seed, playerIndex, numPlayers)
+}
+
+// Returns the Shuffle of the player at playerIndex, escrowing each share of seed with the player it is for
+func (r *Replayer) shuffleStep(d *shuffleDeal, seed []byte, playerIndex int) (*GameCommand, error) {
+	numPlayers := len(r.table.GetPlayers())
+	c, err := d.step(Shuffle, seed, playerIndex, numPlayers)
+	if err != nil {
+		return nil, err
+	}
+	for i, share := range escrowShares(seed, playerIndex, numPlayers) {
+		holder := shareHolder(playerIndex, i)
+		key := r.seatKey(holder)
+		if key == "" {
+			return nil, fmt.Errorf("%w: player %d", ErrNoHandKey, holder)
+		}
+		box, err := sealBox(share, key)
+		if err != nil {
+			return nil, err
+		}
+		c.Escrow = append(c.Escrow, box)
+	}
+	return c, nil
+}
+
+// Applies a step of the deal, starting a new one for the first player's Shuffle once the last round is over
+// Once every player has unlocked, every hand is dealt
+func (r *Replayer) onDealStep(writer int, c *GameCommand) ([]Event, error) {
+	violation := func(err error) ([]Event, error) {
+		return r.violation(ViolationEvent{Writer: writer, Player: c.Player, Command: c.Type, Err: err}), nil
+	}
+	numPlayers := len(r.table.GetPlayers())
+	if r.round != nil && r.round.deal.written(c) {
+		// the same step written twice, for instance by a device which restarted
+		return nil, nil
+	}
+	var d *shuffleDeal
+	if r.round != nil && !r.round.deal.dealt {
+		d = r.round.deal
+	}
+	starting := d == nil && c.Type == Shuffle && c.Player == 0
+	switch {
+	case starting && (!r.table.RoundOver() || !r.table.TrickNew()):
+		return violation(table.ErrRoundNotOver)
+	case starting && !r.table.AllReadyForNewRound():
+		return violation(ErrDealOrder)
+	case starting:
+		var err error
+		if d, err = newShuffleDeal(r.table.GetAllCards()); err != nil {
+			return nil, err
+		}
+	case d == nil || !d.next(c, numPlayers):
+		return violation(ErrDealOrder)
+	}
+	if err := d.check(c, numPlayers); err != nil {
+		return violation(err)
+	}
+	if starting {
+		if r.gameOver {
+			r.table.NewGame()
+			r.gameOver = false
+			r.rematch = false
+		}
+		r.round = newSealedRound(r.table.Copy(), d)
+		r.deals++
+	}
+	d.add(c)
+	if c.Type == Shuffle && r.key != nil {
+		if seed := r.key.dealSeed(r.deals, c.Player); commitment(seed) == c.Commitment {
+			d.seeds[c.Player] = seed
+		}
+		r.openEscrow(d, c)
+	}
+	events := []Event{DealStepEvent{Player: c.Player, Step: c.Type}}
+	if len(d.unlocks) == numPlayers {
+		events = append(events, r.dealHands()...)
+	}
+	return events, nil
+}
+
+// Deals every hand once every player has unlocked: this device unlocks the hands of the players it dealt for,
+// and holds every other hand as hidden cards
+// A hand which doesn't unlock to cards of the deck is reported by the device it was dealt to, and held hidden there
+func (r *Replayer) dealHands() []Event {
+	d := r.round.deal
+	d.dealt = true
+	players := r.table.GetPlayers()
+	events := make([]Event, 0, len(players)+1)
+	for p, player := range players {
+		hand := hiddenCards(len(d.deck) / len(players))
+		if seed, ok := d.seeds[p]; ok {
+			cards, err := d.hand(seed, p, len(players))
+			if err != nil {
+				events = append(events, r.violation(ViolationEvent{Writer: -1, Player: p, Command: Unlock, Err: err})...)
+			} else {
+				hand = cards
+			}
+		}
+		player.SetHand(hand)
+		events = append(events, DealEvent{Player: p, Cards: hand})
+	}
+	r.table.NewRound()
+	// no device can tell who holds the first lead until it is played
+	r.table.SetFirstPlayer(-1)
+	return append(events, NewRoundEvent{})
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sign.go lets devices tell who wrote a log entry. Every seated player may write anywhere in the game log, and the
+// <player_id> suffix of a log key is only what the writer claims, so players sign the commands they write.
+// Each HandKey also holds a signing key, drawn from its private half, whose public half is published as the
+// user's sign key. A command is signed together with the key it is written under, and carries the signature as
+// its last field, such as "sig Rk9v...". Once the user in a seat has published a sign key, entries under that
+// seat must carry its signature.
+
+package replay
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrBadSignature is reported for a log entry which doesn't carry the signature of the seat it is written under
+	ErrBadSignature = errors.New("Log entry not signed by its writer")
+	// ErrSignKeyChanged is reported for a user's sign key written again with a different value
+	ErrSignKeyChanged = errors.New("Sign key changed after it was recorded")
+)
+
+// signing keys are drawn from the private half of a HandKey under this label, so that the two are never the same key
+const signLabel = "hearts sign key"
+
+func (k *HandKey) signingKey() ed25519.PrivateKey {
+	seed := sha256.Sum256(append([]byte(signLabel), k.private.Bytes()...))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// Returns the public half of k's signing key, which other players check the commands it signs against
+func (k *HandKey) SignKey() string {
+	return encoding.EncodeToString(k.signingKey().Public().(ed25519.PublicKey))
+}
+
+// Returns value, a command to be written under the log key key, with k's signature of both
+func (k *HandKey) Sign(key, value string) (string, error) {
+	if !strings.HasSuffix(value, End) {
+		return "", ErrMalformedCommand
+	}
+	signature := ed25519.Sign(k.signingKey(), signedMessage(key, value))
+	return strings.TrimSuffix(value, End) + Signature + Space + encoding.EncodeToString(signature) + Colon + End, nil
+}
+
+// Returns true if value, written under key and decoded as c, carries a signature by the sign key public
+func verifySignature(public, key, value string, c *GameCommand) bool {
+	publicKey, err := encoding.DecodeString(public)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize || c.Signature == "" {
+		return false
+	}
+	signature, err := encoding.DecodeString(c.Signature)
+	if err != nil {
+		return false
+	}
+	field := Signature + Space + c.Signature + Colon + End
+	if !strings.HasSuffix(value, field) {
+		return false
+	}
+	unsigned := strings.TrimSuffix(value, field) + End
+	return ed25519.Verify(ed25519.PublicKey(publicKey), signedMessage(key, unsigned), signature)
+}
+
+func signedMessage(key, value string) []byte {
+	return []byte(key + Bar + value)
+}
+
+// Returns the sign key published by the user in the seat playerIndex, or "" if they haven't published one
+func (r *Replayer) seatSignKey(playerIndex int) string {
+	userID, ok := r.seatUsers[playerIndex]
+	if !ok {
+		return ""
+	}
+	return r.signKeys[userID]
+}
+
+// Only the first sign key a user publishes is accepted, so that no one can sign commands in another user's place
+func (r *Replayer) onSignKey(userID int, value string) ([]Event, error) {
+	if first, ok := r.signKeys[userID]; ok {
+		if first != value {
+			return r.violation(ViolationEvent{Writer: -1, Player: -1, Command: "sign_key", Err: ErrSignKeyChanged}), nil
+		}
+		return nil, nil
+	}
+	r.signKeys[userID] = value
+	return nil, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// status.go contains the lifecycle of a game, written under <game_id>/status, and the result of a finished game,
+// written under <game_id>/result.
+// A game is CREATED when its owner sets it up and RUNNING once it starts, and may be PAUSED and resumed while running.
+// It ends either FINISHED, once a player has won, or ABANDONED. A FINISHED game starts RUNNING again
+// if the same players play another game at the table; an ABANDONED game never changes status again.
+// Writers check transitions with ValidTransition. The status key only holds the latest status, so a device
+// reading the log late may skip statuses: Apply accepts any status the game could have reached since the last
+// one it read, and reports any other as a violation.
+
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type Status string
+
+const (
+	NoStatus  Status = ""
+	Created   Status = "CREATED"
+	Running   Status = "RUNNING"
+	Paused    Status = "PAUSED"
+	Finished  Status = "FINISHED"
+	Abandoned Status = "ABANDONED"
+)
+
+var (
+	// ErrUnknownStatus is returned by ParseStatus for a value which isn't a status
+	ErrUnknownStatus = errors.New("Unknown game status")
+	// ErrBadTransition is returned by ValidTransition for a status the game can't move to from its current one
+	ErrBadTransition = errors.New("Invalid game status transition")
+)
+
+// the statuses each status may move to
+var transitions = map[Status][]Status{
+	NoStatus: {Created},
+	Created:  {Running, Abandoned},
+	Running:  {Paused, Finished, Abandoned},
+	Paused:   {Running, Abandoned},
+	Finished: {Running},
+}
+
+// Returns the status written as value
+func ParseStatus(value string) (Status, error) {
+	switch s := Status(value); s {
+	case Created, Running, Paused, Finished, Abandoned:
+		return s, nil
+	}
+	return NoStatus, ErrUnknownStatus
+}
+
+// Returns nil if a game with status s may move to next, or ErrBadTransition if it may not
+func (s Status) ValidTransition(next Status) error {
+	for _, t := range transitions[s] {
+		if t == next {
+			return nil
+		}
+	}
+	return ErrBadTransition
+}
+
+// Returns true if a game with status s may reach next through one or more transitions
+func (s Status) reaches(next Status) bool {
+	seen := map[Status]bool{s: true}
+	queue := []Status{s}
+	for len(queue) > 0 {
+		for _, t := range transitions[queue[0]] {
+			if t == next {
+				return true
+			}
+			if !seen[t] {
+				seen[t] = true
+				queue = append(queue, t)
+			}
+		}
+		queue = queue[1:]
+	}
+	return false
+}
+
+// Returns true if the game has started and not yet ended, so that a device joining it is shown the game as it stands
+func (s Status) Started() bool {
+	return s == Running || s == Paused
+}
+
+// Returns true if the game has ended, and can no longer be joined or rejoined
+func (s Status) Over() bool {
+	return s == Finished || s == Abandoned
+}
+
+// Returns the key holding the status of a game
+func StatusKey(gameID int) string {
+	return fmt.Sprintf("%d/status", gameID)
+}
+
+// Result is the outcome of a finished game
+type Result struct {
+	Scores  []int `json:"scores"`  // the final score of each player
+	Winners []int `json:"winners"` // the players with the lowest final score
+}
+
+// Returns r encoded to be written to the log
+func (r *Result) Encode() (string, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+// Returns the result encoded in value
+func DecodeResult(value string) (*Result, error) {
+	r := &Result{}
+	if err := json.Unmarshal([]byte(value), r); err != nil {
+		return nil, ErrMalformedEntry
+	}
+	return r, nil
+}
+
+// Returns the key holding the result of a game
+func ResultKey(gameID int) string {
+	return fmt.Sprintf("%d/result", gameID)
+}
+
+// Returns the status most recently read from the log
+func (r *Replayer) Status() Status {
+	return r.status
+}
+
+func (r *Replayer) onStatus(value string) ([]Event, error) {
+	s, err := ParseStatus(value)
+	if err != nil {
+		return nil, ErrMalformedEntry
+	}
+	if s != r.status && !r.status.reaches(s) {
+		return r.violation(ViolationEvent{Writer: -1, Player: -1, Command: "status", Err: ErrBadTransition}), nil
+	}
+	r.status = s
+	if s == Running && r.gameOver {
+		r.rematch = true
+	}
+	return []Event{StatusEvent{Status: s}}, nil
+}
+
+func (r *Replayer) onResult(value string) ([]Event, error) {
+	result, err := DecodeResult(value)
+	if err != nil {
+		return nil, err
+	}
+	return []Event{ResultEvent{Result: result}}, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// timer.go contains the time limit on each move, written by the owner under <game_id>/turn_limit as a number of seconds,
+// and the Timeout command, which plays for a player who ran out of time.
+// Devices don't share a clock, so no device can check how long a player took; a Timeout is accepted as long as
+// it plays the player's lowest legal card, so every device which reads it agrees the player timed out.
+
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"hearts/logic/card"
+)
+
+// ErrNotLowestPlay is found for a Timeout which doesn't play the lowest legal card of the player who timed out
+var ErrNotLowestPlay = errors.New("A player who runs out of time must play their lowest legal card")
+
+// Returns the key holding the time limit on each move of a game
+func TurnLimitKey(gameID int) string {
+	return fmt.Sprintf("%d/turn_limit", gameID)
+}
+
+// Returns the time limit on each move most recently read from the log, 0 if there is none
+func (r *Replayer) TurnLimit() time.Duration {
+	return r.turnLimit
+}
+
+func (r *Replayer) onTurnLimit(value string) ([]Event, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return nil, ErrMalformedEntry
+	}
+	r.turnLimit = time.Duration(seconds) * time.Second
+	return []Event{TurnLimitEvent{Limit: r.turnLimit}}, nil
+}
+
+// Returns nil if c may be played for the player at playerIndex, who ran out of time
+// A hidden hand is only checked as far as every device can check it, until the round is audited
+func (r *Replayer) validTimeout(c *card.Card, playerIndex int, hidden bool) error {
+	if hidden {
+		return r.table.ValidHiddenPlay(c, playerIndex)
+	}
+	if err := r.table.ValidPlay(c, playerIndex); err != nil {
+		return err
+	}
+	if c != r.table.LowestLegalPlay(playerIndex) {
+		return ErrNotLowestPlay
+	}
+	return nil
+}
+
+func (r *Replayer) onTimeout(playerIndex int, c *card.Card) ([]Event, error) {
+	events, err := r.onPlay(playerIndex, c)
+	if err != nil {
+		return nil, err
+	}
+	e := events[0].(PlayEvent)
+	e.TimedOut = true
+	return []Event{e}, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// access.go contains Permissions, the access lists which say who may read, write and administer a syncgroup
+// Devices are named by their blessings, and access lists by blessing patterns. A pattern matches the blessing it
+// names and every blessing extending it, unless it ends in ":$", and AllUsers matches every blessing.
+// Permissions cover both the syncgroup itself and the rows it shares: joining needs Read, writing a shared row
+// needs Write, and changing the permissions needs Admin.
+
+package store
+
+import (
+	"errors"
+	"strings"
+)
+
+// AllUsers is the blessing pattern matching every device
+const AllUsers = "..."
+
+// the separator between the names a blessing extends
+const blessingSeparator = ":"
+
+// ends a pattern which matches only the blessing it names, and none extending it
+const noExtension = blessingSeparator + "$"
+
+// ErrNoAccess is returned when a device's blessings aren't in the access list for what it tried to do
+var ErrNoAccess = errors.New("Access denied")
+
+type Permissions struct {
+	Admin []string // may change the permissions
+	Write []string // may write the shared rows
+	Read  []string // may join the syncgroup and read the shared rows
+}
+
+// Returns true if any of blessings matches any of patterns
+func Matches(patterns, blessings []string) bool {
+	for _, p := range patterns {
+		for _, b := range blessings {
+			if exact := strings.TrimSuffix(p, noExtension); exact != p {
+				if b == exact {
+					return true
+				}
+			} else if p == AllUsers || b == p || strings.HasPrefix(b, p+blessingSeparator) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns a pattern for each of claimed which is one of the blessings members authenticated with,
+// matching only that blessing. Anything else, such as AllUsers or a pattern, is dropped
+func Authenticated(claimed, members []string) []string {
+	patterns := make([]string, 0)
+	for _, c := range claimed {
+		for _, m := range members {
+			if c == m && c != AllUsers && !strings.HasSuffix(c, noExtension) {
+				patterns = append(patterns, c+noExtension)
+				break
+			}
+		}
+	}
+	return patterns
+}
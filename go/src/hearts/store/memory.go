@@ -0,0 +1,348 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// memory.go contains an in-process Store for simulated devices
+// A Network holds any number of devices, each with its own tables. A write to a row shared through a syncgroup
+// is copied to the other members before Put returns, so every device sees it as though it had synced instantly.
+// Each device's only blessing is its name, and syncgroup permissions are checked against it.
+
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+// Network links the memory stores of simulated devices
+type Network struct {
+	mu         sync.Mutex
+	syncgroups map[string]*memorySyncgroup
+}
+
+type memorySyncgroup struct {
+	prefixes []Prefix
+	perms    Permissions
+	members  []*Memory
+}
+
+// Memory is the Store of one device on a Network
+type Memory struct {
+	network  *Network
+	name     string
+	tables   map[string]map[string][]byte
+	watchers []*memoryWatch
+}
+
+type memoryWatch struct {
+	table   string
+	prefix  string
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []Change
+	current Change
+	stopped bool
+}
+
+// Returns an empty network
+func NewNetwork() *Network {
+	return &Network{
+		syncgroups: make(map[string]*memorySyncgroup),
+	}
+}
+
+// Returns the store of a new device on n, named name
+func (n *Network) NewDevice(name string) *Memory {
+	return &Memory{
+		network: n,
+		name:    name,
+		tables:  make(map[string]map[string][]byte),
+	}
+}
+
+func (m *Memory) CreateTable(name string) error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	if m.tables[name] == nil {
+		m.tables[name] = make(map[string][]byte)
+	}
+	return nil
+}
+
+func (m *Memory) Put(table, key string, value []byte) error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	if m.tables[table] == nil {
+		return ErrNoTable
+	}
+	for _, sg := range m.network.syncgroups {
+		if sg.shares(m, table, key) && !Matches(sg.perms.Write, m.Blessings()) {
+			return ErrNoAccess
+		}
+	}
+	m.put(table, key, value)
+	for _, d := range m.peers(table, key) {
+		d.put(table, key, value)
+	}
+	return nil
+}
+
+func (m *Memory) Scan(table, prefix string) ([]Row, error) {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	rows, ok := m.tables[table]
+	if !ok {
+		return nil, ErrNoTable
+	}
+	p := Prefix{Table: table, Row: prefix}
+	scanned := make([]Row, 0)
+	for key, value := range rows {
+		if p.Covers(table, key) {
+			scanned = append(scanned, Row{Key: key, Value: copyBytes(value)})
+		}
+	}
+	sort.Sort(rowSorter(scanned))
+	return scanned, nil
+}
+
+func (m *Memory) Watch(table, prefix string) (WatchStream, error) {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	if m.tables[table] == nil {
+		return nil, ErrNoTable
+	}
+	w := &memoryWatch{table: table, prefix: prefix}
+	w.cond = sync.NewCond(&w.mu)
+	m.watchers = append(m.watchers, w)
+	return w, nil
+}
+
+func (m *Memory) CreateSyncgroup(name string, prefixes []Prefix, perms Permissions) error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	if m.network.syncgroups[name] != nil {
+		return ErrSyncgroupExists
+	}
+	m.network.syncgroups[name] = &memorySyncgroup{
+		prefixes: append([]Prefix{}, prefixes...),
+		perms:    perms,
+		members:  []*Memory{m},
+	}
+	return nil
+}
+
+// Joining copies the rows the syncgroup shares between all of its members
+// Where members hold different values under the same key, the value held by the device joining wins
+func (m *Memory) JoinSyncgroup(name string, creator bool) error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	sg := m.network.syncgroups[name]
+	if sg == nil {
+		return ErrNoSyncgroup
+	}
+	if sg.isMember(m) {
+		return nil
+	}
+	if !Matches(sg.perms.Read, m.Blessings()) {
+		return ErrNoAccess
+	}
+	sg.members = append(sg.members, m)
+	for _, p := range sg.prefixes {
+		shared := make(map[string][]byte)
+		for _, d := range sg.members {
+			for key, value := range d.tables[p.Table] {
+				if p.Covers(p.Table, key) {
+					shared[key] = value
+				}
+			}
+		}
+		keys := make([]string, 0, len(shared))
+		for key := range shared {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := shared[key]
+			for _, d := range sg.members {
+				if current, ok := d.tables[p.Table][key]; !ok || string(current) != string(value) {
+					d.put(p.Table, key, value)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Only a member with Admin access may set the permissions
+func (m *Memory) SetPermissions(name string, perms Permissions) error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	sg := m.network.syncgroups[name]
+	if sg == nil {
+		return ErrNoSyncgroup
+	}
+	if !sg.isMember(m) || !Matches(sg.perms.Admin, m.Blessings()) {
+		return ErrNoAccess
+	}
+	sg.perms = perms
+	return nil
+}
+
+func (m *Memory) Members(name string) ([]string, error) {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	sg := m.network.syncgroups[name]
+	if sg == nil {
+		return nil, ErrNoSyncgroup
+	}
+	names := make([]string, len(sg.members))
+	for i, d := range sg.members {
+		names[i] = d.name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *Memory) MemberBlessings(name string) ([]string, error) {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	sg := m.network.syncgroups[name]
+	if sg == nil {
+		return nil, ErrNoSyncgroup
+	}
+	blessings := make([]string, 0)
+	for _, d := range sg.members {
+		blessings = append(blessings, d.Blessings()...)
+	}
+	sort.Strings(blessings)
+	return blessings, nil
+}
+
+func (m *Memory) Syncgroups() ([]string, error) {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	names := make([]string, 0)
+	for name, sg := range m.network.syncgroups {
+		for _, d := range sg.members {
+			if d == m {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *Memory) Blessings() []string {
+	return []string{m.name}
+}
+
+// Returns the other devices sharing the row key of table with m. Must be called with the network locked
+func (m *Memory) peers(table, key string) []*Memory {
+	peers := make([]*Memory, 0)
+	seen := map[*Memory]bool{m: true}
+	for _, sg := range m.network.syncgroups {
+		if !sg.shares(m, table, key) {
+			continue
+		}
+		for _, d := range sg.members {
+			if !seen[d] {
+				seen[d] = true
+				peers = append(peers, d)
+			}
+		}
+	}
+	return peers
+}
+
+// Returns true if d is a member of sg
+func (sg *memorySyncgroup) isMember(d *Memory) bool {
+	for _, other := range sg.members {
+		if other == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if d is a member of sg and one of its prefixes covers the row key of table
+func (sg *memorySyncgroup) shares(d *Memory, table, key string) bool {
+	if !sg.isMember(d) {
+		return false
+	}
+	for _, p := range sg.prefixes {
+		if p.Covers(table, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes a row to m alone and tells its watchers. Must be called with the network locked
+func (m *Memory) put(table, key string, value []byte) {
+	if m.tables[table] == nil {
+		m.tables[table] = make(map[string][]byte)
+	}
+	m.tables[table][key] = copyBytes(value)
+	for _, w := range m.watchers {
+		if (Prefix{Table: w.table, Row: w.prefix}).Covers(table, key) {
+			w.push(Change{Table: table, Key: key, Value: copyBytes(value)})
+		}
+	}
+}
+
+func (w *memoryWatch) push(c Change) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.queue = append(w.queue, c)
+		w.cond.Signal()
+	}
+}
+
+func (w *memoryWatch) Advance() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && !w.stopped {
+		w.cond.Wait()
+	}
+	if w.stopped {
+		return false
+	}
+	w.current = w.queue[0]
+	w.queue = w.queue[1:]
+	return true
+}
+
+func (w *memoryWatch) Change() Change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+func (w *memoryWatch) Cancel() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.queue = nil
+	w.cond.Broadcast()
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
+
+// Used to sort rows by key
+type rowSorter []Row
+
+func (rs rowSorter) Len() int {
+	return len(rs)
+}
+
+func (rs rowSorter) Swap(i, j int) {
+	rs[i], rs[j] = rs[j], rs[i]
+}
+
+func (rs rowSorter) Less(i, j int) bool {
+	return rs[i].Key < rs[j].Key
+}
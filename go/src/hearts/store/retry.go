@@ -0,0 +1,79 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// retry.go retries writes to a Store which fail, waiting longer after each failure
+// A write which still fails after the last attempt is reported as ErrOffline, so that the caller can stop and tell the user
+// A write refused with ErrNoAccess isn't retried, since it would be refused again
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOffline is returned by Retry when every attempt has failed
+var ErrOffline = errors.New("Store is offline")
+
+// Context is the part of a context Retry needs. Both context.Context and the v23 context.T satisfy it
+type Context interface {
+	Done() <-chan struct{}
+	Err() error
+}
+
+// Backoff describes how Retry spaces out its attempts
+// The wait after the first failed attempt is Initial, and each wait after that is twice as long, up to Max
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+// DefaultBackoff gives up after about five seconds
+var DefaultBackoff = Backoff{
+	Initial:  50 * time.Millisecond,
+	Max:      2 * time.Second,
+	Attempts: 8,
+}
+
+// Returns how long to wait after the nth failed attempt, counting from 0
+func (b Backoff) Delay(n int) time.Duration {
+	d := b.Initial
+	for i := 0; i < n && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	return d
+}
+
+// Calls f until it returns nil, at most b.Attempts times
+// Returns an error wrapping ErrOffline and the last error from f if every attempt fails,
+// or the error of ctx if it is done first. ctx may be nil, in which case Retry can't be cancelled
+// An error from f wrapping ErrNoAccess is returned at once
+func Retry(ctx Context, b Backoff, f func() error) error {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	var err error
+	for n := 0; n < b.Attempts; n++ {
+		if err = f(); err == nil || errors.Is(err, ErrNoAccess) {
+			return err
+		}
+		if n == b.Attempts-1 {
+			break
+		}
+		timer := time.NewTimer(b.Delay(n))
+		select {
+		case <-done:
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrOffline, err)
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// store contains Store, the storage and synchronization hearts/sync writes the game log and settings through.
+// Devices share rows through syncgroups: every row whose key starts with one of a syncgroup's prefixes
+// is copied to each device which has joined it.
+// hearts/sync implements Store on top of Syncbase; Network links in-memory stores without any network at all.
+
+package store
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNoTable is returned when reading or writing a table which hasn't been created
+	ErrNoTable = errors.New("No such table")
+	// ErrNoSyncgroup is returned when joining or inspecting a syncgroup which hasn't been created
+	ErrNoSyncgroup = errors.New("No such syncgroup")
+	// ErrSyncgroupExists is returned when creating a syncgroup under a name which is already taken
+	ErrSyncgroupExists = errors.New("Syncgroup already exists")
+)
+
+type Store interface {
+	// Creates the table name, if it doesn't already exist
+	CreateTable(name string) error
+	// Writes value under key in table
+	Put(table, key string, value []byte) error
+	// Returns the rows of table whose keys start with prefix, in key order
+	Scan(table, prefix string) ([]Row, error)
+	// Returns a stream of the changes made from now on to rows of table whose keys start with prefix
+	Watch(table, prefix string) (WatchStream, error)
+	// Creates the syncgroup name sharing the rows under prefixes with perms, and joins it
+	CreateSyncgroup(name string, prefixes []Prefix, perms Permissions) error
+	// Joins the syncgroup name. creator is true if this device created the game the syncgroup belongs to
+	JoinSyncgroup(name string, creator bool) error
+	// Replaces the permissions of the syncgroup name and the rows it shares
+	SetPermissions(name string, perms Permissions) error
+	// Returns the names of the devices which have joined the syncgroup name
+	Members(name string) ([]string, error)
+	// Returns the blessings the devices which have joined the syncgroup name authenticated with
+	MemberBlessings(name string) ([]string, error)
+	// Returns the names of the syncgroups this device has joined
+	Syncgroups() ([]string, error)
+	// Returns the blessings this device reads and writes with
+	Blessings() []string
+}
+
+type Row struct {
+	Key   string
+	Value []byte
+}
+
+// Prefix names the rows of Table whose keys start with Row
+type Prefix struct {
+	Table string
+	Row   string
+}
+
+type Change struct {
+	Table     string
+	Key       string
+	Value     []byte // nil if the row was deleted
+	Delete    bool
+	Continued bool // true if more changes made at the same time follow this one
+}
+
+type WatchStream interface {
+	// Waits for the next change, returning false once the stream has been cancelled
+	Advance() bool
+	// Returns the change Advance moved to
+	Change() Change
+	// Stops the stream
+	Cancel()
+}
+
+// Returns true if the row key of table is shared by prefix
+func (p Prefix) Covers(table, key string) bool {
+	return p.Table == table && len(key) >= len(p.Row) && key[:len(p.Row)] == p.Row
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// aiPlayer.go moves for the computer players at the table.
+// Only the game owner moves for them, writing the same log entries a person would;
+// every device then applies those entries like any other update from the game log.
+
+package sync
+
+import (
+	"hearts/ai"
+	"hearts/img/uistate"
+)
+
+// Seats a computer player at playerIndex, if that seat is empty. Returns true if the player was seated
+func AddAIPlayer(playerIndex int, u *uistate.UIState) bool {
+	if u.PlayerData[playerIndex] != 0 {
+		return false
+	}
+	return LogAIPlayerNum(u, playerIndex) == nil
+}
+
+// Records the computer player seated at playerIndex. The owner starts moving for it with the default strategy
+func onAIPlayerNum(playerIndex int, u *uistate.UIState) {
+	if u.UserData[ai.UserID(playerIndex)] == nil {
+		u.UserData[ai.UserID(playerIndex)] = map[string]interface{}{
+			uistate.Avatar: ai.Avatar,
+			uistate.Name:   ai.Name(playerIndex),
+			uistate.Device: ai.Device,
+		}
+	}
+	if u.IsOwner && u.AIPlayers[playerIndex] == nil {
+		u.AIPlayers[playerIndex] = ai.Default()
+	}
+}
+
+// Makes the next move for every computer player who has one to make
+// A computer player moves at most once until its move comes back through the game log
+func runAI(u *uistate.UIState) {
+	if !u.IsOwner || u.CurView == uistate.Arrange || u.CurView == uistate.Discovery {
+		return
+	}
+	for playerIndex, s := range u.AIPlayers {
+		if u.AIPending[playerIndex] || u.PlayerData[playerIndex] != ai.UserID(playerIndex) {
+			continue
+		}
+		u.AIPending[playerIndex] = moveAI(playerIndex, s, u)
+	}
+}
+
+// Logs the move the computer player at playerIndex should make next, if any. Returns true if a move was logged
+// A move which couldn't be logged is chosen again the next time the computer players move
+func moveAI(playerIndex int, s ai.Strategy, u *uistate.UIState) bool {
+	t := u.CurTable
+	p := t.GetPlayers()[playerIndex]
+	var err error
+	switch {
+	case !p.GetDoneScoring() && t.RoundOver() && t.TrickNew():
+		err = LogReady(u, playerIndex)
+	case t.AllDoneDealing() && len(t.LegalPasses(playerIndex)) > 0:
+		err = LogPass(u, playerIndex, s.Pass(t, playerIndex))
+	case canTake(playerIndex, u):
+		err = LogTake(u, playerIndex)
+	case t.TrickOver() && t.GetTrickRecipient() == playerIndex:
+		err = LogTakeTrick(u, playerIndex)
+	case p.GetDoneTaking() && len(t.LegalPlays(playerIndex)) > 0:
+		err = LogPlay(u, playerIndex, s.Play(t, playerIndex))
+	default:
+		return false
+	}
+	return err == nil
+}
+
+// Returns true if the cards passed to the player at playerIndex are ready to be taken
+func canTake(playerIndex int, u *uistate.UIState) bool {
+	t := u.CurTable
+	if t.ValidTake(playerIndex) != nil {
+		return false
+	}
+	return !u.SequentialPhases || t.AllDonePassing()
+}
@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// client handles pulling data from syncbase. To be fleshed out when discovery is added.
+// client handles pulling data from the store. To be fleshed out when discovery is added.
 
 package sync
 
@@ -11,12 +11,11 @@ import (
 
 	"hearts/img/uistate"
 	"hearts/img/view"
+	"hearts/store"
 	"hearts/util"
 
 	"v.io/v23/context"
 	"v.io/v23/discovery"
-	wire "v.io/v23/services/syncbase"
-	"v.io/v23/syncbase"
 	ldiscovery "v.io/x/ref/lib/discovery"
 	"v.io/x/ref/lib/discovery/plugins/mdns"
 	"v.io/x/ref/lib/signals"
@@ -77,53 +76,69 @@ func GetSG(instances map[string]string, update discovery.Update, u *uistate.UISt
 }
 
 // Returns a watchstream of the data in the table
-func WatchData(tableName, prefix string, u *uistate.UIState) (syncbase.WatchStream, error) {
-	db := u.Service.App(util.AppName).Database(util.DbName, nil)
-	resumeMarker, err := db.GetResumeMarker(u.Ctx)
-	if err != nil {
-		fmt.Println("RESUMEMARKER ERR: ", err)
-	}
-	return db.Watch(u.Ctx, tableName, prefix, resumeMarker)
+func WatchData(tableName, prefix string, u *uistate.UIState) (store.WatchStream, error) {
+	return u.Store.Watch(tableName, prefix)
 }
 
-// Returns a scanstream of the data in the table
-func ScanData(tableName, prefix string, u *uistate.UIState) syncbase.ScanStream {
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	table := db.Table(tableName)
-	rowRange := syncbase.Range(prefix, "")
-	return table.Scan(u.Ctx, rowRange)
+// Returns the data in the table, in key order
+func ScanData(tableName, prefix string, u *uistate.UIState) ([]store.Row, error) {
+	return u.Store.Scan(tableName, prefix)
 }
 
-// Joins gamelog syncgroup
-func JoinLogSyncgroup(logName string, creator bool, u *uistate.UIState) bool {
+// Joins gamelog syncgroup, whose game is for numPlayers
+func JoinLogSyncgroup(logName string, creator bool, numPlayers int, u *uistate.UIState) bool {
 	fmt.Println("Joining gamelog syncgroup")
 	u.IsOwner = creator
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	logSg := db.Syncgroup(logName)
-	myInfoJoiner := wire.SyncgroupMemberInfo{8, creator}
-	_, err := logSg.Join(u.Ctx, myInfoJoiner)
+	err := u.Store.JoinSyncgroup(logName, creator)
 	if err != nil {
 		fmt.Println("SYNCGROUP JOIN ERROR: ", err)
 		return false
 	} else {
 		fmt.Println("Syncgroup joined")
 		if u.LogSG != logName {
-			ResetGame(logName, creator, u)
+			ResetGame(logName, creator, numPlayers, u)
 		}
 		return true
 	}
 }
 
+// Rejoins the game this device was last in, if it hasn't ended, for instance after the app was restarted
+// The player takes back their seat, and is shown the game as it stands once its log has been read
+// A paused game is resumed when its owner rejoins it
+// Returns false if there is no game to rejoin
+func ResumeGame(u *uistate.UIState) bool {
+	logName, creator, numPlayers, ok := readLogAddr()
+	if !ok || uistate.GetGameStatus(logGameID(logName), u).Over() {
+		return false
+	}
+	joined, _ := u.Store.Syncgroups()
+	found := false
+	for _, name := range joined {
+		found = found || name == logName
+	}
+	if !found {
+		return false
+	}
+	// the log has already been read if this is the game the device is in
+	current := u.LogSG == logName
+	view.LoadArrangeView(u)
+	if !JoinLogSyncgroup(logName, creator, numPlayers, u) {
+		return false
+	}
+	if sgName := CreateSettingsSyncgroup(u); sgName != "" {
+		LogSettingsName(sgName, u)
+	}
+	if current {
+		resumePaused(u)
+		loadGameView(u)
+	}
+	return true
+}
+
 // Joins player settings syncgroup
 func JoinSettingsSyncgroup(settingsName string, u *uistate.UIState) {
 	fmt.Println("Joining user settings syncgroup")
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	settingsSg := db.Syncgroup(settingsName)
-	myInfoJoiner := wire.SyncgroupMemberInfo{8, false}
-	_, err := settingsSg.Join(u.Ctx, myInfoJoiner)
+	err := u.Store.JoinSyncgroup(settingsName, false)
 	if err != nil {
 		fmt.Println("SYNCGROUP JOIN ERROR: ", err)
 	} else {
@@ -132,10 +147,7 @@ func JoinSettingsSyncgroup(settingsName string, u *uistate.UIState) {
 }
 
 func NumInSG(logName string, u *uistate.UIState) int {
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	sg := db.Syncgroup(logName)
-	members, err := sg.GetMembers(u.Ctx)
+	members, err := u.Store.Members(logName)
 	if err != nil {
 		fmt.Println(err)
 	}
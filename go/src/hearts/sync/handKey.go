@@ -0,0 +1,91 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// handKey.go keeps the key this device deals and opens its sealed cards with, writes its steps of each deal,
+// and reveals its cards once each round is over.
+// Each game gets its own key, kept in the settings table under a row no syncgroup shares,
+// so that a device rejoining a game can still open the cards it was dealt.
+
+package sync
+
+import (
+	"fmt"
+
+	"hearts/img/uistate"
+	"hearts/replay"
+	"hearts/util"
+)
+
+// ErrNoHandKey is returned when cards can't be sealed because a player they are for hasn't published a key
+var ErrNoHandKey = replay.ErrNoHandKey
+
+func handKeyRow(gameID int) string {
+	return fmt.Sprintf("hand_keys/%d", gameID)
+}
+
+// Returns this device's key for the current game, creating and saving a new one if it has none
+func loadHandKey(u *uistate.UIState) (*replay.HandKey, error) {
+	row := handKeyRow(u.GameID)
+	rows, err := u.Store.Scan(util.SettingsName, row)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if r.Key == row {
+			return replay.ParseHandKey(string(r.Value))
+		}
+	}
+	key, err := replay.NewHandKey()
+	if err != nil {
+		return nil, err
+	}
+	return key, u.Store.Put(util.SettingsName, row, []byte(key.String()))
+}
+
+// Returns the public key of the player at playerIndex, or ErrNoHandKey if they haven't published one
+func playerHandKey(u *uistate.UIState, playerIndex int) (string, error) {
+	key := u.HandKeys[u.PlayerData[playerIndex]]
+	if key == "" {
+		return "", fmt.Errorf("%w: player %d", ErrNoHandKey, playerIndex)
+	}
+	return key, nil
+}
+
+// Reveals the sealed cards of every seat this device controls, once the round is over
+// A seat is revealed at most once until its reveal comes back through the log
+func revealCards(r *replay.Replayer, u *uistate.UIState) {
+	for playerIndex, openings := range r.DueReveals() {
+		if u.RevealPending[playerIndex] || !controls(u, playerIndex) {
+			continue
+		}
+		if err := LogReveal(u, playerIndex, openings); err != nil {
+			fmt.Println("Reveal error:", err)
+			continue
+		}
+		u.RevealPending[playerIndex] = true
+	}
+}
+
+// Writes the next step of the deal for every seat this device controls which has one to write
+// A seat writes at most one step until it comes back through the log
+func dealCards(r *replay.Replayer, u *uistate.UIState) {
+	for _, playerIndex := range controlledSeats(u) {
+		if u.DealPending[playerIndex] {
+			continue
+		}
+		c, err := r.DealStep(playerIndex)
+		if err != nil {
+			fmt.Println("Deal error:", err)
+			continue
+		}
+		if c == nil {
+			continue
+		}
+		if err := LogDealStep(u, playerIndex, c); err != nil {
+			fmt.Println("Deal error:", err)
+			continue
+		}
+		u.DealPending[playerIndex] = true
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// history.go records each game this device sees finish in its own history.
+// The game is kept in the settings table, under rows no syncgroup shares.
+
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"hearts/history"
+	"hearts/img/uistate"
+	"hearts/replay"
+	"hearts/util"
+)
+
+// Notes when the current game was first dealt
+// The first deal after a game finishes starts recording the next game played in the same log
+func recordDeal(u *uistate.UIState) {
+	if u.Record != nil && len(u.Record.Winners) > 0 {
+		u.Record = &history.Game{GameID: u.GameID, Number: u.Record.Number + 1}
+	}
+	if u.Record != nil && u.Record.Started.IsZero() {
+		u.Record.Started = time.Now()
+	}
+}
+
+// Adds the round e finished to the current game, and keeps the game in this device's history if it's over
+func recordRound(e replay.TakeTrickEvent, u *uistate.UIState) {
+	if u.Record == nil {
+		return
+	}
+	u.Record.Rounds = append(u.Record.Rounds, history.Round{
+		Scores:      e.RoundScores,
+		MoonShooter: e.MoonShooter,
+		QueenTaker:  e.QueenTaker,
+	})
+	if len(e.Winners) == 0 {
+		return
+	}
+	u.Record.Winners = e.Winners
+	u.Record.Finished = time.Now()
+	if u.Record.Started.IsZero() {
+		u.Record.Started = u.Record.Finished
+	}
+	u.Record.Players = make([]history.Player, u.NumPlayers)
+	for i := range u.Record.Players {
+		u.Record.Players[i] = history.Player{UserID: u.PlayerData[i], Name: uistate.GetName(i, u)}
+	}
+	added, err := history.New(u.Store, util.SettingsName).Add(u.Record)
+	if err != nil {
+		fmt.Println("History error:", err)
+	} else if added {
+		loadRatings(u)
+	}
+}
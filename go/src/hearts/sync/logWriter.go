@@ -2,126 +2,276 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// gamelog handles creating the appropriately formatted key and value strings to write to the game log in syncbase
+// gamelog handles creating the appropriately formatted key and value strings to write to the game log in the store
 // a description of the log syntax can be found here: https://docs.google.com/document/d/1uZc9EQ2-F6CjJjGkj7VWvJNFklKGsFGQSVHtpEiJUlQ
 
 package sync
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
-	"time"
 
+	"hearts/ai"
 	"hearts/img/uistate"
+	"hearts/img/view"
 	"hearts/logic/card"
+	"hearts/replay"
+	"hearts/store"
 	"hearts/util"
-
-	"v.io/v23/context"
-	"v.io/v23/syncbase"
-)
-
-var (
-	cardType = "classic"
 )
 
+// The log syntax is shared with hearts/replay, which reads what these functions write
 const (
-	Deal      string = "Deal"
-	Pass      string = "Pass"
-	Take      string = "Take"
-	Play      string = "Play"
-	Ready     string = "Ready"
-	TakeTrick string = "TakeTrick"
-	Bar       string = "|"
-	Space     string = " "
-	Colon     string = ":"
-	Dash      string = "-"
-	End       string = "END"
+	Deal      = replay.Deal
+	Pass      = replay.Pass
+	Take      = replay.Take
+	Play      = replay.Play
+	Ready     = replay.Ready
+	TakeTrick = replay.TakeTrick
+	Reveal    = replay.Reveal
+	Timeout   = replay.Timeout
+	Bar       = replay.Bar
+	Space     = replay.Space
+	Colon     = replay.Colon
+	Dash      = replay.Dash
+	End       = replay.End
+	Seed      = replay.Seed
 )
 
-// Formats deal command and sends to Syncbase
-func LogDeal(u *uistate.UIState, playerIndex int, hands [][]*card.Card) bool {
-	for i, h := range hands {
-		key := getKey(playerIndex, u)
-		value := Deal + Bar
-		value += strconv.Itoa(i) + Colon
-		for _, c := range h {
-			value += cardType + Space + c.GetSuit().String() + c.GetFace().String() + Colon
-		}
-		value += End
-		success := logKeyValue(u.Service, u.Ctx, key, value)
-		if !success {
-			return false
+// Formats pass command for the player at playerIndex and sends to Syncbase
+// The cards are sealed to the keys of the passer and the recipient
+func LogPass(u *uistate.UIState, playerIndex int, cards []*card.Card) error {
+	keys := make([]string, 0, 2)
+	for _, p := range []int{playerIndex, u.CurTable.GetPassRecipient(playerIndex)} {
+		key, err := playerHandKey(u, p)
+		if err != nil {
+			return err
 		}
+		keys = append(keys, key)
 	}
-	return true
+	sealed, _, err := replay.Seal(cards, keys)
+	if err != nil {
+		return err
+	}
+	return logCommand(u, playerIndex, &replay.GameCommand{Type: Pass, Player: playerIndex, Sealed: sealed})
 }
 
-// Formats pass command and sends to Syncbase
-func LogPass(u *uistate.UIState, cards []*card.Card) bool {
-	key := getKey(u.CurPlayerIndex, u)
-	value := Pass + Bar + strconv.Itoa(u.CurPlayerIndex) + Colon
-	for _, c := range cards {
-		value += cardType + Space + c.GetSuit().String() + c.GetFace().String() + Colon
+// Formats take command for the player at playerIndex and sends to Syncbase
+func LogTake(u *uistate.UIState, playerIndex int) error {
+	return logCommand(u, playerIndex, &replay.GameCommand{Type: Take, Player: playerIndex})
+}
+
+// Formats play command for the player at playerIndex and sends to Syncbase
+func LogPlay(u *uistate.UIState, playerIndex int, c *card.Card) error {
+	return logCommand(u, playerIndex, &replay.GameCommand{Type: Play, Player: playerIndex, Cards: []*card.Card{c}})
+}
+
+// Formats timeout command, playing c for the player at playerIndex who ran out of time
+// The owner plays for a player on another device which has gone quiet, once their hand has been released,
+// and writes the command as themselves
+func LogTimeout(u *uistate.UIState, playerIndex int, c *card.Card) error {
+	key, value, err := timeoutEntry(u, playerIndex, c)
+	if err != nil {
+		return err
 	}
-	value += End
-	return logKeyValue(u.Service, u.Ctx, key, value)
+	return logKeyValue(u, key, value)
 }
 
-// Formats take command and sends to Syncbase
-func LogTake(u *uistate.UIState) bool {
+// Returns the key and value LogTimeout writes
+func timeoutEntry(u *uistate.UIState, playerIndex int, c *card.Card) (string, string, error) {
+	command := &replay.GameCommand{Type: Timeout, Player: playerIndex, Cards: []*card.Card{c}}
+	if controls(u, playerIndex) || !u.IsOwner || u.CurPlayerIndex < 0 {
+		return commandEntry(u, playerIndex, command)
+	}
+	value, err := command.Encode()
+	if err != nil {
+		return "", "", err
+	}
 	key := getKey(u.CurPlayerIndex, u)
-	value := Take + Bar + strconv.Itoa(u.CurPlayerIndex) + Colon + End
-	return logKeyValue(u.Service, u.Ctx, key, value)
+	value, err = sign(u, key, value)
+	return key, value, err
 }
 
-// Formats play command and sends to Syncbase
-func LogPlay(u *uistate.UIState, c *card.Card) bool {
-	key := getKey(u.CurPlayerIndex, u)
-	value := Play + Bar + strconv.Itoa(u.CurPlayerIndex) + Colon
-	value += cardType + Space + c.GetSuit().String() + c.GetFace().String() + Colon + End
-	return logKeyValue(u.Service, u.Ctx, key, value)
+// Formats the release c, written by the player at playerIndex for a player who stalled, and sends to Syncbase
+func LogRelease(u *uistate.UIState, playerIndex int, c *replay.GameCommand) error {
+	return logCommand(u, playerIndex, c)
 }
 
-// Formats ready command and sends to Syncbase
-func LogReady(u *uistate.UIState) bool {
-	key := getKey(u.CurPlayerIndex, u)
-	value := Ready + Bar + strconv.Itoa(u.CurPlayerIndex) + Colon + End
-	return logKeyValue(u.Service, u.Ctx, key, value)
+// Formats ready command for the player at playerIndex and sends to Syncbase
+func LogReady(u *uistate.UIState, playerIndex int) error {
+	return logCommand(u, playerIndex, &replay.GameCommand{Type: Ready, Player: playerIndex})
 }
 
-func LogTakeTrick(u *uistate.UIState) bool {
-	key := getKey(u.CurPlayerIndex, u)
-	value := TakeTrick + Bar + End
-	return logKeyValue(u.Service, u.Ctx, key, value)
+func LogTakeTrick(u *uistate.UIState, playerIndex int) error {
+	return logCommand(u, playerIndex, &replay.GameCommand{Type: TakeTrick, Player: -1})
+}
+
+// Formats the step of the deal c for the player at playerIndex and sends to Syncbase
+func LogDealStep(u *uistate.UIState, playerIndex int, c *replay.GameCommand) error {
+	return logCommand(u, playerIndex, c)
 }
 
-func LogPlayerNum(u *uistate.UIState) bool {
-	key := fmt.Sprintf("%d/players/%d/player_number", u.GameID, util.UserID)
+// Formats reveal command for the player at playerIndex, carrying the seed they dealt with and the opening of their sealed pass
+func LogReveal(u *uistate.UIState, playerIndex int, openings []string) error {
+	return logCommand(u, playerIndex, &replay.GameCommand{Type: Reveal, Player: playerIndex, Openings: openings})
+}
+
+// Records this device's blessings, so that the owner can let it keep writing once the game starts,
+// and the key its cards are sealed to, then its seat
+func LogPlayerNum(u *uistate.UIState) error {
+	blessings, err := json.Marshal(u.Store.Blessings())
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%d/players/%d/blessings", u.GameID, util.UserID)
+	if err := logKeyValue(u, key, string(blessings)); err != nil {
+		return err
+	}
+	if err := logHandKey(u, util.UserID); err != nil {
+		return err
+	}
+	key = fmt.Sprintf("%d/players/%d/player_number", u.GameID, util.UserID)
 	value := strconv.Itoa(u.CurPlayerIndex)
-	return logKeyValue(u.Service, u.Ctx, key, value)
+	return logKeyValue(u, key, value)
+}
+
+// Seats a computer player at playerIndex. Its cards are sealed to this device, which moves for it
+func LogAIPlayerNum(u *uistate.UIState, playerIndex int) error {
+	if err := logHandKey(u, ai.UserID(playerIndex)); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%d/players/%d/player_number", u.GameID, ai.UserID(playerIndex))
+	value := strconv.Itoa(playerIndex)
+	return logKeyValue(u, key, value)
+}
+
+// Publishes the public half of this device's key as the key of the user userID, and its sign key as theirs
+func logHandKey(u *uistate.UIState, userID int) error {
+	if u.HandKey == nil {
+		return ErrNoHandKey
+	}
+	key := fmt.Sprintf("%d/players/%d/hand_key", u.GameID, userID)
+	if err := logKeyValue(u, key, u.HandKey.Public()); err != nil {
+		return err
+	}
+	key = fmt.Sprintf("%d/players/%d/sign_key", u.GameID, userID)
+	return logKeyValue(u, key, u.HandKey.SignKey())
 }
 
-func LogSettingsName(name string, u *uistate.UIState) bool {
+func LogSettingsName(name string, u *uistate.UIState) error {
 	key := fmt.Sprintf("%d/players/%d/settings_sg", u.GameID, util.UserID)
-	return logKeyValue(u.Service, u.Ctx, key, name)
+	return logKeyValue(u, key, name)
+}
+
+// Moves the current game to status, returning replay.ErrBadTransition if it can't move there from its current status
+// Starting the game closes its log to everyone but the owner and the seated players
+func LogGameStatus(u *uistate.UIState, status replay.Status) error {
+	if err := u.GameStatus.ValidTransition(status); err != nil {
+		return err
+	}
+	if status == replay.Running {
+		perms, err := startedLogPermissions(u)
+		if err != nil {
+			return err
+		}
+		if err := u.Store.SetPermissions(u.LogSG, perms); err != nil {
+			return err
+		}
+	}
+	if err := logKeyValue(u, replay.StatusKey(u.GameID), string(status)); err != nil {
+		return err
+	}
+	u.GameStatus = status
+	return nil
+}
+
+// Sets the time each player has for a move in the current game, in seconds
+func LogTurnLimit(u *uistate.UIState, seconds int) error {
+	return logKeyValue(u, replay.TurnLimitKey(u.GameID), strconv.Itoa(seconds))
 }
 
-func LogGameStart(u *uistate.UIState) bool {
-	key := fmt.Sprintf("%d/status", u.GameID)
-	value := "RUNNING"
-	return logKeyValue(u.Service, u.Ctx, key, value)
+// Records the final scores and winners of the current game
+func LogResult(u *uistate.UIState, scores, winners []int) error {
+	value, err := (&replay.Result{Scores: scores, Winners: winners}).Encode()
+	if err != nil {
+		return err
+	}
+	return logKeyValue(u, replay.ResultKey(u.GameID), value)
 }
 
-// Note: The syntax replicates the way Croupier in Dart/Flutter writes keys.
 func getKey(playerId int, u *uistate.UIState) string {
-	t := time.Now().UnixNano() / 1000000
-	if t < u.LatestTimestamp {
-		t = u.LatestTimestamp + 1
+	return replay.LogKey(u.GameID, u.Clock.Tick(), playerId)
+}
+
+// ErrSpectator is returned when a device watching the game tries to write a command for a seat it doesn't control
+var ErrSpectator = errors.New("Spectators can't make moves")
+
+// Encodes c and writes it to the game log as the player at playerIndex
+// Devices write only for the seats they control
+func logCommand(u *uistate.UIState, playerIndex int, c *replay.GameCommand) error {
+	key, value, err := commandEntry(u, playerIndex, c)
+	if err != nil {
+		return err
 	}
-	key := fmt.Sprintf("%d/log/%d%s%d", u.GameID, t, Dash, playerId)
-	return key
+	return logKeyValue(u, key, value)
 }
 
-func logKeyValue(service syncbase.Service, ctx *context.T, key, value string) bool {
-	return AddKeyValue(service, ctx, key, value)
+// Returns the key and value logCommand writes
+func commandEntry(u *uistate.UIState, playerIndex int, c *replay.GameCommand) (string, string, error) {
+	if !controls(u, playerIndex) {
+		return "", "", ErrSpectator
+	}
+	value, err := c.Encode()
+	if err != nil {
+		return "", "", err
+	}
+	key := getKey(playerIndex, u)
+	value, err = sign(u, key, value)
+	return key, value, err
+}
+
+// Writes value, an encoded command, under the log key key, signed with this device's hand key if it has one
+func logSigned(u *uistate.UIState, key, value string) error {
+	value, err := sign(u, key, value)
+	if err != nil {
+		return err
+	}
+	return logKeyValue(u, key, value)
+}
+
+// Returns value, an encoded command to be written under the log key key, signed with this device's hand key
+// if it has one
+func sign(u *uistate.UIState, key, value string) (string, error) {
+	if u.HandKey == nil {
+		return value, nil
+	}
+	return u.HandKey.Sign(key, value)
+}
+
+// Writes value under key, retrying with backoff while the store fails
+// The key is chosen before the first attempt, so an attempt which failed after all can't leave a second copy behind
+// Returns an error wrapping store.ErrOffline if every attempt fails, and shows the user that they are offline until a write succeeds
+func logKeyValue(u *uistate.UIState, key, value string) error {
+	return putWithRetry(u, util.LogName, key, []byte(value))
+}
+
+// Writes value under key in tableName the way logKeyValue writes to the game log
+func putWithRetry(u *uistate.UIState, tableName, key string, value []byte) error {
+	var ctx store.Context
+	if u.Ctx != nil {
+		ctx = u.Ctx
+	}
+	err := store.Retry(ctx, store.DefaultBackoff, func() error {
+		return u.Store.Put(tableName, key, value)
+	})
+	switch {
+	case err == nil:
+		view.SetOffline(false, u)
+	case errors.Is(err, store.ErrOffline):
+		fmt.Println("PUT ERROR: ", err)
+		view.SetOffline(true, u)
+	}
+	return err
 }
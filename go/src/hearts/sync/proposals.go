@@ -0,0 +1,76 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// proposals.go writes this device's side of the proposals protocol described in schema.md
+// hearts/replay keeps track of the proposal held by every seat; the functions here agree with the lowest proposal
+// on behalf of the seats this device controls, and commit agreed proposals
+// None of the moves this client makes conflict with each other, so it takes part in the proposals other clients make
+// without making any of its own
+
+package sync
+
+import (
+	"fmt"
+
+	"hearts/img/uistate"
+	"hearts/replay"
+)
+
+func logProposal(u *uistate.UIState, playerIndex int, p *replay.Proposal) error {
+	value, err := p.Encode()
+	if err != nil {
+		return err
+	}
+	return logKeyValue(u, replay.ProposalKey(u.GameID, playerIndex), value)
+}
+
+// Returns the seats this device writes for: its own, and those of computer players if it owns the game
+func controlledSeats(u *uistate.UIState) []int {
+	seats := make([]int, 0)
+	if !uistate.IsSpectator(u) {
+		seats = append(seats, u.CurPlayerIndex)
+	}
+	if u.IsOwner {
+		for playerIndex := range u.AIPlayers {
+			seats = append(seats, playerIndex)
+		}
+	}
+	return seats
+}
+
+// Returns true if this device writes for the seat playerIndex
+func controls(u *uistate.UIState, playerIndex int) bool {
+	for _, seat := range controlledSeats(u) {
+		if seat == playerIndex {
+			return true
+		}
+	}
+	return false
+}
+
+func onProposal(e replay.ProposalEvent, u *uistate.UIState) {
+	committer := false
+	for _, playerIndex := range controlledSeats(u) {
+		if playerIndex >= len(e.Proposals) {
+			continue
+		}
+		if !e.Lowest.Equal(e.Proposals[playerIndex]) {
+			if err := logProposal(u, playerIndex, e.Lowest); err != nil {
+				fmt.Println("Proposal error:", err)
+			}
+		}
+		committer = committer || playerIndex == e.Lowest.PlayerNumber
+	}
+	// only the proposer commits, so the command is written to the log once
+	// it is written under a new timestamp, so that it sorts after every entry this device has read
+	if e.Agreed && committer {
+		value, err := e.Lowest.Commit()
+		if err == nil {
+			err = logSigned(u, replay.LogKey(u.GameID, u.Clock.Tick(), e.Lowest.PlayerNumber), value)
+		}
+		if err != nil {
+			fmt.Println("Commit error:", err)
+		}
+	}
+}
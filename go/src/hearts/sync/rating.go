@@ -0,0 +1,28 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// rating.go keeps the ratings of the users this device has played with up to date.
+// Ratings aren't shared: each device rates every user from the games in its own history, which it recorded while
+// replaying their logs, so no user can write a rating of their own choosing. Computer players aren't rated.
+
+package sync
+
+import (
+	"fmt"
+
+	"hearts/ai"
+	"hearts/history"
+	"hearts/img/uistate"
+	"hearts/util"
+)
+
+// Rates every user from the games in this device's history
+func loadRatings(u *uistate.UIState) {
+	games, err := history.New(u.Store, util.SettingsName).Games()
+	if err != nil {
+		fmt.Println("Rating error:", err)
+		return
+	}
+	u.Ratings = history.Ratings(games, ai.IsAI)
+}
@@ -2,11 +2,12 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// server handles advertising (to be fleshed out when discovery is added), and all local syncbase updates
+// server handles advertising (to be fleshed out when discovery is added), and all local store updates
 
 package sync
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -14,15 +15,16 @@ import (
 	"strconv"
 	"strings"
 
+	"hearts/ai"
+	"hearts/history"
 	"hearts/img/uistate"
+	"hearts/logic/table"
+	"hearts/replay"
+	"hearts/store"
 	"hearts/util"
 
 	"v.io/v23/context"
 	"v.io/v23/discovery"
-	"v.io/v23/security"
-	"v.io/v23/security/access"
-	wire "v.io/v23/services/syncbase"
-	"v.io/v23/syncbase"
 	ldiscovery "v.io/x/ref/lib/discovery"
 	"v.io/x/ref/lib/discovery/plugins/mdns"
 	"v.io/x/ref/lib/signals"
@@ -54,67 +56,103 @@ func Advertise(logAddress, settingsAddress, gameStartData string, quit chan bool
 	}
 }
 
-// Puts key and value into the syncbase gamelog table
-func AddKeyValue(service syncbase.Service, ctx *context.T, key, value string) bool {
-	app := service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	table := db.Table(util.LogName)
-	valueByte := []byte(value)
-	err := table.Put(ctx, key, valueByte)
-	if err != nil {
-		fmt.Println("PUT ERROR: ", err)
-		return false
-	}
-	return true
+// Puts key and value into the gamelog table
+func AddKeyValue(s store.Store, key, value string) error {
+	return s.Put(util.LogName, key, []byte(value))
 }
 
-// Creates an app, db, game log table and game settings table in syncbase if they don't already exist
-// Adds appropriate data to settings table
+// Creates the game log table and game settings table if they don't already exist
+// Loads the ID of this device's user unless the config chose one, then adds appropriate data to settings table
 func CreateTables(u *uistate.UIState) {
-	app := u.Service.App(util.AppName)
-	if isThere, err := app.Exists(u.Ctx); err != nil {
-		fmt.Println("APP EXISTS ERROR: ", err)
-	} else if !isThere {
-		if app.Create(u.Ctx, nil) != nil {
-			fmt.Println("APP ERROR: ", err)
+	for _, name := range []string{util.LogName, util.SettingsName} {
+		if err := u.Store.CreateTable(name); err != nil {
+			fmt.Println("TABLE ERROR: ", err)
 		}
 	}
-	db := app.Database(util.DbName, nil)
-	if isThere, err := db.Exists(u.Ctx); err != nil {
-		fmt.Println("DB EXISTS ERROR: ", err)
-	} else if !isThere {
-		if db.Create(u.Ctx, nil) != nil {
-			fmt.Println("DB ERROR: ", err)
+	if util.UserID == 0 {
+		userID, err := personalUserID(u.Store)
+		if err != nil {
+			fmt.Println("USER ID ERROR: ", err)
 		}
+		util.UserID = userID
 	}
-	logTable := db.Table(util.LogName)
-	if isThere, err := logTable.Exists(u.Ctx); err != nil {
-		fmt.Println("TABLE EXISTS ERROR: ", err)
-	} else if !isThere {
-		if logTable.Create(u.Ctx, nil) != nil {
-			fmt.Println("TABLE ERROR: ", err)
-		}
+	// Add user settings data to represent this player, unless they already have some
+	key := settingsKey(util.UserID)
+	rows, err := u.Store.Scan(util.SettingsName, key)
+	if err != nil {
+		fmt.Println("SCAN ERROR: ", err)
 	}
-	settingsTable := db.Table(util.SettingsName)
-	if isThere, err := settingsTable.Exists(u.Ctx); err != nil {
-		fmt.Println("TABLE EXISTS ERROR: ", err)
-	} else if !isThere {
-		if settingsTable.Create(u.Ctx, nil) != nil {
-			fmt.Println("TABLE ERROR: ", err)
+	for _, row := range rows {
+		var settingsMap map[string]interface{}
+		if row.Key == key && json.Unmarshal(row.Value, &settingsMap) == nil {
+			u.UserData[util.UserID] = settingsMap
+			return
 		}
 	}
-	// Add user settings data to represent this player
 	settingsMap := make(map[string]interface{})
 	settingsMap["userID"] = util.UserID
-	settingsMap["avatar"] = util.UserAvatar
-	settingsMap["name"] = util.UserName
-	settingsMap["color"] = util.UserColor
+	settingsMap[uistate.Avatar] = util.UserAvatar
+	settingsMap[uistate.Name] = util.UserName
+	settingsMap[uistate.Color] = util.UserColor
 	u.UserData[util.UserID] = settingsMap
 	value, err := json.Marshal(settingsMap)
 	if err != nil {
 		fmt.Println("WE HAVE A HUGE PROBLEM:", err)
 	}
-	settingsTable.Put(u.Ctx, fmt.Sprintf("users/%d/settings", util.UserID), value)
+	if err := u.Store.Put(util.SettingsName, key, value); err != nil {
+		fmt.Println("PUT ERROR: ", err)
+	}
+}
+
+// Returns the settings row of the user userID, which their settings syncgroup shares
+func settingsKey(userID int) string {
+	return fmt.Sprintf("users/%d/settings", userID)
+}
+
+// Changes one of this user's settings, such as their uistate.Name, and writes them back so other players see the change
+func SetProfile(u *uistate.UIState, setting string, value interface{}) error {
+	settingsMap := make(map[string]interface{})
+	for k, v := range u.UserData[util.UserID] {
+		settingsMap[k] = v
+	}
+	settingsMap[setting] = value
+	data, err := json.Marshal(settingsMap)
+	if err != nil {
+		return err
+	}
+	if err := putWithRetry(u, util.SettingsName, settingsKey(util.UserID), data); err != nil {
+		return err
+	}
+	u.UserData[util.UserID] = settingsMap
+	return nil
+}
+
+// the settings row holding the ID of the user of this device, which no syncgroup shares
+const personalKey = "users/personal"
+
+// Returns the ID of the user of this device, creating and saving a new random one on its first run
+// If the saved ID can't be read, a new one is returned for this run only, along with the error
+func personalUserID(s store.Store) (int, error) {
+	rows, err := s.Scan(util.SettingsName, personalKey)
+	if err != nil {
+		return newUserID(), err
+	}
+	for _, row := range rows {
+		if row.Key == personalKey {
+			userID, err := strconv.Atoi(string(row.Value))
+			if err != nil || userID <= 0 {
+				return newUserID(), fmt.Errorf("bad user ID %q", row.Value)
+			}
+			return userID, nil
+		}
+	}
+	userID := newUserID()
+	return userID, s.Put(util.SettingsName, personalKey, []byte(strconv.Itoa(userID)))
+}
+
+// Returns a random user ID. IDs are positive, since 0 marks an empty seat and computer players have negative IDs
+func newUserID() int {
+	return rand.Intn(1000000) + 1
 }
 
 // Creates a new gamelog syncgroup
@@ -129,39 +167,20 @@ func CreateLogSyncgroup(u *uistate.UIState) (string, string) {
 	gameMap["playerNumber"] = 0
 	gameMap["gameID"] = gameID
 	gameMap["ownerID"] = util.UserID
+	gameMap["numPlayers"] = util.NumPlayers
+	gameMap["turnLimit"] = util.TurnLimit
 	value, err := json.Marshal(gameMap)
 	if err != nil {
 		fmt.Println("WE HAVE A HUGE PROBLEM:", err)
 	}
 	// Create gamelog syncgroup
 	logSGName := fmt.Sprintf("%s/croupier/%s/%%%%sync/gaming-%d", util.MountPoint, util.SBName, gameID)
-	allAccess := access.AccessList{In: []security.BlessingPattern{"..."}}
-	permissions := access.Permissions{
-		"Admin":   allAccess,
-		"Write":   allAccess,
-		"Read":    allAccess,
-		"Resolve": allAccess,
-		"Debug":   allAccess,
-	}
-	logPref := wire.TableRow{util.LogName, fmt.Sprintf("%d", u.GameID)}
-	logPrefs := []wire.TableRow{logPref}
-	tables := []string{util.MountPoint + "/croupier"}
-	logSpec := wire.SyncgroupSpec{
-		Description: "croupier syncgroup",
-		Perms:       permissions,
-		Prefixes:    logPrefs,
-		MountTables: tables,
-		IsPrivate:   false,
-	}
-	myInfoCreator := wire.SyncgroupMemberInfo{8, true}
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
-	logSG := db.Syncgroup(logSGName)
-	err = logSG.Create(u.Ctx, logSpec, myInfoCreator)
+	logPrefs := []store.Prefix{{Table: util.LogName, Row: fmt.Sprintf("%d", u.GameID)}}
+	err = u.Store.CreateSyncgroup(logSGName, logPrefs, openLogPermissions(u))
 	if err != nil {
 		fmt.Println("SYNCGROUP CREATE ERROR: ", err)
 		fmt.Println("JOINING INSTEAD...")
-		_, err2 := logSG.Join(u.Ctx, myInfoCreator)
+		err2 := u.Store.JoinSyncgroup(logSGName, true)
 		if err2 != nil {
 			fmt.Println("SYNCGROUP JOIN ERROR: ", err2)
 			return string(value), ""
@@ -171,7 +190,7 @@ func CreateLogSyncgroup(u *uistate.UIState) (string, string) {
 	} else {
 		fmt.Println("Syncgroup created")
 		if logSGName != u.LogSG {
-			ResetGame(logSGName, true, u)
+			ResetGame(logSGName, true, util.NumPlayers, u)
 		}
 		return string(value), logSGName
 	}
@@ -180,34 +199,13 @@ func CreateLogSyncgroup(u *uistate.UIState) (string, string) {
 // Creates a new user settings syncgroup
 func CreateSettingsSyncgroup(u *uistate.UIState) string {
 	fmt.Println("Creating Settings Syncgroup")
-	allAccess := access.AccessList{In: []security.BlessingPattern{"..."}}
-	permissions := access.Permissions{
-		"Admin":   allAccess,
-		"Write":   allAccess,
-		"Read":    allAccess,
-		"Resolve": allAccess,
-		"Debug":   allAccess,
-	}
-	tables := []string{util.MountPoint + "/croupier"}
-	myInfoCreator := wire.SyncgroupMemberInfo{8, true}
-	app := u.Service.App(util.AppName)
-	db := app.Database(util.DbName, nil)
 	settingsSGName := fmt.Sprintf("%s/croupier/%s/%%%%sync/discovery-%d", util.MountPoint, util.SBName, util.UserID)
-	settingsPref := wire.TableRow{util.SettingsName, fmt.Sprintf("users/%d", util.UserID)}
-	settingsPrefs := []wire.TableRow{settingsPref}
-	settingsSpec := wire.SyncgroupSpec{
-		Description: "croupier syncgroup",
-		Perms:       permissions,
-		Prefixes:    settingsPrefs,
-		MountTables: tables,
-		IsPrivate:   false,
-	}
-	settingsSG := db.Syncgroup(settingsSGName)
-	err := settingsSG.Create(u.Ctx, settingsSpec, myInfoCreator)
+	settingsPrefs := []store.Prefix{{Table: util.SettingsName, Row: fmt.Sprintf("users/%d", util.UserID)}}
+	err := u.Store.CreateSyncgroup(settingsSGName, settingsPrefs, settingsPermissions(u))
 	if err != nil {
 		fmt.Println("SYNCGROUP CREATE ERROR: ", err)
 		fmt.Println("JOINING INSTEAD...")
-		_, err2 := settingsSG.Join(u.Ctx, myInfoCreator)
+		err2 := u.Store.JoinSyncgroup(settingsSGName, true)
 		if err2 != nil {
 			fmt.Println("SYNCGROUP JOIN ERROR: ", err2)
 			return ""
@@ -220,20 +218,84 @@ func CreateSettingsSyncgroup(u *uistate.UIState) string {
 	}
 }
 
-func ResetGame(logName string, creator bool, u *uistate.UIState) {
+// Returns the permissions of a game log until the game starts: its owner administers it, and anyone may join and take a seat
+func openLogPermissions(u *uistate.UIState) store.Permissions {
+	return store.Permissions{
+		Admin: u.Store.Blessings(),
+		Write: []string{store.AllUsers},
+		Read:  []string{store.AllUsers},
+	}
+}
+
+// Returns the permissions of a game log once the game has started
+// Only the owner and the seated players may write to it, and everyone else may only watch
+// Players are let in by the blessings they recorded, but only those a member of the syncgroup authenticated with
+func startedLogPermissions(u *uistate.UIState) (store.Permissions, error) {
+	members, err := u.Store.MemberBlessings(u.LogSG)
+	if err != nil {
+		return store.Permissions{}, err
+	}
+	owner := u.Store.Blessings()
+	writers := append([]string{}, owner...)
+	for _, userID := range u.PlayerData {
+		writers = append(writers, store.Authenticated(u.Blessings[userID], members)...)
+	}
+	return store.Permissions{
+		Admin: owner,
+		Write: writers,
+		Read:  []string{store.AllUsers},
+	}, nil
+}
+
+// Returns the permissions of this user's settings: only this device may change them, and anyone may read them
+func settingsPermissions(u *uistate.UIState) store.Permissions {
+	return store.Permissions{
+		Admin: u.Store.Blessings(),
+		Write: u.Store.Blessings(),
+		Read:  []string{store.AllUsers},
+	}
+}
+
+// Starts following the game whose log is shared by the syncgroup logName, at a table for numPlayers
+func ResetGame(logName string, creator bool, numPlayers int, u *uistate.UIState) {
 	u.M.Lock()
 	defer u.M.Unlock()
 	go sendTrueIfExists(u.GameChan)
+	go sendTrueIfExists(u.TimerChan)
 	u.PlayerData = make(map[int]int)
+	u.AIPlayers = make(map[int]ai.Strategy)
+	u.AIPending = make(map[int]bool)
+	u.Spectators = make(map[int]bool)
+	u.Blessings = make(map[int][]string)
+	u.HandKeys = make(map[int]string)
+	u.RevealPending = make(map[int]bool)
+	u.DealPending = make(map[int]bool)
+	u.GodView = false
+	u.TurnLimit = 0
+	u.TurnPlayer = -1
+	u.Releases = make(map[int]*replay.GameCommand)
 	u.CurPlayerIndex = -1
+	u.GameStatus = replay.NoStatus
 	u.LogSG = logName
-	writeLogAddr(logName, creator)
-	u.CurTable.NewGame()
-	tmp := strings.Split(logName, "-")
-	gameID, _ := strconv.Atoi(tmp[len(tmp)-1])
-	u.GameID = gameID
+	writeLogAddr(logName, creator, numPlayers)
+	if len(u.CurTable.GetPlayers()) == numPlayers {
+		u.CurTable.NewGame()
+	} else {
+		u.CurTable = table.InitializeGame(numPlayers, u.CurTable.GetRules())
+	}
+	u.NumPlayers = numPlayers
+	u.RoundScores = make([]int, numPlayers)
+	u.GameID = logGameID(logName)
+	u.Record = &history.Game{GameID: u.GameID}
+	key, err := loadHandKey(u)
+	if err != nil {
+		fmt.Println("Hand key error:", err)
+	}
+	u.HandKey = key
 	u.GameChan = make(chan bool)
 	go UpdateGame(u.GameChan, u)
+	u.TimerChan = make(chan bool)
+	go TimeTurns(u.TimerChan, u)
 }
 
 func sendTrueIfExists(ch chan bool) {
@@ -242,12 +304,47 @@ func sendTrueIfExists(ch chan bool) {
 	}
 }
 
-func writeLogAddr(logName string, creator bool) {
+// Returns the ID of the game whose log is shared by the syncgroup logName
+func logGameID(logName string) int {
+	tmp := strings.Split(logName, "-")
+	gameID, _ := strconv.Atoi(tmp[len(tmp)-1])
+	return gameID
+}
+
+// Returns the name of the game log syncgroup saved by writeLogAddr, whether this device created the game,
+// and the number of players in it, which is the classic number for a game saved without one
+// Returns false if no game has been saved, or the game saved is for a number of players no table seats
+func readLogAddr() (string, bool, int, bool) {
+	file, err := os.Open(util.AddrFile)
+	if err != nil {
+		return "", false, 0, false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || scanner.Text() == "" {
+		return "", false, 0, false
+	}
+	logName := scanner.Text()
+	scanner.Scan()
+	creator, _ := strconv.ParseBool(scanner.Text())
+	numPlayers := uistate.ClassicNumPlayers
+	if scanner.Scan() {
+		n, err := strconv.Atoi(scanner.Text())
+		if err != nil || !util.ValidNumPlayers(n) {
+			return "", false, 0, false
+		}
+		numPlayers = n
+	}
+	return logName, creator, numPlayers, true
+}
+
+func writeLogAddr(logName string, creator bool, numPlayers int) {
 	file, err := os.OpenFile(util.AddrFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		fmt.Println("err:", err)
 	}
 	fmt.Fprintln(file, logName)
 	fmt.Fprintln(file, creator)
+	fmt.Fprintln(file, numPlayers)
 	file.Close()
 }
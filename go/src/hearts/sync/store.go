@@ -0,0 +1,247 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// store.go implements store.Store on top of the Syncbase app and database Croupier uses
+
+package sync
+
+import (
+	"fmt"
+
+	"hearts/store"
+	"hearts/util"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/security"
+	"v.io/v23/security/access"
+	wire "v.io/v23/services/syncbase"
+	"v.io/v23/syncbase"
+	"v.io/v23/verror"
+)
+
+type syncbaseStore struct {
+	service syncbase.Service
+	ctx     *context.T
+}
+
+type syncbaseWatch struct {
+	stream  syncbase.WatchStream
+	current store.Change
+}
+
+// Returns a store backed by service. The app and database are created if they don't already exist
+func NewSyncbaseStore(service syncbase.Service, ctx *context.T) store.Store {
+	s := &syncbaseStore{service: service, ctx: ctx}
+	app := service.App(util.AppName)
+	if isThere, err := app.Exists(ctx); err != nil {
+		fmt.Println("APP EXISTS ERROR: ", err)
+	} else if !isThere {
+		if err := app.Create(ctx, nil); err != nil {
+			fmt.Println("APP ERROR: ", err)
+		}
+	}
+	db := s.db()
+	if isThere, err := db.Exists(ctx); err != nil {
+		fmt.Println("DB EXISTS ERROR: ", err)
+	} else if !isThere {
+		if err := db.Create(ctx, nil); err != nil {
+			fmt.Println("DB ERROR: ", err)
+		}
+	}
+	return s
+}
+
+func (s *syncbaseStore) db() syncbase.Database {
+	return s.service.App(util.AppName).Database(util.DbName, nil)
+}
+
+func (s *syncbaseStore) CreateTable(name string) error {
+	t := s.db().Table(name)
+	isThere, err := t.Exists(s.ctx)
+	if err != nil || isThere {
+		return err
+	}
+	return t.Create(s.ctx, nil)
+}
+
+func (s *syncbaseStore) Put(table, key string, value []byte) error {
+	return accessError(s.db().Table(table).Put(s.ctx, key, value))
+}
+
+func (s *syncbaseStore) Scan(table, prefix string) ([]store.Row, error) {
+	stream := s.db().Table(table).Scan(s.ctx, syncbase.Prefix(prefix))
+	rows := make([]store.Row, 0)
+	for stream.Advance() {
+		var value []byte
+		if err := stream.Value(&value); err != nil {
+			return nil, err
+		}
+		rows = append(rows, store.Row{Key: stream.Key(), Value: value})
+	}
+	return rows, stream.Err()
+}
+
+func (s *syncbaseStore) Watch(table, prefix string) (store.WatchStream, error) {
+	db := s.db()
+	resumeMarker, err := db.GetResumeMarker(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := db.Watch(s.ctx, table, prefix, resumeMarker)
+	if err != nil {
+		return nil, err
+	}
+	return &syncbaseWatch{stream: stream}, nil
+}
+
+// The rows the syncgroup shares are given the same permissions as the syncgroup itself
+func (s *syncbaseStore) CreateSyncgroup(name string, prefixes []store.Prefix, perms store.Permissions) error {
+	rows := make([]wire.TableRow, len(prefixes))
+	for i, p := range prefixes {
+		rows[i] = wire.TableRow{TableName: p.Table, Row: p.Row}
+	}
+	spec := wire.SyncgroupSpec{
+		Description: "croupier syncgroup",
+		Perms:       accessPermissions(perms),
+		Prefixes:    rows,
+		MountTables: []string{util.MountPoint + "/croupier"},
+		IsPrivate:   false,
+	}
+	myInfoCreator := wire.SyncgroupMemberInfo{SyncPriority: 8, IsServer: true}
+	if err := s.db().Syncgroup(name).Create(s.ctx, spec, myInfoCreator); err != nil {
+		return accessError(err)
+	}
+	return s.setPrefixPermissions(rows, perms)
+}
+
+func (s *syncbaseStore) JoinSyncgroup(name string, creator bool) error {
+	myInfoJoiner := wire.SyncgroupMemberInfo{SyncPriority: 8, IsServer: creator}
+	_, err := s.db().Syncgroup(name).Join(s.ctx, myInfoJoiner)
+	return accessError(err)
+}
+
+func (s *syncbaseStore) SetPermissions(name string, perms store.Permissions) error {
+	sg := s.db().Syncgroup(name)
+	spec, version, err := sg.GetSpec(s.ctx)
+	if err != nil {
+		return accessError(err)
+	}
+	spec.Perms = accessPermissions(perms)
+	if err := sg.SetSpec(s.ctx, spec, version); err != nil {
+		return accessError(err)
+	}
+	return s.setPrefixPermissions(spec.Prefixes, perms)
+}
+
+func (s *syncbaseStore) setPrefixPermissions(rows []wire.TableRow, perms store.Permissions) error {
+	rowPerms := access.Permissions{
+		"Admin": accessList(perms.Admin),
+		"Write": accessList(perms.Write),
+		"Read":  accessList(perms.Read),
+	}
+	for _, r := range rows {
+		if err := s.db().Table(r.TableName).SetPrefixPermissions(s.ctx, syncbase.Prefix(r.Row), rowPerms); err != nil {
+			return accessError(err)
+		}
+	}
+	return nil
+}
+
+func (s *syncbaseStore) Members(name string) ([]string, error) {
+	members, err := s.db().Syncgroup(name).GetMembers(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(members))
+	for member := range members {
+		names = append(names, member)
+	}
+	return names, nil
+}
+
+func (s *syncbaseStore) MemberBlessings(name string) ([]string, error) {
+	members, err := s.db().Syncgroup(name).GetMembers(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	blessings := make([]string, 0)
+	for _, info := range members {
+		blessings = append(blessings, info.BlessingNames...)
+	}
+	return blessings, nil
+}
+
+func (s *syncbaseStore) Syncgroups() ([]string, error) {
+	return s.db().GetSyncgroupNames(s.ctx)
+}
+
+func (s *syncbaseStore) Blessings() []string {
+	return security.DefaultBlessingNames(v23.GetPrincipal(s.ctx))
+}
+
+// Returns the permissions of this device's Syncbase service
+// Anyone may reach it to join the syncgroups it hosts, but only this device may administer it
+func ServicePermissions(ctx *context.T) access.Permissions {
+	own := security.DefaultBlessingNames(v23.GetPrincipal(ctx))
+	return accessPermissions(store.Permissions{
+		Admin: own,
+		Write: own,
+		Read:  []string{store.AllUsers},
+	})
+}
+
+// Returns perms as Syncbase access lists. Resolving a name needs the same access as reading it
+func accessPermissions(perms store.Permissions) access.Permissions {
+	return access.Permissions{
+		"Admin":   accessList(perms.Admin),
+		"Write":   accessList(perms.Write),
+		"Read":    accessList(perms.Read),
+		"Resolve": accessList(perms.Read),
+		"Debug":   accessList(perms.Admin),
+	}
+}
+
+func accessList(patterns []string) access.AccessList {
+	in := make([]security.BlessingPattern, len(patterns))
+	for i, p := range patterns {
+		in[i] = security.BlessingPattern(p)
+	}
+	return access.AccessList{In: in}
+}
+
+// Returns err, wrapping store.ErrNoAccess if Syncbase refused access
+func accessError(err error) error {
+	if err != nil && verror.ErrorID(err) == verror.ErrNoAccess.ID {
+		return fmt.Errorf("%w: %v", store.ErrNoAccess, err)
+	}
+	return err
+}
+
+func (w *syncbaseWatch) Advance() bool {
+	if !w.stream.Advance() {
+		return false
+	}
+	c := w.stream.Change()
+	w.current = store.Change{
+		Table:     c.Table,
+		Key:       c.Row,
+		Delete:    c.ChangeType == syncbase.DeleteChange,
+		Continued: c.Continued,
+	}
+	if !w.current.Delete {
+		if err := c.Value(&w.current.Value); err != nil {
+			fmt.Println("Value error:", err)
+		}
+	}
+	return true
+}
+
+func (w *syncbaseWatch) Change() store.Change {
+	return w.current
+}
+
+func (w *syncbaseWatch) Cancel() {
+	w.stream.Cancel()
+}
@@ -0,0 +1,162 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// timer.go times each move of a game with a turn limit, and plays for a player who runs out of time.
+// A player's own device plays their lowest legal card once their time is up. If that device has gone quiet too,
+// the other players release their shares of the player's sealed hand once they've had twice their time,
+// and the owner plays for them once the hand is released.
+
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"hearts/img/uistate"
+	"hearts/img/view"
+	"hearts/replay"
+)
+
+// Checks the current move against the turn limit every second, until quit receives a value
+func TimeTurns(quit chan bool, u *uistate.UIState) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			checkTurn(u)
+		}
+	}
+}
+
+// Returns the player who must play a card now, or -1 if no one is waiting on a card
+func turnPlayer(u *uistate.UIState) int {
+	t := u.CurTable
+	if u.GameStatus != replay.Running {
+		return -1
+	}
+	p := t.WhoseTurn()
+	if p < 0 || t.TrickOver() || !t.AllDonePassing() || (u.SequentialPhases && !t.AllDoneTaking()) {
+		return -1
+	}
+	if len(t.GetPlayers()[p].GetHand()) == 0 {
+		return -1
+	}
+	return p
+}
+
+// Starts timing the move of the player whose turn it now is, if it has just become their turn,
+// and readies the releases of their hand by the seats this device controls, in case they stall
+func trackTurn(r *replay.Replayer, u *uistate.UIState) {
+	u.M.Lock()
+	defer u.M.Unlock()
+	if p := turnPlayer(u); p != u.TurnPlayer {
+		u.TurnPlayer = p
+		u.TurnStart = time.Now()
+		u.TimeoutLogged = false
+		u.ReleaseLogged = false
+		u.Releases = make(map[int]*replay.GameCommand)
+		if p < 0 || controls(u, p) {
+			return
+		}
+		for _, playerIndex := range controlledSeats(u) {
+			if c := r.ReleaseHand(p, playerIndex); c != nil {
+				u.Releases[playerIndex] = c
+			}
+		}
+	}
+}
+
+// a log entry this device owes once the move it is timing runs out
+type dueEntry struct {
+	key, value string
+	timeout    bool // true for the Timeout playing for the player whose turn it is
+}
+
+// Updates the countdown, and plays for the player whose turn it is if they have run out of time
+// The turn and the table are read under u.M, which the watch goroutine holds while it changes them. The entries
+// are written once it is released, since a write which fails reloads the view, which takes u.M itself
+func checkTurn(u *uistate.UIState) {
+	u.M.Lock()
+	timed := u.TurnLimit != 0 && u.TurnPlayer >= 0
+	u.M.Unlock()
+	if !timed {
+		return
+	}
+	view.RefreshCountdown(u)
+	u.M.Lock()
+	turnStart := u.TurnStart
+	entries := dueEntries(u)
+	u.M.Unlock()
+	for _, e := range entries {
+		if err := logKeyValue(u, e.key, e.value); err != nil {
+			fmt.Println("Turn timer error:", err)
+			continue
+		}
+		if e.timeout {
+			u.M.Lock()
+			// the move may have ended while the entry was written
+			if u.TurnStart == turnStart {
+				u.TimeoutLogged = true
+			}
+			u.M.Unlock()
+		}
+	}
+}
+
+// Returns the entries this device owes for the move it is timing: the releases of the hand of a player who has
+// stalled, and the Timeout of a player who has run out of time. Releases are only returned once
+// u.M must be held
+func dueEntries(u *uistate.UIState) []dueEntry {
+	entries := make([]dueEntry, 0)
+	if u.TurnLimit == 0 || u.TurnPlayer < 0 {
+		return entries
+	}
+	p := u.TurnPlayer
+	elapsed := time.Since(u.TurnStart)
+	if elapsed >= 2*u.TurnLimit && !u.ReleaseLogged {
+		entries = append(entries, releaseEntries(u)...)
+	}
+	stalled := u.IsOwner && elapsed >= 2*u.TurnLimit && !hasHiddenCards(p, u)
+	if u.TimeoutLogged || elapsed < u.TurnLimit || (p != u.CurPlayerIndex && !stalled) {
+		return entries
+	}
+	c := u.CurTable.LowestLegalPlay(p)
+	if c == nil {
+		return entries
+	}
+	key, value, err := timeoutEntry(u, p, c)
+	if err != nil {
+		fmt.Println("Timeout error:", err)
+		return entries
+	}
+	return append(entries, dueEntry{key: key, value: value, timeout: true})
+}
+
+// Returns the releases of the hand of the player whose turn it is, who has stalled
+func releaseEntries(u *uistate.UIState) []dueEntry {
+	entries := make([]dueEntry, 0, len(u.Releases))
+	for playerIndex, c := range u.Releases {
+		key, value, err := commandEntry(u, playerIndex, c)
+		if err != nil {
+			fmt.Println("Release error:", err)
+			continue
+		}
+		entries = append(entries, dueEntry{key: key, value: value})
+	}
+	u.ReleaseLogged = true
+	return entries
+}
+
+// Returns true if this device can't see every card in the hand of the player at playerIndex
+func hasHiddenCards(playerIndex int, u *uistate.UIState) bool {
+	for _, c := range u.CurTable.GetPlayers()[playerIndex].GetHand() {
+		if c.Hidden() {
+			return true
+		}
+	}
+	return false
+}
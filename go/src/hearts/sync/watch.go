@@ -11,6 +11,7 @@ package sync
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -20,30 +21,26 @@ import (
 
 	"golang.org/x/mobile/exp/sprite"
 
+	"hearts/ai"
 	"hearts/img/direction"
 	"hearts/img/reposition"
 	"hearts/img/uistate"
 	"hearts/img/view"
 	"hearts/logic/card"
+	"hearts/replay"
 	"hearts/sound"
+	"hearts/store"
 	"hearts/util"
-
-	"v.io/v23/syncbase"
 )
 
 func UpdateSettings(u *uistate.UIState) {
-	scanner := ScanData(util.SettingsName, "users", u)
-	for {
-		if updateExists := scanner.Advance(); updateExists {
-			key := scanner.Key()
-			var value []byte
-			if err := scanner.Value(&value); err != nil {
-				fmt.Println("Value error:", err)
-			}
-			handleSettingsUpdate(key, value, u)
-		} else {
-			break
-		}
+	loadRatings(u)
+	rows, err := ScanData(util.SettingsName, "users", u)
+	if err != nil {
+		fmt.Println("ScanData error:", err)
+	}
+	for _, row := range rows {
+		handleSettingsUpdate(row.Key, row.Value, u)
 	}
 	stream, err := WatchData(util.SettingsName, "users", u)
 	if err != nil {
@@ -52,15 +49,10 @@ func UpdateSettings(u *uistate.UIState) {
 		for {
 			if updateExists := stream.Advance(); updateExists {
 				c := stream.Change()
-				if c.ChangeType == syncbase.PutChange {
-					key := c.Row
-					var value []byte
-					if err := c.Value(&value); err != nil {
-						fmt.Println("Value error:", err)
-					}
-					handleSettingsUpdate(key, value, u)
+				if !c.Delete {
+					handleSettingsUpdate(c.Key, c.Value, u)
 				} else {
-					fmt.Println("Unexpected ChangeType: ", c.ChangeType)
+					fmt.Println("Unexpected delete: ", c.Key)
 				}
 			}
 		}
@@ -68,6 +60,14 @@ func UpdateSettings(u *uistate.UIState) {
 }
 
 func handleSettingsUpdate(key string, value []byte, u *uistate.UIState) {
+	if key == personalKey {
+		return
+	}
+	parts := strings.Split(key, "/")
+	// each device rates every user itself, so ratings other devices write of their own users are ignored
+	if len(parts) == 3 && parts[2] == "rating" {
+		return
+	}
 	var valueMap map[string]interface{}
 	err := json.Unmarshal(value, &valueMap)
 	if err != nil {
@@ -92,37 +92,49 @@ func UpdateGame(quit chan bool, u *uistate.UIState) {
 	}
 	fmt.Fprintf(file, fmt.Sprintf("\n***NEW GAME: %d\n", u.GameID))
 	defer file.Close()
-	scanner := ScanData(util.LogName, fmt.Sprintf("%d", u.GameID), u)
+	rows, err := ScanData(util.LogName, fmt.Sprintf("%d", u.GameID), u)
+	if err != nil {
+		fmt.Println("ScanData error:", err)
+	}
 	m := make(map[string][]byte)
 	keys := make([]string, 0)
-	for scanner.Advance() {
-		k := scanner.Key()
-		var v []byte
-		if err := scanner.Value(&v); err != nil {
-			fmt.Println("Value error:", err)
-		}
-		id := strings.Split(k, "/")[0]
+	for _, row := range rows {
+		id := strings.Split(row.Key, "/")[0]
 		if id == fmt.Sprintf("%d", u.GameID) {
-			m[k] = v
-			keys = append(keys, k)
+			m[row.Key] = row.Value
+			keys = append(keys, row.Key)
 		}
 	}
 	sort.Sort(scanSorter(keys))
+	r := replay.New(u.CurTable, u.SequentialPhases)
+	r.SetHandKey(u.HandKey)
 	for _, key := range keys {
 		select {
 		case <-quit:
 			return
 		default:
 			value := m[key]
-			handleGameUpdate(file, key, value, u)
+			handleGameUpdate(file, r, key, value, u)
 		}
 	}
+	resumePaused(u)
+	// a game which had already started is shown as it now stands, whichever views reading its log passed through
+	if u.GameStatus.Started() {
+		loadGameView(u)
+	}
+	// computer players only move once the existing log has been read, so they don't repeat moves already in it
+	// cards are revealed first, so that a computer player's reveal is logged before it gets ready for the next round
+	revealCards(r, u)
+	runAI(u)
+	dealCards(r, u)
+	// moves are only timed from when this device saw them begin, so none are timed while reading the existing log
+	trackTurn(r, u)
 	stream, err2 := WatchData(util.LogName, fmt.Sprintf("%d", u.GameID), u)
 	fmt.Println("STARTING WATCH FOR GAME", u.GameID)
 	if err2 != nil {
 		fmt.Println("WatchData error:", err2)
 	}
-	updateBlock := make([]syncbase.WatchChange, 0)
+	updateBlock := make([]store.Change, 0)
 	for {
 		if updateExists := stream.Advance(); updateExists {
 			c := stream.Change()
@@ -134,79 +146,117 @@ func UpdateGame(quit chan bool, u *uistate.UIState) {
 					case <-quit:
 						return
 					default:
-						if c.ChangeType == syncbase.PutChange {
-							key := c.Row
-							var value []byte
-							if err := c.Value(&value); err != nil {
-								fmt.Println("Value error:", err)
-							}
-							handleGameUpdate(file, key, value, u)
+						if !c.Delete {
+							handleGameUpdate(file, r, c.Key, c.Value, u)
+							revealCards(r, u)
+							runAI(u)
+							dealCards(r, u)
+							trackTurn(r, u)
 						} else {
-							fmt.Println("Unexpected ChangeType: ", c.ChangeType)
+							fmt.Println("Unexpected delete: ", c.Key)
 						}
 					}
 				}
-				updateBlock = make([]syncbase.WatchChange, 0)
+				updateBlock = make([]store.Change, 0)
 			}
 		}
 	}
 }
 
-func handleGameUpdate(file *os.File, key string, value []byte, u *uistate.UIState) {
+func handleGameUpdate(file *os.File, r *replay.Replayer, key string, value []byte, u *uistate.UIState) {
 	curTime := time.Now().UnixNano() / 1000000
 	valueStr := string(value)
 	fmt.Fprintf(file, fmt.Sprintf("key: %s\n", key))
 	fmt.Fprintf(file, fmt.Sprintf("value: %s\n", valueStr))
 	fmt.Fprintf(file, fmt.Sprintf("time: %v\n", curTime))
-	tmp := strings.Split(key, "/")
-	if len(tmp) == 3 {
-		keyTime, _ := strconv.ParseInt(strings.Split(tmp[2], "-")[0], 10, 64)
-		if keyTime > u.LatestTimestamp {
-			u.LatestTimestamp = keyTime
-		}
-		fmt.Fprintf(file, fmt.Sprintf("diff: %d milliseconds\n\n", curTime-keyTime))
+	keyTime, playerInt, isLog := replay.ParseLogKey(key)
+	if isLog {
+		u.Clock.Observe(keyTime)
+		fmt.Fprintf(file, fmt.Sprintf("clock: %d\n\n", keyTime))
+		delete(u.AIPending, playerInt)
 	} else {
 		fmt.Fprintf(file, "\n")
 	}
 	fmt.Println(key, valueStr)
-	keyType := strings.Split(key, "/")[1]
-	switch keyType {
-	case "log":
-		updateType := strings.Split(valueStr, "|")[0]
-		switch updateType {
-		case Deal:
-			onDeal(valueStr, u)
-		case Pass:
-			onPass(valueStr, u)
-		case Take:
-			onTake(valueStr, u)
-		case Play:
-			onPlay(valueStr, u)
-		case TakeTrick:
-			onTakeTrick(valueStr, u)
-		case Ready:
-			onReady(valueStr, u)
-		}
-	case "players":
-		switch strings.Split(key, "/")[3] {
-		case "player_number":
-			onPlayerNum(key, valueStr, u)
-		case "settings_sg":
-			onSettings(key, valueStr, u)
+	// the turn timer reads the table under u.M
+	u.M.Lock()
+	events, err := r.Apply(key, valueStr)
+	u.M.Unlock()
+	if err != nil {
+		fmt.Println("Replay error:", err, key, valueStr)
+		return
+	}
+	for _, e := range events {
+		switch e := e.(type) {
+		case replay.PlayerNumEvent:
+			onPlayerNum(e, u)
+		case replay.SettingsEvent:
+			onSettings(e, u)
+		case replay.BlessingsEvent:
+			u.Blessings[e.UserID] = e.Blessings
+		case replay.HandKeyEvent:
+			u.HandKeys[e.UserID] = e.Key
+		case replay.StatusEvent:
+			onStatus(e, u)
+		case replay.DealStepEvent:
+			delete(u.DealPending, e.Player)
+		case replay.DealEvent:
+			recordDeal(u)
+		case replay.NewRoundEvent:
+			onNewRound(e, u)
+		case replay.PassEvent:
+			onPass(e, u)
+		case replay.TakeEvent:
+			onTake(e, u)
+		case replay.FirstPlayerEvent:
+			onFirstPlayer(e, u)
+		case replay.PlayEvent:
+			onPlay(e, u)
+		case replay.TakeTrickEvent:
+			onTakeTrick(e, u)
+		case replay.ReadyEvent:
+			onReady(e, u)
+		case replay.ProposalEvent:
+			onProposal(e, u)
+		case replay.TurnLimitEvent:
+			u.TurnLimit = e.Limit
+		case replay.RevealEvent:
+			delete(u.RevealPending, e.Player)
+		case replay.ReleaseEvent:
+			fmt.Fprintf(file, "release: player %d\n\n", e.Player)
+		case replay.AuditEvent:
+			// the violations the audit found come before it, as ViolationEvents of their own
+			fmt.Fprintf(file, "audit: %d violations\n\n", len(e.Violations))
+		case replay.ViolationEvent:
+			// the entry was not applied, so there is nothing to update on screen
+			fmt.Fprintf(file, "violation: player %d, %s by player %d: %v\n\n", e.Writer, e.Command, e.Player, e.Err)
+			fmt.Println("Protocol violation:", e.Writer, e.Command, e.Player, e.Err)
 		}
 	}
 }
 
-func onPlayerNum(key, value string, u *uistate.UIState) {
-	userID, _ := strconv.Atoi(strings.Split(key, "/")[2])
-	playerNum, _ := strconv.Atoi(value)
-	if playerNum >= 0 && playerNum < 4 {
-		u.PlayerData[playerNum] = userID
-		u.CurTable.GetPlayers()[playerNum].SetDoneScoring(true)
+func onPlayerNum(e replay.PlayerNumEvent, u *uistate.UIState) {
+	// the turn timer reads the seats this device controls under u.M
+	u.M.Lock()
+	if e.PlayerNum >= len(u.CurTable.GetPlayers()) {
+		u.Spectators[e.UserID] = true
+	}
+	if e.PlayerNum >= 0 && e.PlayerNum < len(u.CurTable.GetPlayers()) {
+		delete(u.Spectators, e.UserID)
+		u.PlayerData[e.PlayerNum] = e.UserID
+		if ai.IsAI(e.UserID) {
+			onAIPlayerNum(e.PlayerNum, u)
+		} else {
+			delete(u.AIPlayers, e.PlayerNum)
+		}
 	}
-	if playerNum == u.CurPlayerIndex && userID != util.UserID {
+	if e.UserID == util.UserID {
+		// this device's own seat, which a player rejoining a game takes back
+		u.CurPlayerIndex = e.PlayerNum
+	} else if e.PlayerNum == u.CurPlayerIndex {
 		u.CurPlayerIndex = -1
 	}
+	u.M.Unlock()
 	if u.CurView == uistate.Arrange {
 		view.LoadArrangeView(u)
 		if u.CurTable.AllReadyForNewRound() && u.IsOwner {
@@ -222,53 +272,56 @@ func onPlayerNum(key, value string, u *uistate.UIState) {
 	}
 }
 
-func onSettings(key, value string, u *uistate.UIState) {
-	JoinSettingsSyncgroup(value, u)
+// Shows the current game: the arrange view until it starts, then the player's hand, or the table for a device without a seat
+func loadGameView(u *uistate.UIState) {
+	switch {
+	case !u.GameStatus.Started():
+		view.LoadArrangeView(u)
+	case u.CurPlayerIndex >= 0 && u.CurPlayerIndex < u.NumPlayers:
+		view.LoadPassOrTakeOrPlay(u)
+	default:
+		view.LoadTableView(u)
+	}
+}
+
+func onSettings(e replay.SettingsEvent, u *uistate.UIState) {
+	JoinSettingsSyncgroup(e.Name, u)
 }
 
-func onDeal(value string, u *uistate.UIState) {
-	playerInt, curCards := parsePlayerAndCards(value, u)
-	u.CurTable.GetPlayers()[playerInt].SetHand(curCards)
-	if u.CurTable.AllDoneDealing() {
-		u.CurTable.NewRound()
-		if u.CurPlayerIndex >= 0 && u.CurPlayerIndex < u.NumPlayers {
-			view.LoadPassOrTakeOrPlay(u)
-		} else {
-			view.LoadTableView(u)
-		}
+func onStatus(e replay.StatusEvent, u *uistate.UIState) {
+	u.GameStatus = e.Status
+	// players waiting for an abandoned game to start go back to looking for another
+	if e.Status == replay.Abandoned && !u.IsOwner && u.CurView == uistate.Arrange {
+		u.ScanChan = make(chan bool)
+		go ScanForSG(u.Ctx, u.ScanChan, u)
+		view.LoadDiscoveryView(u)
+	}
+	// spectators may see every hand once the game is over
+	if e.Status.Over() && uistate.IsSpectator(u) && u.CurView == uistate.Table {
+		view.LoadTableView(u)
+	}
+}
+
+func onNewRound(e replay.NewRoundEvent, u *uistate.UIState) {
+	if u.CurPlayerIndex >= 0 && u.CurPlayerIndex < u.NumPlayers {
+		view.LoadPassOrTakeOrPlay(u)
+	} else {
+		view.LoadTableView(u)
 	}
 }
 
-func onPass(value string, u *uistate.UIState) {
-	// logic
-	playerInt, curCards := parsePlayerAndCards(value, u)
-	var receivingPlayer int
-	switch u.CurTable.GetDir() {
-	case direction.Right:
-		receivingPlayer = (playerInt + 3) % u.NumPlayers
-	case direction.Left:
-		receivingPlayer = (playerInt + 1) % u.NumPlayers
-	case direction.Across:
-		receivingPlayer = (playerInt + 2) % u.NumPlayers
-	}
-	for _, c := range curCards {
-		u.CurTable.GetPlayers()[playerInt].RemoveFromHand(c)
-	}
-	u.CurTable.GetPlayers()[playerInt].SetPassedFrom(curCards)
-	u.CurTable.GetPlayers()[receivingPlayer].SetPassedTo(curCards)
-	u.CurTable.GetPlayers()[playerInt].SetDonePassing(true)
-	// UI
+func onPass(e replay.PassEvent, u *uistate.UIState) {
 	if u.CurView == uistate.Table {
 		quit := make(chan bool)
 		u.AnimChans = append(u.AnimChans, quit)
-		reposition.AnimateTableCardPass(curCards, receivingPlayer, quit, u)
+		reposition.AnimateTableCardPass(e.Cards, e.Recipient, quit, u)
 		view.LoadTableView(u)
 	} else if u.CurView == uistate.Take {
 		if u.SequentialPhases {
 			if u.CurTable.AllDonePassing() {
 				view.LoadTakeView(u)
 			}
-		} else if u.CurPlayerIndex == receivingPlayer {
+		} else if u.CurPlayerIndex == e.Recipient {
 			view.LoadTakeView(u)
 		}
 	} else if u.CurView == uistate.Play && u.CurTable.AllDonePassing() {
@@ -276,78 +329,48 @@ func onPass(value string, u *uistate.UIState) {
 	}
 }
 
-func onTake(value string, u *uistate.UIState) {
-	// logic
-	playerInt, _ := parsePlayerAndCards(value, u)
-	p := u.CurTable.GetPlayers()[playerInt]
-	passed := p.GetPassedTo()
-	for _, c := range passed {
-		p.AddToHand(c)
-	}
-	u.CurTable.GetPlayers()[playerInt].SetDoneTaking(true)
-	if u.SequentialPhases {
-		if u.CurTable.AllDoneTaking() {
-			for _, player := range u.CurTable.GetPlayers() {
-				if player.HasTwoOfClubs() {
-					u.CurTable.SetFirstPlayer(player.GetPlayerIndex())
-				}
-			}
-			// UI
-			if u.CurView == uistate.Play {
-				view.LoadPlayView(true, u)
-			}
-		}
-	} else if p.HasTwoOfClubs() {
-		u.CurTable.SetFirstPlayer(p.GetPlayerIndex())
-		// UI
-		if u.CurView == uistate.Play && u.CurPlayerIndex != playerInt {
-			view.LoadPlayView(true, u)
-		}
-	}
-	// UI
+func onTake(e replay.TakeEvent, u *uistate.UIState) {
 	if u.CurView == uistate.Table {
 		quit := make(chan bool)
 		u.AnimChans = append(u.AnimChans, quit)
-		reposition.AnimateTableCardTake(passed, u.CurTable.GetPlayers()[playerInt], quit, u)
+		reposition.AnimateTableCardTake(e.Cards, u.CurTable.GetPlayers()[e.Player], quit, u)
 		view.LoadTableView(u)
 	}
 }
 
-func onPlay(value string, u *uistate.UIState) {
-	// logic
-	playerInt, curCards := parsePlayerAndCards(value, u)
-	playedCard := curCards[0]
-	u.CurTable.GetPlayers()[playerInt].RemoveFromHand(playedCard)
-	u.CurTable.SetPlayedCard(playedCard, playerInt)
-	u.CurTable.GetPlayers()[playerInt].SetDonePlaying(true)
-	trickOver := u.CurTable.TrickOver()
-	var recipient int
-	if trickOver {
-		recipient = u.CurTable.GetTrickRecipient()
-	}
-	// UI
+func onFirstPlayer(e replay.FirstPlayerEvent, u *uistate.UIState) {
+	if u.CurView == uistate.Play && (u.SequentialPhases || u.CurPlayerIndex != e.Player) {
+		view.LoadPlayView(true, u)
+	}
+}
+
+func onPlay(e replay.PlayEvent, u *uistate.UIState) {
+	// a card dropped on the play target by a player who ran out of time goes back to their hand
+	if e.TimedOut && e.Player == u.CurPlayerIndex {
+		u.CardToPlay = nil
+	}
 	if u.CurView == uistate.Table {
 		sound.PlaySound(0, u)
 		quit := make(chan bool)
 		u.AnimChans = append(u.AnimChans, quit)
-		reposition.AnimateTableCardPlay(playedCard, playerInt, quit, u)
+		reposition.AnimateTableCardPlay(e.Card, e.Player, quit, u)
 		reposition.SetTableDropColors(u)
-		if trickOver {
-			// display take trick button
+		if e.TrickOver && !uistate.IsSpectator(u) {
+			// display take trick button, which spectators can't use
 			b := u.Buttons["takeTrick"]
 			u.Eng.SetSubTex(b.GetNode(), b.GetImage())
 			b.SetHidden(false)
 		}
 	} else if u.CurView == uistate.Split {
-		if playerInt != u.CurPlayerIndex {
+		if e.Player != u.CurPlayerIndex {
 			quit := make(chan bool)
 			u.AnimChans = append(u.AnimChans, quit)
-			reposition.AnimateSplitCardPlay(playedCard, playerInt, quit, u)
+			reposition.AnimateSplitCardPlay(e.Card, e.Player, quit, u)
 		}
 		reposition.SetSplitDropColors(u)
 		view.LoadSplitView(true, u)
-		if trickOver {
-			if recipient == u.CurPlayerIndex {
+		if e.TrickOver {
+			if e.Recipient == u.CurPlayerIndex {
 				// display take trick button
 				b := u.Buttons["takeTrick"]
 				u.Eng.SetSubTex(b.GetNode(), b.GetImage())
@@ -355,8 +378,8 @@ func onPlay(value string, u *uistate.UIState) {
 			}
 		} else if u.CardToPlay != nil && u.CurTable.WhoseTurn() == u.CurPlayerIndex {
 			ch := make(chan bool)
-			if err := PlayCard(ch, u.CurPlayerIndex, u); err != "" {
-				view.ChangePlayMessage(err, u)
+			if err := PlayCard(ch, u.CurPlayerIndex, u); err != nil {
+				view.ChangePlayMessage(err.Error(), u)
 				RemoveCardFromTarget(u.CardToPlay, u)
 				// add card back to hand
 				reposition.ResetCardPosition(u.CardToPlay, u.Eng)
@@ -367,12 +390,15 @@ func onPlay(value string, u *uistate.UIState) {
 			u.Eng.SetSubTex(u.BackgroundImgs[0].GetNode(), emptyTex)
 			u.BackgroundImgs[0].SetHidden(true)
 		}
-	} else if u.CurView == uistate.Play && u.CurPlayerIndex != playerInt {
+	} else if u.CurView == uistate.Play && e.TimedOut && u.CurPlayerIndex == e.Player {
+		view.LoadPlayView(true, u)
+		view.ChangePlayMessage("Out of time", u)
+	} else if u.CurView == uistate.Play && u.CurPlayerIndex != e.Player {
 		view.LoadPlayView(true, u)
 		if u.CardToPlay != nil && u.CurTable.WhoseTurn() == u.CurPlayerIndex {
 			ch := make(chan bool)
-			if err := PlayCard(ch, u.CurPlayerIndex, u); err != "" {
-				view.ChangePlayMessage(err, u)
+			if err := PlayCard(ch, u.CurPlayerIndex, u); err != nil {
+				view.ChangePlayMessage(err.Error(), u)
 				RemoveCardFromTarget(u.CardToPlay, u)
 				// add card back to hand
 				reposition.ResetCardPosition(u.CardToPlay, u.Eng)
@@ -393,81 +419,85 @@ func onPlay(value string, u *uistate.UIState) {
 	}
 }
 
-func onTakeTrick(value string, u *uistate.UIState) {
-	trickCards := u.CurTable.GetTrick()
-	recipient := u.CurTable.GetTrickRecipient()
-	roundOver := u.CurTable.SendTrick(recipient)
-	if roundOver {
-		u.RoundScores, u.Winners = u.CurTable.EndRound()
+func onTakeTrick(e replay.TakeTrickEvent, u *uistate.UIState) {
+	if e.RoundOver {
+		u.RoundScores, u.Winners = e.RoundScores, e.Winners
+		recordRound(e, u)
+		if len(e.Winners) > 0 && u.IsOwner {
+			finishGame(e.Winners, u)
+		}
 	}
-	// UI
 	if u.CurView == uistate.Table {
 		sound.PlaySound(1, u)
 		var emptyTex sprite.SubTex
 		u.Eng.SetSubTex(u.Buttons["takeTrick"].GetNode(), emptyTex)
 		u.Buttons["takeTrick"].SetHidden(true)
-		var trickDir direction.Direction
-		switch recipient {
-		case 0:
-			trickDir = direction.Down
-		case 1:
-			trickDir = direction.Left
-		case 2:
-			trickDir = direction.Across
-		case 3:
-			trickDir = direction.Right
-		}
+		trickDir, _, _ := direction.Seat(e.Recipient, u.NumPlayers)
 		quit := make(chan bool)
 		u.AnimChans = append(u.AnimChans, quit)
-		reposition.AnimateTableCardTakeTrick(trickCards, trickDir, quit, u)
+		reposition.AnimateTableCardTakeTrick(e.Cards, trickDir, quit, u)
 		reposition.SetTableDropColors(u)
 		view.SetNumTricksTable(u)
 	} else if u.CurView == uistate.Split {
 		var emptyTex sprite.SubTex
 		u.Eng.SetSubTex(u.Buttons["takeTrick"].GetNode(), emptyTex)
 		u.Buttons["takeTrick"].SetHidden(true)
-		if roundOver {
+		if e.RoundOver {
 			view.LoadScoreView(u)
 		} else {
-			var trickDir direction.Direction
-			switch recipient {
-			case u.CurPlayerIndex:
+			if e.Recipient == u.CurPlayerIndex {
 				sound.PlaySound(0, u)
-				trickDir = direction.Down
-			case (u.CurPlayerIndex + 1) % u.NumPlayers:
-				trickDir = direction.Left
-			case (u.CurPlayerIndex + 2) % u.NumPlayers:
-				trickDir = direction.Across
-			case (u.CurPlayerIndex + 3) % u.NumPlayers:
-				trickDir = direction.Right
 			}
+			// the trick goes off towards the edge of the screen the recipient sits at, seen from this player
+			trickDir, _, _ := direction.Seat((e.Recipient-u.CurPlayerIndex+u.NumPlayers)%u.NumPlayers, u.NumPlayers)
 			quit := make(chan bool)
 			u.AnimChans = append(u.AnimChans, quit)
-			reposition.AnimateTableCardTakeTrick(trickCards, trickDir, quit, u)
+			reposition.AnimateTableCardTakeTrick(e.Cards, trickDir, quit, u)
 			view.LoadSplitView(true, u)
 		}
 	} else if u.CurView == uistate.Play {
-		if roundOver {
+		if e.RoundOver {
 			view.LoadScoreView(u)
 		} else {
-			if recipient == u.CurPlayerIndex {
+			if e.Recipient == u.CurPlayerIndex {
 				sound.PlaySound(0, u)
 			}
 			view.LoadPlayView(true, u)
 		}
 	}
-	// logic
-	if len(u.Winners) > 0 {
-		u.CurTable.NewGame()
+}
+
+// Records the current game as finished, with its final scores
+// Nothing is written while the log is read back from before the game's status, when the game isn't running yet
+func finishGame(winners []int, u *uistate.UIState) {
+	if u.GameStatus != replay.Running {
+		return
+	}
+	scores := make([]int, 0)
+	for _, p := range u.CurTable.GetPlayers() {
+		scores = append(scores, p.GetScore())
+	}
+	if err := LogResult(u, scores, winners); err != nil {
+		fmt.Println("Result error:", err)
+		return
+	}
+	if err := LogGameStatus(u, replay.Finished); err != nil {
+		fmt.Println("Status error:", err)
+	}
+}
+
+// Resumes the current game if it is paused and this device owns it, as happens when the owner rejoins it
+func resumePaused(u *uistate.UIState) {
+	if !u.IsOwner || u.GameStatus != replay.Paused {
+		return
+	}
+	if err := LogGameStatus(u, replay.Running); err != nil {
+		fmt.Println("Status error:", err)
 	}
 }
 
-func onReady(value string, u *uistate.UIState) {
-	// logic
-	playerInt, _ := parsePlayerAndCards(value, u)
-	u.CurTable.GetPlayers()[playerInt].SetDoneScoring(true)
-	// UI
-	if u.CurTable.AllReadyForNewRound() && u.IsOwner {
+func onReady(e replay.ReadyEvent, u *uistate.UIState) {
+	if e.AllReady && u.IsOwner {
 		if u.CurView == uistate.Arrange {
 			b := u.Buttons["start"]
 			u.Eng.SetSubTex(b.GetNode(), b.GetImage())
@@ -478,34 +508,19 @@ func onReady(value string, u *uistate.UIState) {
 				u.SGChan = nil
 			}
 		} else if u.CurView == uistate.Score {
-			newHands := u.CurTable.Deal()
-			successDeal := LogDeal(u, u.CurPlayerIndex, newHands)
-			for !successDeal {
-				successDeal = LogDeal(u, u.CurPlayerIndex, newHands)
+			// the players of a finished game have chosen to play another at the same table
+			// the first player then starts the deal, as they do for every round
+			if u.GameStatus == replay.Finished {
+				if err := LogGameStatus(u, replay.Running); err != nil {
+					fmt.Println("Status error:", err)
+				}
 			}
 		}
 	}
 }
 
-func parsePlayerAndCards(value string, u *uistate.UIState) (int, []*card.Card) {
-	updateContents := strings.Split(value, "|")[1]
-	playerIntPlusCards := strings.Split(updateContents, ":")
-	playerInt, _ := strconv.Atoi(playerIntPlusCards[0])
-	cardList := u.CurTable.GetAllCards()
-	curCards := make([]*card.Card, 0)
-	for i := 1; i < len(playerIntPlusCards)-1; i++ {
-		cardInfo := playerIntPlusCards[i]
-		cardSuitFace := strings.Split(cardInfo, " ")[1]
-		cardSuit := card.ConvertToSuit(string(cardSuitFace[0]))
-		cardFace := card.ConvertToFace(string(cardSuitFace[1:]))
-		cardIndex := int(cardSuit*13) + int(cardFace) - 2
-		curCards = append(curCards, cardList[cardIndex])
-	}
-	return playerInt, curCards
-}
-
 // Used to sort an array of watch changes
-type updateSorter []syncbase.WatchChange
+type updateSorter []store.Change
 
 // Returns the length of the array
 func (us updateSorter) Len() int {
@@ -519,8 +534,8 @@ func (us updateSorter) Swap(i, j int) {
 
 // Compares two changes-- one card is less than another if it has an earlier timestamp
 func (us updateSorter) Less(i, j int) bool {
-	iKey := us[i].Row
-	jKey := us[j].Row
+	iKey := us[i].Key
+	jKey := us[j].Key
 	return iKey < jKey
 }
 
@@ -542,38 +557,31 @@ func (ss scanSorter) Less(i, j int) bool {
 	return iKey < jKey
 }
 
-func PlayCard(ch chan bool, playerId int, u *uistate.UIState) string {
+// ErrNoCardPlayed is returned by PlayCard when no card has been dropped on the play target
+var ErrNoCardPlayed = errors.New("No card has been played")
+
+func PlayCard(ch chan bool, playerId int, u *uistate.UIState) error {
 	c := u.DropTargets[0].GetCardHere()
 	if c == nil {
-		return "No card has been played"
+		return ErrNoCardPlayed
 	}
 	// checks to make sure that:
 	// -player has not already played a card this round
 	// -all players have passed cards
 	// -the play is in the right order
 	// -the play is valid given game logic
-	if u.CurTable.GetPlayers()[playerId].GetDonePlaying() {
-		return "You have already played a card in this trick"
-	}
-	if !u.CurTable.AllDonePassing() {
-		return "Not all players have passed their cards"
-	}
-	if !u.CurTable.ValidPlayOrder(playerId) {
-		return "It is not your turn"
+	if err := u.CurTable.ValidPlay(c, playerId); err != nil {
+		return err
 	}
-	if err := u.CurTable.ValidPlayLogic(c, playerId); err != "" {
+	if err := LogPlay(u, playerId, c); err != nil {
 		return err
 	}
 	sound.PlaySound(1, u)
-	success := LogPlay(u, c)
-	for !success {
-		success = LogPlay(u, c)
-	}
 	// no animation when in split view
 	if u.CurView == uistate.Play {
 		reposition.AnimateHandCardPlay(ch, c, u)
 	}
-	return ""
+	return nil
 }
 
 func RemoveCardFromTarget(c *card.Card, u *uistate.UIState) bool {
@@ -20,8 +20,10 @@ import (
 	"hearts/img/uistate"
 	"hearts/img/view"
 	"hearts/logic/card"
+	"hearts/replay"
 	"hearts/sound"
 	"hearts/sync"
+	"hearts/util"
 )
 
 var (
@@ -118,6 +120,24 @@ func OnTouch(t touch.Event, u *uistate.UIState) {
 		case touch.TypeEnd:
 			endClickSplit(t, u)
 		}
+	case uistate.Profile:
+		switch t.Type {
+		case touch.TypeBegin:
+			beginClickProfile(t, u)
+		case touch.TypeMove:
+			moveClickProfile(t, u)
+		case touch.TypeEnd:
+			endClickProfile(t, u)
+		}
+	case uistate.Stats:
+		switch t.Type {
+		case touch.TypeBegin:
+			beginClickStats(t, u)
+		case touch.TypeMove:
+			moveClickStats(t, u)
+		case touch.TypeEnd:
+			endClickStats(t, u)
+		}
 	case uistate.Score:
 		switch t.Type {
 		case touch.TypeBegin:
@@ -154,6 +174,14 @@ func endClickDiscovery(t touch.Event, u *uistate.UIState) {
 			gameStartData, logName := sync.CreateLogSyncgroup(u)
 			settingsName := sync.CreateSettingsSyncgroup(u)
 			if logName != "" && settingsName != "" {
+				if err := sync.LogGameStatus(u, replay.Created); err != nil {
+					fmt.Println("Status error:", err)
+				}
+				if util.TurnLimit > 0 {
+					if err := sync.LogTurnLimit(u, util.TurnLimit); err != nil {
+						fmt.Println("Turn limit error:", err)
+					}
+				}
 				sync.LogSettingsName(settingsName, u)
 				u.ScanChan <- true
 				u.ScanChan = nil
@@ -161,14 +189,36 @@ func endClickDiscovery(t touch.Event, u *uistate.UIState) {
 				go sync.Advertise(logName, settingsName, gameStartData, u.SGChan, u.Ctx)
 				view.LoadArrangeView(u)
 			}
+		} else if button == u.Buttons["profile"] {
+			u.ScanChan <- true
+			u.ScanChan = nil
+			view.LoadProfileView(u)
+		} else if button == u.Buttons["stats"] {
+			u.ScanChan <- true
+			u.ScanChan = nil
+			view.LoadStatsView(u)
+		} else if button == u.Buttons["rejoinGame"] {
+			u.ScanChan <- true
+			u.ScanChan = nil
+			if !sync.ResumeGame(u) {
+				fmt.Println("Failed to rejoin")
+				u.ScanChan = make(chan bool)
+				go sync.ScanForSG(u.Ctx, u.ScanChan, u)
+				view.LoadDiscoveryView(u)
+			}
 		} else {
 			for _, b := range u.Buttons {
 				if button == b {
 					s := strings.Split(b.GetInfo(), "|")
 					logAddr := s[0]
 					creator, _ := strconv.ParseBool(s[1])
+					numPlayers, err := strconv.Atoi(s[2])
+					if err != nil || !util.ValidNumPlayers(numPlayers) {
+						fmt.Println("Not joining a game for", s[2], "players")
+						continue
+					}
 					fmt.Println("TRYING TO JOIN:", logAddr)
-					success := sync.JoinLogSyncgroup(logAddr, creator, u)
+					success := sync.JoinLogSyncgroup(logAddr, creator, numPlayers, u)
 					if success {
 						sgName := sync.CreateSettingsSyncgroup(u)
 						if sgName != "" {
@@ -186,6 +236,108 @@ func endClickDiscovery(t touch.Event, u *uistate.UIState) {
 	}
 }
 
+func beginClickProfile(t touch.Event, u *uistate.UIState) {
+	buttonList := findClickedButton(t, u)
+	for _, button := range buttonList {
+		pressButton(button, u)
+	}
+}
+
+func moveClickProfile(t touch.Event, u *uistate.UIState) {
+	curPressed := findClickedButton(t, u)
+	alreadyPressed := getPressed(u)
+	if len(alreadyPressed) > 0 && len(curPressed) == 0 {
+		unpressButtons(u)
+	}
+}
+
+func endClickProfile(t touch.Event, u *uistate.UIState) {
+	pressed := unpressButtons(u)
+	for _, button := range pressed {
+		if button == u.Buttons["exit"] {
+			u.ScanChan = make(chan bool)
+			go sync.ScanForSG(u.Ctx, u.ScanChan, u)
+			view.LoadDiscoveryView(u)
+			return
+		}
+		name, _ := u.UserData[util.UserID][uistate.Name].(string)
+		var err error
+		for key, b := range u.Buttons {
+			if b != button {
+				continue
+			}
+			s := strings.Split(key, "-")
+			switch s[0] {
+			case "avatar":
+				i, _ := strconv.Atoi(s[1])
+				err = sync.SetProfile(u, uistate.Avatar, uistate.Avatars[i])
+			case "color":
+				i, _ := strconv.Atoi(s[1])
+				err = sync.SetProfile(u, uistate.Color, uistate.Colors[i])
+			case "key", "space", "backspace":
+				if newName := typeName(name, key); newName != name {
+					err = sync.SetProfile(u, uistate.Name, newName)
+				}
+			}
+		}
+		if err != nil {
+			fmt.Println("Profile error:", err)
+		}
+		view.LoadProfileView(u)
+	}
+}
+
+// Returns name after pressing the key of the keyboard named key: "key-<letter>", "space" or "backspace"
+// The first letter of each word is capitalized, and the name never grows past uistate.MaxNameLength
+// nor starts with a space
+func typeName(name, key string) string {
+	switch {
+	case key == "backspace":
+		if len(name) > 0 {
+			return name[:len(name)-1]
+		}
+		return name
+	case len(name) >= uistate.MaxNameLength:
+		return name
+	case key == "space":
+		if len(name) == 0 || strings.HasSuffix(name, " ") {
+			return name
+		}
+		return name + " "
+	}
+	letter := strings.TrimPrefix(key, "key-")
+	if len(name) > 0 && !strings.HasSuffix(name, " ") {
+		letter = strings.ToLower(letter)
+	}
+	return name + letter
+}
+
+func beginClickStats(t touch.Event, u *uistate.UIState) {
+	buttonList := findClickedButton(t, u)
+	for _, button := range buttonList {
+		pressButton(button, u)
+	}
+}
+
+func moveClickStats(t touch.Event, u *uistate.UIState) {
+	curPressed := findClickedButton(t, u)
+	alreadyPressed := getPressed(u)
+	if len(alreadyPressed) > 0 && len(curPressed) == 0 {
+		unpressButtons(u)
+	}
+}
+
+func endClickStats(t touch.Event, u *uistate.UIState) {
+	pressed := unpressButtons(u)
+	for _, button := range pressed {
+		if button == u.Buttons["exit"] {
+			u.ScanChan = make(chan bool)
+			go sync.ScanForSG(u.Ctx, u.ScanChan, u)
+			view.LoadDiscoveryView(u)
+		}
+	}
+}
+
 func beginClickArrange(t touch.Event, u *uistate.UIState) {
 	buttonList := findClickedButton(t, u)
 	for _, b := range buttonList {
@@ -195,7 +347,7 @@ func beginClickArrange(t touch.Event, u *uistate.UIState) {
 			if u.CurTable.AllReadyForNewRound() {
 				pressButton(b, u)
 			}
-		} else if u.CurPlayerIndex < 0 || u.Debug {
+		} else if u.CurPlayerIndex < 0 || u.Debug || u.IsOwner {
 			for _, button := range u.Buttons {
 				if b == button {
 					pressButton(b, u)
@@ -221,6 +373,12 @@ func endClickArrange(t touch.Event, u *uistate.UIState) {
 				u.SGChan <- true
 				u.SGChan = nil
 			}
+			// a game its owner leaves before it starts can't be started by anyone else
+			if u.IsOwner {
+				if err := sync.LogGameStatus(u, replay.Abandoned); err != nil {
+					fmt.Println("Status error:", err)
+				}
+			}
 			u.IsOwner = false
 			u.DiscGroups = make(map[string]*uistate.DiscStruct)
 			u.ScanChan = make(chan bool)
@@ -228,21 +386,21 @@ func endClickArrange(t touch.Event, u *uistate.UIState) {
 			view.LoadDiscoveryView(u)
 		} else if b == u.Buttons["start"] {
 			if u.CurTable.AllReadyForNewRound() {
-				successStart := sync.LogGameStart(u)
-				for !successStart {
-					successStart = sync.LogGameStart(u)
-				}
-				newHands := u.CurTable.Deal()
-				successDeal := sync.LogDeal(u, u.CurPlayerIndex, newHands)
-				for !successDeal {
-					successDeal = sync.LogDeal(u, u.CurPlayerIndex, newHands)
+				// the first player starts the deal once the status comes back through the log
+				if err := sync.LogGameStatus(u, replay.Running); err != nil {
+					fmt.Println("Status error:", err)
 				}
 			}
 		} else {
 			for key, button := range u.Buttons {
-				if b == button && (u.CurPlayerIndex < 0 || u.Debug) {
+				if b == button && u.CurPlayerIndex >= 0 && u.IsOwner && !u.Debug && key != "joinTable" {
+					// once seated, the owner fills empty seats with computer players
+					playerNum, _ := strconv.Atoi(strings.Split(key, "-")[1])
+					sync.AddAIPlayer(playerNum, u)
+				} else if b == button && (u.CurPlayerIndex < 0 || u.Debug) {
 					if key == "joinTable" {
-						u.CurPlayerIndex = 4
+						// the first number past the seats marks a spectator
+						u.CurPlayerIndex = len(u.CurTable.GetPlayers())
 						sync.LogPlayerNum(u)
 					} else {
 						playerNum := strings.Split(key, "-")[1]
@@ -258,7 +416,7 @@ func endClickArrange(t touch.Event, u *uistate.UIState) {
 func beginClickTable(t touch.Event, u *uistate.UIState) {
 	buttonList := findClickedButton(t, u)
 	for _, b := range buttonList {
-		if b == u.Buttons["takeTrick"] {
+		if b == u.Buttons["takeTrick"] || b == u.Buttons["godView"] {
 			pressButton(b, u)
 		} else {
 			handleDebugButtonClick(b, u)
@@ -277,8 +435,13 @@ func moveClickTable(t touch.Event, u *uistate.UIState) {
 func endClickTable(t touch.Event, u *uistate.UIState) {
 	pressed := unpressButtons(u)
 	for _, b := range pressed {
-		if b == u.Buttons["takeTrick"] {
-			sync.LogTakeTrick(u)
+		if b == u.Buttons["takeTrick"] && !uistate.IsSpectator(u) {
+			if err := sync.LogTakeTrick(u, u.CurPlayerIndex); err != nil {
+				fmt.Println("Take trick error:", err)
+			}
+		} else if b == u.Buttons["godView"] {
+			u.GodView = !u.GodView
+			view.LoadTableView(u)
 		}
 	}
 }
@@ -465,8 +628,8 @@ func endClickPlay(t touch.Event, u *uistate.UIState) {
 			if dropCardOnTarget(u.CurCard, t, u) {
 				if u.CurTable.WhoseTurn() == u.CurPlayerIndex {
 					ch := make(chan bool)
-					if err := sync.PlayCard(ch, u.CurPlayerIndex, u); err != "" {
-						view.ChangePlayMessage(err, u)
+					if err := sync.PlayCard(ch, u.CurPlayerIndex, u); err != nil {
+						view.ChangePlayMessage(err.Error(), u)
 						sync.RemoveCardFromTarget(u.CurCard, u)
 						u.CardToPlay = nil
 						// add card back to hand
@@ -517,7 +680,9 @@ func endClickPlay(t touch.Event, u *uistate.UIState) {
 			u.AnimChans = append(u.AnimChans, quit)
 			go func() {
 				onDone := func() {
-					sync.LogTakeTrick(u)
+					if err := sync.LogTakeTrick(u, u.CurPlayerIndex); err != nil {
+						fmt.Println("Take trick error:", err)
+					}
 				}
 				reposition.SwitchOnChan(ch, quit, onDone, u)
 			}()
@@ -580,8 +745,8 @@ func endClickSplit(t touch.Event, u *uistate.UIState) {
 			if dropCardHere(u.CurCard, u.DropTargets[0], t, u) {
 				if u.CurTable.WhoseTurn() == u.CurPlayerIndex {
 					ch := make(chan bool)
-					if err := sync.PlayCard(ch, u.CurPlayerIndex, u); err != "" {
-						view.ChangePlayMessage(err, u)
+					if err := sync.PlayCard(ch, u.CurPlayerIndex, u); err != nil {
+						view.ChangePlayMessage(err.Error(), u)
 						if sync.RemoveCardFromTarget(u.CurCard, u) {
 							u.CardToPlay = nil
 							u.BackgroundImgs[0].GetNode().Arranger = nil
@@ -618,7 +783,9 @@ func endClickSplit(t touch.Event, u *uistate.UIState) {
 	unpress := true
 	for _, b := range pressed {
 		if b == u.Buttons["takeTrick"] {
-			sync.LogTakeTrick(u)
+			if err := sync.LogTakeTrick(u, u.CurPlayerIndex); err != nil {
+				view.ChangePlayMessage(err.Error(), u)
+			}
 		} else if b == u.Buttons["toggleSplit"] {
 			unpress = false
 		}
@@ -646,9 +813,9 @@ func moveClickScore(t touch.Event, u *uistate.UIState) {
 func endClickScore(t touch.Event, u *uistate.UIState) {
 	pressed := unpressButtons(u)
 	if len(pressed) > 0 {
-		success := sync.LogReady(u)
-		for !success {
-			sync.LogReady(u)
+		if err := sync.LogReady(u, u.CurPlayerIndex); err != nil {
+			fmt.Println("Ready error:", err)
+			return
 		}
 		view.LoadWaitingView(u)
 	}
@@ -701,14 +868,13 @@ func passCards(ch chan bool, playerId int, u *uistate.UIState) bool {
 		}
 	}
 	// if the pass is not valid, don't pass any cards
-	if !u.CurTable.ValidPass(cardsPassed) || u.CurTable.GetPlayers()[playerId].GetDonePassing() {
+	if u.CurTable.ValidPassFrom(cardsPassed, playerId) != nil {
 		return false
 	}
-	sound.PlaySound(1, u)
-	success := sync.LogPass(u, cardsPassed)
-	for !success {
-		success = sync.LogPass(u, cardsPassed)
+	if err := sync.LogPass(u, playerId, cardsPassed); err != nil {
+		return false
 	}
+	sound.PlaySound(1, u)
 	imgs := append(u.Other, u.DropTargets...)
 	imgs = append(imgs, u.Buttons["pass"])
 	reposition.AnimateHandCardPass(ch, imgs, u)
@@ -721,11 +887,10 @@ func takeCards(ch chan bool, playerId int, u *uistate.UIState) bool {
 	if len(passedCards) != 3 {
 		return false
 	}
-	sound.PlaySound(0, u)
-	success := sync.LogTake(u)
-	for !success {
-		success = sync.LogTake(u)
+	if err := sync.LogTake(u, playerId); err != nil {
+		return false
 	}
+	sound.PlaySound(0, u)
 	imgs := append(u.Other, u.Buttons["take"])
 	reposition.AnimateHandCardTake(ch, imgs, u)
 	return true
@@ -817,23 +982,18 @@ func touchingStaticImg(t touch.Event, s *staticimg.StaticImg, u *uistate.UIState
 }
 
 func handleDebugButtonClick(b *staticimg.StaticImg, u *uistate.UIState) {
-	if b == u.Buttons["player0"] {
-		u.CurPlayerIndex = 0
-		view.LoadPassOrTakeOrPlay(u)
-	} else if b == u.Buttons["player1"] {
-		u.CurPlayerIndex = 1
-		view.LoadPassOrTakeOrPlay(u)
-	} else if b == u.Buttons["player2"] {
-		u.CurPlayerIndex = 2
-		view.LoadPassOrTakeOrPlay(u)
-	} else if b == u.Buttons["player3"] {
-		u.CurPlayerIndex = 3
-		view.LoadPassOrTakeOrPlay(u)
-	} else if b == u.Buttons["table"] {
+	for p := 0; p < u.NumPlayers; p++ {
+		if b == u.Buttons[fmt.Sprintf("player%d", p)] {
+			u.CurPlayerIndex = p
+			view.LoadPassOrTakeOrPlay(u)
+			return
+		}
+	}
+	if b == u.Buttons["table"] {
 		view.LoadTableView(u)
 	} else if b == u.Buttons["hand"] {
 		view.LoadPassOrTakeOrPlay(u)
 	} else if b == u.Buttons["restart"] {
-		sync.ResetGame(u.LogSG, u.IsOwner, u)
+		sync.ResetGame(u.LogSG, u.IsOwner, u.NumPlayers, u)
 	}
 }
@@ -0,0 +1,180 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// config.go reads the settings which differ between devices and developers: the mount table to find other devices
+// on, the name of this device's Syncbase, where the last game is saved, the profile a new user starts with,
+// and the number of players and time limit on each move of the games this device creates.
+// Each setting is read from its default, then the config file, then the environment, then the command line,
+// each overriding the one before.
+
+package util
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// DefaultConfigFile is where the config file is read from unless CROUPIER_CONFIG or -croupier.config say otherwise
+const DefaultConfigFile = "/sdcard/croupier.json"
+
+// The numbers of players a game can be created for
+const (
+	MinPlayers = 3
+	MaxPlayers = 6
+)
+
+type Config struct {
+	MountPoint string `json:"mountPoint"`
+	SBName     string `json:"syncbaseName"`
+	AddrFile   string `json:"addrFile"`
+	UserID     int    `json:"userID"` // 0 to use the ID saved on this device, creating one on its first run
+	UserName   string `json:"userName"`
+	UserAvatar string `json:"userAvatar"`
+	UserColor  int    `json:"userColor"`
+	NumPlayers int    `json:"numPlayers"`
+	TurnLimit  int    `json:"turnLimit"` // seconds, 0 for no limit
+}
+
+// a setting which can be given in the environment or on the command line
+type option struct {
+	name  string // the name of the flag, after "croupier."
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var options = []option{
+	{"mount", "CROUPIER_MOUNT_POINT", "name of the mount table devices find each other on",
+		func(c *Config, v string) error { c.MountPoint = v; return nil }},
+	{"syncbase", "CROUPIER_SYNCBASE_NAME", "name this device's Syncbase is mounted under",
+		func(c *Config, v string) error { c.SBName = v; return nil }},
+	{"addr", "CROUPIER_ADDR_FILE", "file the game this device last joined is saved in",
+		func(c *Config, v string) error { c.AddrFile = v; return nil }},
+	{"user", "CROUPIER_USER_ID", "user ID to play as, instead of the one saved on this device",
+		func(c *Config, v string) error { return setInt(&c.UserID, v) }},
+	{"name", "CROUPIER_USER_NAME", "display name a new user starts with",
+		func(c *Config, v string) error { c.UserName = v; return nil }},
+	{"avatar", "CROUPIER_USER_AVATAR", "avatar a new user starts with",
+		func(c *Config, v string) error { c.UserAvatar = v; return nil }},
+	{"color", "CROUPIER_USER_COLOR", "color a new user starts with",
+		func(c *Config, v string) error { return setInt(&c.UserColor, v) }},
+	{"players", "CROUPIER_NUM_PLAYERS", "number of players in games this device creates",
+		func(c *Config, v string) error { return setInt(&c.NumPlayers, v) }},
+	{"turnlimit", "CROUPIER_TURN_LIMIT", "seconds each player has for a move in games this device creates, 0 for no limit",
+		func(c *Config, v string) error { return setInt(&c.TurnLimit, v) }},
+}
+
+const (
+	configEnv  = "CROUPIER_CONFIG"
+	configFlag = "config"
+	flagPrefix = "croupier."
+)
+
+func init() {
+	RegisterFlags(flag.CommandLine)
+	setConfig(DefaultConfig())
+}
+
+// Adds the flags which override the config to fs. They are parsed along with the other flags, by v23.Init
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.String(flagPrefix+configFlag, "", "config file to read instead of "+DefaultConfigFile)
+	for _, o := range options {
+		fs.String(flagPrefix+o.name, "", o.usage)
+	}
+}
+
+// Returns the config used when nothing overrides it
+func DefaultConfig() Config {
+	return Config{
+		MountPoint: "/192.168.86.254:8101",
+		SBName:     "syncbase1",
+		AddrFile:   "/sdcard/addr.txt",
+		UserName:   "Bruce",
+		UserAvatar: "man.png",
+		UserColor:  16777215,
+		NumPlayers: 4,
+	}
+}
+
+// Returns the default config overridden by the config file, then getenv, then the flags set in fs
+// A missing config file is skipped, but one which can't be read, or which sets up games no table can seat, is an error
+func ReadConfig(getenv func(string) string, fs *flag.FlagSet) (Config, error) {
+	c := DefaultConfig()
+	set := make(map[string]string)
+	if fs != nil {
+		fs.Visit(func(f *flag.Flag) {
+			set[f.Name] = f.Value.String()
+		})
+	}
+	path := DefaultConfigFile
+	if v := getenv(configEnv); v != "" {
+		path = v
+	}
+	if v, ok := set[flagPrefix+configFlag]; ok {
+		path = v
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &c); err != nil {
+			return c, fmt.Errorf("%s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return c, err
+	}
+	for _, o := range options {
+		if v := getenv(o.env); v != "" {
+			if err := o.set(&c, v); err != nil {
+				return c, fmt.Errorf("%s: %v", o.env, err)
+			}
+		}
+		if v, ok := set[flagPrefix+o.name]; ok {
+			if err := o.set(&c, v); err != nil {
+				return c, fmt.Errorf("-%s%s: %v", flagPrefix, o.name, err)
+			}
+		}
+	}
+	if !ValidNumPlayers(c.NumPlayers) {
+		return c, fmt.Errorf("games are for %d to %d players, not %d", MinPlayers, MaxPlayers, c.NumPlayers)
+	}
+	return c, nil
+}
+
+// Returns true if a game can be created for numPlayers
+func ValidNumPlayers(numPlayers int) bool {
+	return numPlayers >= MinPlayers && numPlayers <= MaxPlayers
+}
+
+// Reads the config from the environment and the command line flags, and sets this package's settings from it
+// The settings keep their defaults if the config can't be read
+func LoadConfig() error {
+	c, err := ReadConfig(os.Getenv, flag.CommandLine)
+	if err != nil {
+		return err
+	}
+	setConfig(c)
+	return nil
+}
+
+func setConfig(c Config) {
+	MountPoint = c.MountPoint
+	SBName = c.SBName
+	AddrFile = c.AddrFile
+	UserID = c.UserID
+	UserName = c.UserName
+	UserAvatar = c.UserAvatar
+	UserColor = c.UserColor
+	NumPlayers = c.NumPlayers
+	TurnLimit = c.TurnLimit
+}
+
+func setInt(field *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*field = n
+	return nil
+}
@@ -7,20 +7,22 @@
 package util
 
 const (
-	// switch back to my mountpoint with the following code:
-	//MountPoint = "users/emshack@google.com"
-	MountPoint        = "/192.168.86.254:8101"
-	UserID            = 323432
-	UserColor         = 16777215
-	UserAvatar        = "man.png"
-	UserName          = "Bruce"
-	SBName            = "syncbase1"
 	AppName           = "app"
 	DbName            = "db"
 	LogName           = "games"
 	SettingsName      = "table_settings"
 	CroupierInterface = "CroupierSettingsAndGame"
-	// Swap the following two lines when running app on a computer vs. mobile device:
-	// AddrFile = "src/dataParser/addr"
-	AddrFile = "/sdcard/addr.txt"
+)
+
+// These are set from the Config read by LoadConfig, and hold the DefaultConfig until then
+var (
+	MountPoint string
+	SBName     string
+	AddrFile   string
+	UserID     int // set by sync.CreateTables to the ID saved on this device, unless the Config chose one
+	UserColor  int
+	UserAvatar string
+	UserName   string
+	NumPlayers int // players in the games this device creates
+	TurnLimit  int // seconds a player has for each move in the games this device creates, 0 for no limit
 )
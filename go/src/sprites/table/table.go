@@ -19,6 +19,7 @@ func NewTable(p []*player.Player) *Table {
 		heartsBroken: false,
 		firstTrick:   true,
 		winCondition: 100,
+		rng:          rand.New(rand.NewSource(rand.Int63())),
 	}
 }
 
@@ -38,12 +39,19 @@ type Table struct {
 	//winCondition is the number of points needed to win the game
 	//traditionally 100, could set higher or lower for longer or shorter game
 	winCondition int
+	//rng is the source of randomness used to shuffle the deck
+	rng *rand.Rand
 }
 
 func (t *Table) GetPlayers() []*player.Player {
 	return t.players
 }
 
+//SetRand sets the source of randomness t deals from, so that a series of deals can be reproduced
+func (t *Table) SetRand(r *rand.Rand) {
+	t.rng = r
+}
+
 func (t *Table) SetFirstPlayed(index int) {
 	t.firstPlayed = index
 }
@@ -147,7 +155,7 @@ func (t *Table) Deal() {
 	if t.allCards == nil {
 		t.GenerateCards()
 	}
-	shuffle := rand.Perm(52)
+	shuffle := t.rng.Perm(52)
 	for i := 0; i < len(t.allCards); i++ {
 		t.players[i%numPlayers].AddToHand(t.allCards[shuffle[i]])
 	}